@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"fssh/internal/fsutil"
+	"fssh/internal/otp"
+	"fssh/internal/store"
+)
+
+// cmdBackup 把所有密钥解密后用备份口令重新加密到一个备份文件
+// 备份不依赖 master key，可用于迁移到新机器（包括 Touch ID 模式）
+func cmdBackup() {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("out", "", "output path (e.g. vault.fsshbak)")
+	passFile := fs.String("passphrase-file", "", "read backup passphrase from file path")
+	passStdin := fs.Bool("passphrase-stdin", false, "read backup passphrase from stdin")
+	force := fs.Bool("force", false, "overwrite output if exists")
+	fs.Parse(os.Args[2:])
+
+	if *out == "" {
+		fatal(errors.New("out is required"))
+	}
+	if !*force {
+		if _, err := os.Stat(*out); err == nil {
+			fatal(fmt.Errorf("output exists: %s", *out))
+		}
+	}
+	if store.RekeyInProgress() {
+		fatal(store.ErrRekeyInProgress)
+	}
+
+	mk, err := unlockMasterKey()
+	if err != nil {
+		fatal(err)
+	}
+
+	var passphrase string
+	if *passFile != "" || *passStdin {
+		passphrase, err = resolvePassphrase("", false, *passFile, *passStdin, "")
+	} else {
+		passphrase, err = otp.PromptPasswordWithConfirm("请设置备份口令（至少12位）: ", "确认口令: ")
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if err := otp.ValidatePasswordStrength(passphrase); err != nil {
+		fatal(fmt.Errorf("口令强度不足: %w", err))
+	}
+
+	fmt.Println("正在加密备份...")
+	data, n, err := store.CreateBackup(store.Default(), mk, passphrase)
+	if err != nil {
+		fatal(err)
+	}
+	if err := fsutil.WriteFileAtomic(*out, data, 0600); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("✓ 已备份 %d 个密钥到 %s\n", n, *out)
+	fmt.Println("  请妥善保管备份口令，丢失后备份无法恢复")
+}
+
+// cmdRestore 从备份文件导入密钥，用本地 master key 重新加密
+func cmdRestore() {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	in := fs.String("in", "", "backup file path")
+	onConflict := fs.String("on-conflict", store.ConflictSkip, "when an alias exists with a different key: skip, rename or overwrite")
+	dryRun := fs.Bool("dry-run", false, "show what would be restored without writing")
+	allowWeak := fs.Bool("allow-weak", false, "restore keys even if they do not satisfy key_policy")
+	passFile := fs.String("passphrase-file", "", "read backup passphrase from file path")
+	passStdin := fs.Bool("passphrase-stdin", false, "read backup passphrase from stdin")
+	fs.Parse(os.Args[2:])
+
+	if *in == "" {
+		fatal(errors.New("in is required"))
+	}
+	switch *onConflict {
+	case store.ConflictSkip, store.ConflictRename, store.ConflictOverwrite:
+	default:
+		fatal(fmt.Errorf("unsupported --on-conflict %q (supported: skip, rename, overwrite)", *onConflict))
+	}
+	if store.RekeyInProgress() {
+		fatal(store.ErrRekeyInProgress)
+	}
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		fatal(err)
+	}
+
+	var passphrase string
+	if *passFile != "" || *passStdin {
+		passphrase, err = resolvePassphrase("", false, *passFile, *passStdin, "")
+	} else {
+		passphrase, err = otp.PromptPassword("请输入备份口令: ")
+	}
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println("正在解密备份...")
+	bk, err := store.OpenBackup(data, passphrase)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("备份创建于 %s，包含 %d 个密钥\n", bk.CreatedAt, len(bk.Records))
+
+	// 与 import 相同，不满足 key_policy 的密钥不恢复
+	rejected := 0
+	accepted := bk.Records[:0]
+	for _, rec := range bk.Records {
+		if err := enforceKeyPolicy(rec, *allowWeak); err != nil {
+			fmt.Printf("✗ %s: %v\n", rec.Alias, err)
+			rejected++
+			continue
+		}
+		accepted = append(accepted, rec)
+	}
+	bk.Records = accepted
+
+	var mk []byte
+	if !*dryRun {
+		if mk, err = unlockMasterKey(); err != nil {
+			fatal(err)
+		}
+	}
+	results, err := store.RestoreBackup(store.Default(), bk, mk, *onConflict, *dryRun)
+	for _, r := range results {
+		switch r.Action {
+		case store.RestoreAdded:
+			fmt.Printf("✓ %s\n", r.Alias)
+		case store.RestoreRenamed:
+			fmt.Printf("✓ %s -> %s (别名已存在)\n", r.Alias, r.Target)
+		case store.RestoreReplaced:
+			fmt.Printf("✓ %s (已覆盖本地同名密钥)\n", r.Alias)
+		case store.RestoreSkipped:
+			fmt.Printf("- %s: 别名已存在且密钥不同，已跳过（--on-conflict rename|overwrite）\n", r.Alias)
+		case store.RestoreDuplicate:
+			fmt.Printf("- %s: 本地已有相同密钥 (alias=%s)，已跳过\n", r.Alias, r.Target)
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if *dryRun {
+		fmt.Println("(dry run，未写入任何密钥)")
+	}
+	if rejected > 0 {
+		fatal(fmt.Errorf("%d 个密钥不满足 key_policy，未恢复", rejected))
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fssh/internal/ca"
+	"fssh/internal/store"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// rawList 可重复指定的命令行参数，不按逗号拆分（source-address 的值本身含逗号）
+type rawList []string
+
+func (s *rawList) String() string { return strings.Join(*s, " ") }
+
+func (s *rawList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// cmdCA SSH 证书颁发机构子命令入口
+func cmdCA() {
+	if len(os.Args) < 3 {
+		caUsage()
+		os.Exit(2)
+	}
+	switch os.Args[2] {
+	case "sign-user":
+		cmdCASign(ca.TypeUser)
+	case "sign-host":
+		cmdCASign(ca.TypeHost)
+	case "log":
+		cmdCALog()
+	default:
+		caUsage()
+		os.Exit(2)
+	}
+}
+
+func caUsage() {
+	fmt.Fprintf(os.Stderr, "usage: fssh ca <sign-user|sign-host|log>\n")
+}
+
+// cmdCASign 用密钥库中的 CA 密钥签发用户或主机证书，写到 <pubkey>-cert.pub
+// 与 ssh-keygen -s 相同，已存在的证书文件会被覆盖
+func cmdCASign(certType string) {
+	fs := flag.NewFlagSet("ca sign-"+certType, flag.ExitOnError)
+	caAlias := fs.String("ca", "", "alias of the CA key in this store")
+	var principals, extensions stringList
+	var options rawList
+	fs.Var(&principals, "principals", "user names or host names the certificate is valid for (comma-separated or repeatable)")
+	defaultValidity := "8h"
+	if certType == ca.TypeHost {
+		defaultValidity = "52w"
+	}
+	validity := fs.String("validity", defaultValidity, "validity period, e.g. 8h, 30d, 52w, or forever")
+	keyID := fs.String("identity", "", "certificate key ID shown in sshd logs (default: public key comment or file name)")
+	out := fs.String("o", "", "output path (default: <pubkey without .pub>-cert.pub)")
+	if certType == ca.TypeUser {
+		fs.Var(&options, "option", "critical option: force-command=CMD, source-address=CIDR[,CIDR...] or verify-required (repeatable)")
+		fs.Var(&extensions, "extension", "extension to add, e.g. permit-pty (comma-separated or repeatable)")
+	}
+	noDefaults := fs.Bool("no-default-extensions", false, "do not add the default permit-* extensions (user certificates)")
+	fs.Parse(os.Args[3:])
+
+	if *caAlias == "" || fs.NArg() != 1 {
+		fatal(fmt.Errorf("usage: fssh ca sign-%s --ca <alias> --principals a,b [--validity 8h] <pubkey.pub>", certType))
+	}
+	pubPath := fs.Arg(0)
+	pubBytes, err := os.ReadFile(pubPath)
+	if err != nil {
+		fatal(err)
+	}
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey(pubBytes)
+	if err != nil {
+		fatal(fmt.Errorf("parse %s: %w", pubPath, err))
+	}
+
+	req := ca.Request{Type: certType, KeyID: *keyID, Principals: principals}
+	if req.KeyID == "" {
+		req.KeyID = comment
+	}
+	if req.KeyID == "" {
+		req.KeyID = strings.TrimSuffix(filepath.Base(pubPath), ".pub")
+	}
+	if *validity != "forever" {
+		if req.Validity, err = parseAge(*validity); err != nil {
+			fatal(err)
+		}
+	}
+	if certType == ca.TypeUser {
+		req.CriticalOptions = map[string]string{}
+		for _, o := range options {
+			name, value, _ := strings.Cut(o, "=")
+			req.CriticalOptions[name] = value
+		}
+		req.Extensions = map[string]string{}
+		if !*noDefaults {
+			for _, e := range ca.DefaultUserExtensions {
+				req.Extensions[e] = ""
+			}
+		}
+		for _, e := range extensions {
+			req.Extensions[e] = ""
+		}
+	}
+	if err := req.Validate(); err != nil {
+		fatal(err)
+	}
+	if *out == "" {
+		*out = strings.TrimSuffix(pubPath, ".pub") + "-cert.pub"
+	}
+
+	// CA 密钥过期后不再签发；先检查明文元数据，解锁后再校验元数据 MAC
+	b := store.Default()
+	ef, err := b.Get(*caAlias)
+	if err != nil {
+		fatal(err)
+	}
+	if ef.Meta.Expired(time.Now()) {
+		fatal(fmt.Errorf("CA key %s expired on %s", *caAlias, ef.Meta.Expires))
+	}
+	mk, err := unlockMasterKey()
+	if err != nil {
+		fatal(err)
+	}
+	if err := store.VerifyMeta(ef, mk); err != nil {
+		fatal(fmt.Errorf("CA key %s: %w", *caAlias, err))
+	}
+	rec, err := store.LoadRecord(b, *caAlias, mk)
+	if err != nil {
+		fatal(err)
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(rec.PKCS8DER)
+	if err != nil {
+		fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		fatal(err)
+	}
+	cert, err := ca.Sign(signer, *caAlias, pub, req)
+	if err != nil {
+		fatal(err)
+	}
+
+	line := strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(cert)), "\n")
+	if comment != "" {
+		line += " " + comment
+	}
+	if err := os.WriteFile(*out, []byte(line+"\n"), 0644); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("signed %s certificate serial=%d key_id=%q principals=%s\n", certType, cert.Serial, cert.KeyId, strings.Join(cert.ValidPrincipals, ","))
+	fmt.Printf("valid %s to %s\n", ca.FormatCertTime(cert.ValidAfter), ca.FormatCertTime(cert.ValidBefore))
+	fmt.Printf("wrote %s\n", *out)
+}
+
+// cmdCALog 列出签发日志
+func cmdCALog() {
+	fs := flag.NewFlagSet("ca log", flag.ExitOnError)
+	caFilter := fs.String("ca", "", "only certificates issued by this CA alias or fingerprint")
+	fs.Parse(os.Args[3:])
+
+	issued, err := ca.LoadLog()
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("%-7s %-5s %-24s %-25s %-25s %s\n", "SERIAL", "TYPE", "KEY ID", "VALID BEFORE", "PRINCIPALS", "CA")
+	n := 0
+	for _, is := range issued {
+		if *caFilter != "" && is.CA != *caFilter && is.CAFingerprint != *caFilter {
+			continue
+		}
+		fmt.Printf("%-7d %-5s %-24s %-25s %-25s %s\n", is.Serial, is.Type, is.KeyID, is.ValidBefore, strings.Join(is.Principals, ","), is.CA)
+		n++
+	}
+	fmt.Printf("\n%d certificate(s), log: %s\n", n, ca.LogPath())
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"fssh/internal/auth"
+	"fssh/internal/config"
+	"fssh/internal/otp"
+	"fssh/internal/profile"
+	"fssh/internal/sshconfig"
+	"fssh/internal/store"
+
+	xagent "golang.org/x/crypto/ssh/agent"
+)
+
+// 诊断结果级别
+const (
+	doctorOK = iota
+	doctorInfo
+	doctorWarn
+	doctorFail
+)
+
+// doctorFinding 一条诊断结果
+type doctorFinding struct {
+	level int
+	msg   string
+	fix   string       // 建议的修复方法
+	apply func() error // 可以安全自动执行的修复，nil 表示需要手动处理
+}
+
+// doctorReport 按检查项收集诊断结果
+type doctorReport struct {
+	findings []doctorFinding
+}
+
+func (r *doctorReport) section(title string) {
+	fmt.Printf("\n== %s ==\n", title)
+}
+
+func (r *doctorReport) add(f doctorFinding) {
+	r.findings = append(r.findings, f)
+	mark := map[int]string{doctorOK: "✓", doctorInfo: "·", doctorWarn: "⚠️ ", doctorFail: "✗"}[f.level]
+	fmt.Printf("%s %s\n", mark, f.msg)
+	if f.fix != "" {
+		auto := ""
+		if f.apply != nil {
+			auto = "（--fix 可自动修复）"
+		}
+		fmt.Printf("    修复: %s%s\n", f.fix, auto)
+	}
+}
+
+func (r *doctorReport) ok(format string, a ...interface{}) {
+	r.add(doctorFinding{level: doctorOK, msg: fmt.Sprintf(format, a...)})
+}
+
+func (r *doctorReport) info(format string, a ...interface{}) {
+	r.add(doctorFinding{level: doctorInfo, msg: fmt.Sprintf(format, a...)})
+}
+
+// cmdDoctor 检查 fssh 的安装和运行状态，并给出每个问题的修复建议
+// --fix 只执行不会丢失数据的修复（收紧权限、删除失效的 socket 和未提交的暂存等）
+func cmdDoctor() {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	decrypt := fs.Bool("decrypt", false, "unlock the master key and decrypt every record to confirm its fingerprint")
+	fix := fs.Bool("fix", false, "apply the safe fixes")
+	fs.Parse(os.Args[2:])
+
+	r := &doctorReport{}
+	fmt.Printf("profile=%s dir=%s\n", profile.Current(), profile.Dir())
+	doctorPermissions(r)
+	doctorRecords(r, *decrypt)
+	doctorAgent(r)
+	doctorSSHConfig(r)
+	doctorAutostart(r)
+
+	var problems, fixable, failed int
+	for _, f := range r.findings {
+		if f.level < doctorWarn {
+			continue
+		}
+		problems++
+		if f.apply != nil {
+			fixable++
+		}
+		if f.level == doctorFail {
+			failed++
+		}
+	}
+	fmt.Println()
+	if problems == 0 {
+		fmt.Println("未发现问题")
+		return
+	}
+	if !*fix {
+		fmt.Printf("发现 %d 个问题，其中 %d 个可以用 fssh doctor --fix 自动修复\n", problems, fixable)
+	} else {
+		fmt.Println("== 自动修复 ==")
+		fixed := 0
+		for _, f := range r.findings {
+			if f.level < doctorWarn || f.apply == nil {
+				continue
+			}
+			if err := f.apply(); err != nil {
+				fmt.Printf("✗ %s: %v\n", f.msg, err)
+				continue
+			}
+			fmt.Printf("✓ 已修复: %s\n", f.msg)
+			fixed++
+			if f.level == doctorFail {
+				failed--
+			}
+		}
+		fmt.Printf("已修复 %d 个问题，%d 个需要手动处理\n", fixed, problems-fixed)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// modeProblem 检查文件的所有者和权限，mask 中的权限位不应被设置
+// 文件不存在或没有问题时返回 nil
+func modeProblem(path string, mask os.FileMode) *doctorFinding {
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return &doctorFinding{level: doctorFail, msg: fmt.Sprintf("无法读取 %s: %v", path, err)}
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return &doctorFinding{
+			level: doctorFail,
+			msg:   fmt.Sprintf("%s 的所有者不是当前用户（uid %d）", path, st.Uid),
+			fix:   fmt.Sprintf("确认文件来源后执行 chown %d %s", os.Getuid(), path),
+		}
+	}
+	perm := fi.Mode().Perm()
+	if perm&mask == 0 {
+		return nil
+	}
+	want := perm &^ mask
+	return &doctorFinding{
+		level: doctorFail,
+		msg:   fmt.Sprintf("%s 的权限为 %04o，其他用户可以访问", path, perm),
+		fix:   fmt.Sprintf("chmod %04o %s", want, path),
+		apply: func() error { return os.Chmod(path, want) },
+	}
+}
+
+// doctorPermissions 检查 profile 目录、密钥、认证配置和 socket 的权限
+func doctorPermissions(r *doctorReport) {
+	r.section("文件权限")
+	dir := profile.Dir()
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		r.add(doctorFinding{level: doctorFail, msg: dir + " 不存在，尚未初始化", fix: "fssh init"})
+		return
+	}
+	type permCheck struct {
+		path string
+		mask os.FileMode
+	}
+	checks := []permCheck{
+		{dir, 0077},
+		{config.Path(), 0022}, // 不含机密，但可以改变 socket 和保险库路径
+		{otp.ConfigPath(), 0077},
+		{auth.PassphraseConfigPath(), 0077},
+	}
+	if b, ok := store.Default().(store.Relocatable); ok {
+		checks = append(checks, permCheck{b.Root(), 0077})
+	}
+	// 连接 unix socket 需要写权限
+	checks = append(checks, permCheck{agentSocketPath(), 0022})
+	for _, c := range checks {
+		if _, err := os.Stat(c.path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if f := modeProblem(c.path, c.mask); f != nil {
+			r.add(*f)
+		} else {
+			r.ok("%s", c.path)
+		}
+	}
+
+	db, ok := store.Default().(*store.DirBackend)
+	if !ok {
+		return
+	}
+	entries, err := os.ReadDir(db.Dir)
+	if err != nil {
+		return
+	}
+	good, total := 0, 0
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".enc") {
+			continue
+		}
+		total++
+		if f := modeProblem(filepath.Join(db.Dir, e.Name()), 0077); f != nil {
+			r.add(*f)
+		} else {
+			good++
+		}
+	}
+	if total > 0 && good == total {
+		r.ok("%d 个记录文件权限正确", total)
+	}
+}
+
+// doctorRecords 解析并检查每条记录，decrypt 时解锁 master key 逐条解密核对指纹
+func doctorRecords(r *doctorReport, decrypt bool) {
+	r.section("密钥记录")
+	b := store.Default()
+	if rb, ok := b.(store.Relocatable); ok {
+		doctorLeftovers(r, rb.Root())
+	}
+
+	var files []store.EncryptedFile
+	if db, ok := b.(*store.DirBackend); ok {
+		entries, err := db.Scan()
+		if err != nil {
+			r.add(doctorFinding{level: doctorFail, msg: fmt.Sprintf("无法读取密钥目录: %v", err)})
+			return
+		}
+		for _, e := range entries {
+			if e.Err != nil {
+				r.add(doctorFinding{
+					level: doctorFail,
+					msg:   fmt.Sprintf("%s 无法解析，list 和 agent 会跳过它: %v", e.Path, e.Err),
+					fix:   fmt.Sprintf("用 fssh restore 从备份恢复，或把文件移出 %s", db.Dir),
+				})
+				continue
+			}
+			if e.Record.Alias != e.Name {
+				target := filepath.Join(db.Dir, e.Record.Alias+".enc")
+				f := doctorFinding{
+					level: doctorFail,
+					msg:   fmt.Sprintf("%s 中记录的别名是 %q，按别名读取时找不到", e.Path, e.Record.Alias),
+					fix:   fmt.Sprintf("mv %s %s", e.Path, target),
+				}
+				if _, err := os.Stat(target); errors.Is(err, os.ErrNotExist) && len(store.CheckRecord(e.Record)) == 0 {
+					from := e.Path
+					f.apply = func() error { return os.Rename(from, target) }
+				}
+				r.add(f)
+			}
+			files = append(files, *e.Record)
+		}
+	} else {
+		list, err := b.List()
+		if err != nil {
+			r.add(doctorFinding{level: doctorFail, msg: fmt.Sprintf("无法读取保险库: %v", err), fix: "用 fssh restore 从备份恢复"})
+			return
+		}
+		files = list
+	}
+
+	now := time.Now()
+	var valid []*store.EncryptedFile
+	for i := range files {
+		ef := &files[i]
+		problems := store.CheckRecord(ef)
+		for _, p := range problems {
+			r.add(doctorFinding{
+				level: doctorFail,
+				msg:   fmt.Sprintf("%s: %v", ef.Alias, p),
+				fix:   "用 fssh restore --on-conflict overwrite 从备份恢复该记录",
+			})
+		}
+		if len(problems) > 0 {
+			continue
+		}
+		valid = append(valid, ef)
+		if ef.Version == store.VersionV1 {
+			r.add(doctorFinding{level: doctorWarn, msg: ef.Alias + " 仍是 v1 格式，元数据未被认证，agent 不会提供该密钥", fix: "fssh store migrate"})
+		} else if ef.MetaMAC == "" {
+			r.add(doctorFinding{level: doctorWarn, msg: ef.Alias + " 的标签、过期时间等元数据没有 MAC，agent 不会提供该密钥", fix: "fssh store migrate"})
+		}
+		if ef.Meta.Expired(now) {
+			r.add(doctorFinding{
+				level: doctorWarn,
+				msg:   fmt.Sprintf("%s 已于 %s 过期，agent 不再提供", ef.Alias, ef.Meta.Expires),
+				fix:   fmt.Sprintf("轮换该密钥，或 fssh key set --alias %s --expires never", ef.Alias),
+			})
+		}
+	}
+	if len(valid) == len(files) {
+		r.ok("%d 条记录结构正常", len(files))
+	} else {
+		r.info("%d/%d 条记录结构正常", len(valid), len(files))
+	}
+
+	if !decrypt {
+		r.info("未解密验证；使用 --decrypt 确认每条记录的私钥与指纹一致")
+		return
+	}
+	if len(valid) == 0 {
+		return
+	}
+	mk, err := unlockMasterKey()
+	if err != nil {
+		r.add(doctorFinding{level: doctorFail, msg: fmt.Sprintf("解锁 master key 失败: %v", err)})
+		return
+	}
+	verified := 0
+	for _, ef := range valid {
+		if err := store.VerifyRecord(ef, mk); err != nil {
+			r.add(doctorFinding{
+				level: doctorFail,
+				msg:   fmt.Sprintf("%s 解密失败: %v", ef.Alias, err),
+				fix:   "用 fssh restore --on-conflict overwrite 从备份恢复该记录",
+			})
+			continue
+		}
+		verified++
+	}
+	if verified == len(valid) {
+		r.ok("%d 条记录解密后指纹一致", verified)
+	}
+}
+
+// doctorLeftovers 检查中断的 rekey、未提交的暂存和残留的旧密钥库备份
+func doctorLeftovers(r *doctorReport, root string) {
+	if store.RekeyInProgress() {
+		r.add(doctorFinding{level: doctorFail, msg: "存在未完成的 rekey", fix: "fssh rekey --resume 或 fssh rekey --rollback"})
+		return
+	}
+	staging := root + ".staging"
+	if _, err := os.Stat(staging); err == nil {
+		r.add(doctorFinding{
+			level: doctorWarn,
+			msg:   "残留未提交的重新加密暂存 " + staging,
+			fix:   "rm -rf " + staging,
+			apply: func() error { return os.RemoveAll(staging) },
+		})
+	}
+	backups, _ := filepath.Glob(root + ".bak-*")
+	for _, bak := range backups {
+		r.add(doctorFinding{
+			level: doctorWarn,
+			msg:   fmt.Sprintf("残留旧密钥库备份 %s（用旧 master key 加密）", bak),
+			fix:   "确认当前密钥库可以正常使用后执行 rm -rf " + bak,
+		})
+	}
+}
+
+// doctorAgent 检查 agent socket 是否存在并能响应
+func doctorAgent(r *doctorReport) {
+	r.section("agent")
+	sock := agentSocketPath()
+	fi, err := os.Lstat(sock)
+	if errors.Is(err, os.ErrNotExist) {
+		r.add(doctorFinding{level: doctorWarn, msg: fmt.Sprintf("agent 未运行（%s 不存在）", sock), fix: "fssh agent，或配置自动启动"})
+		return
+	}
+	if err != nil {
+		r.add(doctorFinding{level: doctorFail, msg: fmt.Sprintf("无法读取 %s: %v", sock, err)})
+		return
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		r.add(doctorFinding{level: doctorFail, msg: sock + " 不是 socket", fix: "确认该文件无用后删除，再启动 agent"})
+		return
+	}
+	conn, err := net.DialTimeout("unix", sock, 2*time.Second)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			r.add(doctorFinding{
+				level: doctorWarn,
+				msg:   sock + " 没有进程监听，agent 可能已异常退出",
+				fix:   "删除失效的 socket 后重新启动 agent",
+				apply: func() error { return os.Remove(sock) },
+			})
+			return
+		}
+		r.add(doctorFinding{level: doctorFail, msg: fmt.Sprintf("无法连接 %s: %v", sock, err)})
+		return
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	keys, err := xagent.NewClient(conn).List()
+	if err != nil {
+		r.add(doctorFinding{level: doctorFail, msg: fmt.Sprintf("agent 没有响应: %v", err), fix: "重新启动 agent"})
+		return
+	}
+	r.ok("agent 在 %s 响应，提供 %d 个密钥", sock, len(keys))
+}
+
+// doctorSSHConfig 比较 SSH_AUTH_SOCK、ssh config 的 IdentityAgent 与 fssh 的 socket
+func doctorSSHConfig(r *doctorReport) {
+	r.section("SSH 配置")
+	sock := agentSocketPath()
+	fixConfig := "在 ~/.ssh/config 的 Host * 中设置 IdentityAgent " + sock
+	fixEnv := "export SSH_AUTH_SOCK=" + sock
+
+	identityAgent := ""
+	if cfg, found, err := sshconfig.LoadGlobalConfig(); err != nil {
+		r.add(doctorFinding{level: doctorWarn, msg: fmt.Sprintf("无法读取 ~/.ssh/config: %v", err)})
+	} else if found {
+		identityAgent = expandAgentPath(cfg.IdentityAgent)
+	}
+	env := os.Getenv("SSH_AUTH_SOCK")
+
+	switch {
+	case identityAgent == "" || identityAgent == "SSH_AUTH_SOCK":
+		// ssh 使用 SSH_AUTH_SOCK
+		switch {
+		case env == "":
+			r.add(doctorFinding{level: doctorWarn, msg: "ssh config 未设置 IdentityAgent，SSH_AUTH_SOCK 也为空，ssh 不会使用 fssh", fix: fixConfig})
+		case samePath(env, sock):
+			r.ok("ssh 通过 SSH_AUTH_SOCK 使用 fssh")
+		default:
+			r.add(doctorFinding{
+				level: doctorWarn,
+				msg:   fmt.Sprintf("ssh config 未设置 IdentityAgent，SSH_AUTH_SOCK 指向 %s 而不是 fssh", env),
+				fix:   fixConfig + "，或 " + fixEnv,
+			})
+		}
+	case identityAgent == "none":
+		r.add(doctorFinding{level: doctorWarn, msg: "ssh config 的 IdentityAgent 为 none，ssh 不会使用任何 agent", fix: fixConfig})
+	case samePath(identityAgent, sock):
+		r.ok("ssh config 的 IdentityAgent 指向 fssh")
+		if !samePath(env, sock) {
+			r.info("SSH_AUTH_SOCK=%s 不是 fssh，直接读取它的程序（如 ssh-add）不会使用 fssh；需要时 %s", env, fixEnv)
+		}
+	default:
+		r.add(doctorFinding{
+			level: doctorWarn,
+			msg:   fmt.Sprintf("ssh config 的 IdentityAgent 指向 %s 而不是 fssh 的 %s", identityAgent, sock),
+			fix:   fixConfig,
+		})
+	}
+
+	hosts, err := sshconfig.LoadAllHostConfigs()
+	if err != nil {
+		return
+	}
+	for name, h := range hosts {
+		if name == "*" || h.IdentityAgent == "" {
+			continue
+		}
+		if p := expandAgentPath(h.IdentityAgent); !samePath(p, sock) {
+			r.info("Host %s 使用自己的 IdentityAgent %s", name, p)
+		}
+	}
+}
+
+// expandAgentPath 展开 IdentityAgent 中的 ~ 和环境变量
+func expandAgentPath(p string) string {
+	p = strings.Trim(p, `"`)
+	if p == "SSH_AUTH_SOCK" || p == "none" {
+		return p
+	}
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[2:])
+		}
+	}
+	return os.ExpandEnv(p)
+}
+
+// samePath 比较两个路径是否指向同一位置
+func samePath(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if ra, err := filepath.EvalSymlinks(a); err == nil {
+		a = ra
+	}
+	if rb, err := filepath.EvalSymlinks(b); err == nil {
+		b = rb
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// doctorAutostart 报告 LaunchAgent（macOS）或 systemd 用户服务（Linux）的状态
+func doctorAutostart(r *doctorReport) {
+	r.section("自动启动")
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	if runtime.GOOS == "darwin" {
+		label := launchAgentLabel()
+		plist := filepath.Join(home, "Library", "LaunchAgents", label+".plist")
+		if _, err := os.Stat(plist); err != nil {
+			r.add(doctorFinding{level: doctorWarn, msg: "未配置 LaunchAgent，登录后 agent 不会自动启动", fix: "fssh init --interactive"})
+			return
+		}
+		if err := exec.Command("launchctl", "list", label).Run(); err != nil {
+			r.add(doctorFinding{
+				level: doctorWarn,
+				msg:   fmt.Sprintf("LaunchAgent %s 未加载", label),
+				fix:   "launchctl load " + plist,
+				apply: func() error { return exec.Command("launchctl", "load", plist).Run() },
+			})
+			return
+		}
+		r.ok("LaunchAgent %s 已加载", label)
+		return
+	}
+
+	unit := "fssh-agent.service"
+	if !profile.IsDefault() {
+		unit = "fssh-agent-" + profile.Current() + ".service"
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	if _, err := os.Stat(filepath.Join(configHome, "systemd", "user", unit)); err != nil {
+		r.info("未配置 systemd 用户服务 %s", unit)
+		return
+	}
+	out, _ := exec.Command("systemctl", "--user", "is-active", unit).Output()
+	state := strings.TrimSpace(string(out))
+	if state == "active" {
+		r.ok("systemd 用户服务 %s 正在运行", unit)
+		return
+	}
+	if state == "" {
+		state = "unknown"
+	}
+	r.add(doctorFinding{
+		level: doctorWarn,
+		msg:   fmt.Sprintf("systemd 用户服务 %s 状态为 %s", unit, state),
+		fix:   "systemctl --user start " + unit,
+		apply: func() error { return exec.Command("systemctl", "--user", "start", unit).Run() },
+	})
+}
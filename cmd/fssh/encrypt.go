@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"fssh/internal/age"
+	"fssh/internal/auth"
+	"fssh/internal/store"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// cmdEncrypt 用 ed25519 公钥加密小文件（env 文件、令牌等），输出 age 格式
+// 只需要接收方公钥，不需要解锁 master key
+func cmdEncrypt() {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	var recipients stringList
+	fs.Var(&recipients, "r", "recipient: alias in this store, ssh-ed25519 public key file or public key string (repeatable)")
+	out := fs.String("o", "", "output path (default stdout)")
+	armor := fs.Bool("a", false, "write ASCII-armored output")
+	fs.BoolVar(armor, "armor", false, "same as -a")
+	fs.Parse(os.Args[2:])
+
+	if len(recipients) == 0 {
+		fatal(errors.New("at least one -r recipient is required"))
+	}
+	var rs []age.Recipient
+	for _, r := range recipients {
+		pk, err := resolvePublicKey(r)
+		if err != nil {
+			fatal(err)
+		}
+		rec, err := age.NewEd25519Recipient(pk)
+		if err != nil {
+			fatal(fmt.Errorf("%s: %w", r, err))
+		}
+		rs = append(rs, rec)
+	}
+	if *out == "" && !*armor && term.IsTerminal(int(os.Stdout.Fd())) {
+		fatal(errors.New("refusing to write binary output to a terminal; use -a or -o"))
+	}
+
+	plaintext, err := readInput(fs.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	data, err := age.Encrypt(plaintext, rs...)
+	if err != nil {
+		fatal(err)
+	}
+	if *armor {
+		data = age.Armor(data)
+	}
+	if err := writeOutput(*out, data); err != nil {
+		fatal(err)
+	}
+}
+
+// cmdDecrypt 用密钥库中的 ed25519 私钥解密 age 文件
+// 未指定 -k 时按文件头中的公钥标签查找接收方；解密需要与签名相同的解锁（Touch ID/OTP/口令）
+func cmdDecrypt() {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	alias := fs.String("k", "", "alias of the ed25519 key to decrypt with (default: any matching key in this store)")
+	out := fs.String("o", "", "output path (default stdout)")
+	fs.Parse(os.Args[2:])
+
+	// OTP 和口令模式从标准输入读取验证码或口令，不能同时从标准输入读取密文
+	mode, err := auth.LoadMode()
+	if err != nil {
+		fatal(err)
+	}
+	if in := fs.Arg(0); (in == "" || in == "-") && mode != auth.ModeTouchID {
+		fatal(fmt.Errorf("in %s mode the unlock prompt reads stdin; pass the encrypted file as a path", mode))
+	}
+
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	stanzas, err := age.Stanzas(data)
+	if err != nil {
+		fatal(err)
+	}
+	tags := map[string]bool{}
+	for _, s := range stanzas {
+		if s.Type == "ssh-ed25519" && len(s.Args) > 0 {
+			tags[s.Args[0]] = true
+		}
+	}
+
+	b := store.Default()
+	var candidates []string
+	if *alias != "" {
+		candidates = []string{*alias}
+	} else {
+		files, err := b.List()
+		if err != nil {
+			fatal(err)
+		}
+		for i := range files {
+			pk, err := files[i].PublicKey()
+			if err == nil && pk.Type() == ssh.KeyAlgoED25519 && tags[age.Tag(pk)] {
+				candidates = append(candidates, files[i].Alias)
+			}
+		}
+		if len(candidates) == 0 {
+			fatal(errors.New("no ed25519 key in this store is a recipient of this file"))
+		}
+	}
+
+	mk, err := unlockLikeSigning()
+	if err != nil {
+		fatal(err)
+	}
+	var ids []age.Identity
+	for _, a := range candidates {
+		rec, err := store.LoadRecord(b, a, mk)
+		if err != nil {
+			fatal(err)
+		}
+		priv, err := x509.ParsePKCS8PrivateKey(rec.PKCS8DER)
+		if err != nil {
+			fatal(fmt.Errorf("%s: %w", a, err))
+		}
+		edPriv, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			fatal(fmt.Errorf("%s is not an ed25519 key", a))
+		}
+		id, err := age.NewEd25519Identity(edPriv)
+		if err != nil {
+			fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	plaintext, err := age.Decrypt(data, ids...)
+	if err != nil {
+		fatal(err)
+	}
+	if err := writeOutput(*out, plaintext); err != nil {
+		fatal(err)
+	}
+}
+
+// unlockLikeSigning 通过当前认证模式的 provider 解锁 master key，与 agent 签名相同（OTP 模式需要输入验证码）
+// unlockMasterKey 在 OTP 模式下直接读取 Keychain，只适合管理命令
+// 提示信息改写到标准错误，避免混入写到标准输出的明文
+func unlockLikeSigning() ([]byte, error) {
+	provider, err := auth.GetAuthProvider(0)
+	if err != nil {
+		return nil, err
+	}
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+	return provider.UnlockMasterKey()
+}
+
+// resolvePublicKey 依次把参数当作别名、公钥文件和公钥字符串解析
+func resolvePublicKey(arg string) (ssh.PublicKey, error) {
+	if ef, err := store.Default().Get(arg); err == nil {
+		return ef.PublicKey()
+	}
+	text := []byte(arg)
+	if data, err := os.ReadFile(arg); err == nil {
+		text = data
+	}
+	pk, _, _, _, err := ssh.ParseAuthorizedKey(text)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not an alias, a public key file or a public key", arg)
+	}
+	return pk, nil
+}
+
+// readInput 读取文件，路径为空或 "-" 时读取标准输入
+func readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// writeOutput 写入文件（0600），路径为空时写到标准输出
+func writeOutput(path string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
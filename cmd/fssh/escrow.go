@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"fssh/internal/auth"
+	"fssh/internal/escrow"
+	"fssh/internal/keychain"
+	"fssh/internal/otp"
+	"fssh/internal/qrcode"
+	"fssh/internal/store"
+)
+
+// cmdEscrow 秘密分享托管子命令入口
+func cmdEscrow() {
+	if len(os.Args) < 3 {
+		escrowUsage()
+		os.Exit(2)
+	}
+	switch os.Args[2] {
+	case "split":
+		cmdEscrowSplit()
+	case "combine":
+		cmdEscrowCombine()
+	default:
+		escrowUsage()
+		os.Exit(2)
+	}
+}
+
+func escrowUsage() {
+	fmt.Fprintf(os.Stderr, "usage: fssh escrow <split|combine>\n")
+}
+
+// cmdEscrowSplit 把 master key 或备份口令拆分为 n 份 Shamir 分享，任意 k 份可恢复
+func cmdEscrowSplit() {
+	fs := flag.NewFlagSet("escrow split", flag.ExitOnError)
+	n := fs.Int("n", 5, "number of shares")
+	k := fs.Int("k", 3, "number of shares required to recover")
+	secret := fs.String("secret", "master-key", "secret to split: master-key or backup-passphrase")
+	format := fs.String("format", "words", "output format: words or qr")
+	fs.Parse(os.Args[3:])
+
+	if *format != "words" && *format != "qr" {
+		fatal(errors.New("--format must be words or qr"))
+	}
+	var (
+		kind escrow.Kind
+		data []byte
+		err  error
+	)
+	switch *secret {
+	case "master-key":
+		kind = escrow.KindMasterKey
+		data, err = unlockMasterKey()
+	case "backup-passphrase":
+		kind = escrow.KindBackupPassphrase
+		var p string
+		p, err = otp.PromptPasswordWithConfirm("请输入要托管的备份口令: ", "确认口令: ")
+		data = []byte(p)
+	default:
+		fatal(errors.New("--secret must be master-key or backup-passphrase"))
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	shares, err := escrow.Split(kind, data, *n, *k)
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Println()
+	fmt.Printf("⚠️  已将 %s 拆分为 %d 份，任意 %d 份即可恢复\n", kind, *n, *k)
+	fmt.Println("   请分别交给不同的人保管，不要把多份放在同一处")
+	for _, s := range shares {
+		fmt.Println()
+		fmt.Printf("===== 分享 %d/%d（需要 %d 份）=====\n", s.Index, *n, *k)
+		if *format == "qr" {
+			code, err := qrcode.Encode([]byte(s.String()), qrcode.LevelM)
+			if err != nil {
+				fatal(fmt.Errorf("生成二维码失败: %w", err))
+			}
+			if err := code.WriteTerminal(os.Stdout); err != nil {
+				fatal(err)
+			}
+		}
+		words := s.Words()
+		for i := 0; i < len(words); i += 8 {
+			end := i + 8
+			if end > len(words) {
+				end = len(words)
+			}
+			fmt.Printf("  %2d. %s\n", i+1, strings.Join(words[i:end], " "))
+		}
+	}
+	fmt.Println()
+	fmt.Println("恢复: fssh escrow combine")
+}
+
+// cmdEscrowCombine 从足够数量的分享恢复秘密
+// master key 会通过当前认证方式重新保存；备份口令直接显示
+func cmdEscrowCombine() {
+	fs := flag.NewFlagSet("escrow combine", flag.ExitOnError)
+	file := fs.String("file", "", "read shares from file (one share per line) instead of prompting")
+	fs.Parse(os.Args[3:])
+
+	var in io.Reader = os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+	shares, err := readShares(bufio.NewReader(in), *file == "")
+	if err != nil {
+		fatal(err)
+	}
+	secret, err := escrow.Combine(shares)
+	if err != nil {
+		fatal(err)
+	}
+
+	switch shares[0].Kind {
+	case escrow.KindBackupPassphrase:
+		fmt.Println()
+		fmt.Println("⚠️  以下为备份口令，请勿截屏或分享")
+		fmt.Printf("备份口令: %s\n", secret)
+		fmt.Println("恢复备份: fssh restore --in <备份文件>")
+	case escrow.KindMasterKey:
+		if err := restoreMasterKeyAccess(secret); err != nil {
+			fatal(err)
+		}
+	default:
+		fatal(fmt.Errorf("unsupported secret type: %s", shares[0].Kind))
+	}
+}
+
+// readShares 逐行读取分享直到达到门限
+// 交互模式下每行提示一次，输入错误的分享会要求重新输入
+func readShares(r *bufio.Reader, interactive bool) ([]*escrow.Share, error) {
+	var shares []*escrow.Share
+	for len(shares) == 0 || len(shares) < shares[0].Threshold {
+		if interactive {
+			if len(shares) == 0 {
+				fmt.Print("分享 1（粘贴整行词列表）: ")
+			} else {
+				fmt.Printf("分享 %d/%d: ", len(shares)+1, shares[0].Threshold)
+			}
+		}
+		line, err := r.ReadString('\n')
+		if strings.TrimSpace(line) == "" {
+			if err != nil {
+				if len(shares) == 0 {
+					return nil, errors.New("no shares")
+				}
+				return nil, fmt.Errorf("need %d shares, got %d", shares[0].Threshold, len(shares))
+			}
+			continue
+		}
+		s, perr := escrow.ParseShare(line)
+		if perr == nil && len(shares) > 0 && (s.Kind != shares[0].Kind || s.SetID != shares[0].SetID) {
+			perr = errors.New("share belongs to a different split")
+		}
+		if perr == nil {
+			for _, prev := range shares {
+				if prev.Index == s.Index {
+					perr = fmt.Errorf("share #%d already entered", s.Index)
+				}
+			}
+		}
+		if perr != nil {
+			if !interactive {
+				return nil, perr
+			}
+			fmt.Printf("✗ %v，请重新输入\n", perr)
+			continue
+		}
+		shares = append(shares, s)
+		if interactive {
+			fmt.Printf("✓ 分享 #%d\n", s.Index)
+		}
+	}
+	return shares, nil
+}
+
+// restoreMasterKeyAccess 校验恢复出的 master key，并通过当前认证方式重新建立访问
+// Touch ID 写回 Keychain；口令模式设置新口令；
+// OTP 模式的 master key 由 seed 派生，需要重新初始化 OTP 并重新加密密钥库
+func restoreMasterKeyAccess(mk []byte) error {
+	if store.RekeyInProgress() {
+		return store.ErrRekeyInProgress
+	}
+	if err := verifyMasterKey(mk); err != nil {
+		return err
+	}
+	mode, err := auth.LoadMode()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ 已恢复 master key，通过 %s 模式重新建立访问\n", mode)
+
+	switch mode {
+	case auth.ModeTouchID:
+		if err := keychain.StoreMasterKey(mk, true); err != nil {
+			return err
+		}
+		fmt.Println("✓ master key 已写入 Keychain")
+	case auth.ModePassphrase:
+		passphrase, err := promptNewPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := auth.InitPassphrase(mk, passphrase); err != nil {
+			return err
+		}
+		fmt.Println("✓ 已用新口令保护 master key")
+	case auth.ModeOTP:
+		return restoreOTPAccess(mk)
+	default:
+		return fmt.Errorf("unsupported auth mode: %s", mode)
+	}
+	fmt.Println("如果 agent 正在运行，请重启 agent")
+	return nil
+}
+
+// verifyMasterKey 用任意一条记录确认 master key 属于当前 profile
+func verifyMasterKey(mk []byte) error {
+	b := store.Default()
+	files, err := b.List()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Println("⚠️  密钥库为空，无法校验恢复出的 master key")
+		return nil
+	}
+	if _, err := store.LoadRecord(b, files[0].Alias, mk); err != nil {
+		return errors.New("恢复出的 master key 无法解密当前密钥库，分享可能属于其他 profile 或其他机器")
+	}
+	return nil
+}
+
+// restoreOTPAccess 重新初始化 OTP（新 seed 和密码），并把密钥库从恢复出的 master key 重新加密到新 key
+func restoreOTPAccess(mk []byte) error {
+	opts := &otp.InitOptions{Period: 30, GenerateRecovery: true}
+	if cfg, err := otp.LoadConfig(otp.ConfigPath()); err == nil {
+		opts.SeedUnlockTTL = cfg.SeedUnlockTTLSeconds
+		opts.Type = cfg.OTPType()
+		opts.Algorithm = cfg.Algorithm
+		opts.Digits = cfg.Digits
+		opts.SkewSteps = cfg.SkewSteps
+		opts.LookAhead = cfg.HOTPLookAhead
+	}
+
+	fmt.Println("OTP 模式需要重新初始化认证器")
+	undo, err := snapshotAuthState(auth.ModeOTP, mk)
+	if err != nil {
+		return err
+	}
+	res, err := setupOTPKey(opts)
+	if err != nil {
+		undo()
+		return fmt.Errorf("初始化 OTP 失败: %w", err)
+	}
+	re, err := store.StageReencryption(mk, res.masterKey)
+	if err != nil {
+		undo()
+		return fmt.Errorf("重新加密失败（密钥库未改动）: %w", err)
+	}
+	if err := re.Commit(); err != nil {
+		re.Abort()
+		undo()
+		return fmt.Errorf("替换密钥目录失败（密钥库未改动）: %w", err)
+	}
+	if err := re.Cleanup(); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
+
+	fmt.Printf("✓ 已重新加密 %d 个密钥\n", re.Count)
+	fmt.Println("⚠️  旧的托管分享已失效，请重新运行 fssh escrow split")
+	if err := otp.DisplayInitResult(res.seed, res.recoveryCodes, res.config); err != nil {
+		return err
+	}
+	fmt.Println("如果 agent 正在运行，请重启 agent")
+	return nil
+}
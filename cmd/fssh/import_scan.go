@@ -0,0 +1,319 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"fssh/internal/fsutil"
+	"fssh/internal/otp"
+	"fssh/internal/store"
+)
+
+// 扫描导入后对原始私钥文件的处理方式
+const (
+	originalsKeep   = "keep"   // 保留原文件
+	originalsBackup = "backup" // 写入加密备份后覆盖并删除原文件
+	originalsShred  = "shred"  // 直接覆盖并删除原文件
+)
+
+// scanImportOptions fssh import --scan 的参数
+type scanImportOptions struct {
+	Dir            string
+	Originals      string
+	BackupOut      string
+	BackupPassFile string
+	Yes            bool
+	AllowWeak      bool
+}
+
+// scanImportItem 一个待处理的私钥文件
+type scanImportItem struct {
+	key         *SSHKeyInfo
+	alias       string        // 导入或已存在的别名
+	rec         *store.Record // 需要新导入时非空
+	fileChecked bool          // 已解析私钥文件，key.Fingerprint 来自私钥本身
+	verified    bool          // 密钥库中的副本已解密，指纹与私钥文件一致
+}
+
+// cmdImportScan 批量导入目录中的私钥
+// 按指纹去重，建议不冲突的别名，只解锁一次；导入后核对密钥库中的副本，再按需备份并删除明文原文件
+func cmdImportScan(opts scanImportOptions) {
+	switch opts.Originals {
+	case originalsKeep, originalsShred:
+	case originalsBackup:
+		if opts.BackupOut == "" {
+			fatal(errors.New("--originals backup requires --backup-out"))
+		}
+		if _, err := os.Stat(opts.BackupOut); err == nil {
+			fatal(fmt.Errorf("output exists: %s", opts.BackupOut))
+		}
+	default:
+		fatal(errors.New("--originals must be keep, backup or shred"))
+	}
+	if store.RekeyInProgress() {
+		fatal(store.ErrRekeyInProgress)
+	}
+
+	keys, err := scanSSHDirectory(opts.Dir)
+	if err != nil {
+		fatal(err)
+	}
+	if len(keys) == 0 {
+		fmt.Printf("no private keys found in %s\n", opts.Dir)
+		return
+	}
+	b := store.Default()
+	byFingerprint, taken, err := existingKeys(b)
+	if err != nil {
+		fatal(err)
+	}
+
+	// 1. 列出扫描结果；已导入的密钥不再导入，但原文件仍按 --originals 处理
+	items := make([]*scanImportItem, len(keys))
+	fmt.Printf("found %d private key file(s) in %s:\n", len(keys), opts.Dir)
+	for i, key := range keys {
+		item := &scanImportItem{key: key}
+		status := ""
+		if alias, ok := byFingerprint[key.Fingerprint]; ok && key.Fingerprint != "" {
+			item.alias = alias
+			status = fmt.Sprintf("already imported as %s", alias)
+		} else {
+			item.alias = uniqueAlias(generateAlias(key.Filename), taken)
+			taken[item.alias] = true
+			status = "new, alias " + item.alias
+		}
+		if key.IsEncrypted {
+			status += " [encrypted]"
+		}
+		fmt.Printf("  %d) %-24s %s\n", i+1, key.Filename, status)
+		items[i] = item
+	}
+
+	if !opts.Yes {
+		selection, err := otp.PromptInput("Keys to process (e.g. 1,3 or 1-3) [all]: ")
+		if err != nil {
+			fatal(err)
+		}
+		selection = strings.ReplaceAll(strings.TrimSpace(selection), " ", "")
+		if selection == "" {
+			selection = "all"
+		}
+		indices, err := parseSelection(selection, len(items))
+		if err != nil {
+			fatal(err)
+		}
+		var selected []*scanImportItem
+		for _, i := range indices {
+			selected = append(selected, items[i])
+		}
+		items = selected
+	}
+
+	// 2. 解析私钥文件，加密的密钥逐个询问口令
+	// 删除原文件前必须用私钥本身的指纹核对密钥库中的副本；扫描时的指纹可能来自 .pub 文件，不能作为依据
+	var pending []*scanImportItem
+	for _, item := range items {
+		alias, known := byFingerprint[item.key.Fingerprint]
+		known = known && item.key.Fingerprint != ""
+		if known && opts.Originals == originalsKeep {
+			// 已导入，或与本次扫描中前一个文件相同；原文件保留，不需要解密
+			item.alias = alias
+			pending = append(pending, item)
+			continue
+		}
+		data, err := os.ReadFile(item.key.Path)
+		if err != nil {
+			fmt.Printf("  ✗ %s: %v\n", item.key.Filename, err)
+			continue
+		}
+		passphrase := ""
+		if item.key.IsEncrypted {
+			if passphrase, err = otp.PromptPassword(fmt.Sprintf("Passphrase for %s: ", item.key.Filename)); err != nil {
+				fatal(err)
+			}
+		}
+		rec, err := store.NewRecordFromPrivateKeyBytes(item.alias, data, passphrase, "")
+		if err != nil {
+			fmt.Printf("  ✗ %s: %v\n", item.key.Filename, err)
+			continue
+		}
+		if known && rec.Fingerprint != item.key.Fingerprint {
+			fmt.Printf("  ! %s: the private key does not match %s.pub (%s), treating it as a new key\n", item.key.Filename, item.key.Filename, item.key.Fingerprint)
+			item.alias = uniqueAlias(generateAlias(item.key.Filename), taken)
+			taken[item.alias] = true
+		}
+		item.key.Fingerprint, item.fileChecked = rec.Fingerprint, true
+		if alias, ok := byFingerprint[rec.Fingerprint]; ok {
+			if item.alias != alias {
+				fmt.Printf("  - %s is already imported as %s\n", item.key.Filename, alias)
+				delete(taken, item.alias)
+			}
+			item.alias = alias
+			pending = append(pending, item)
+			continue
+		}
+		if !opts.Yes {
+			delete(taken, item.alias)
+			alias, err := otp.PromptInput(fmt.Sprintf("Alias for %s [%s]: ", item.key.Filename, item.alias))
+			if err != nil {
+				fatal(err)
+			}
+			if alias = strings.TrimSpace(alias); alias != "" {
+				item.alias = alias
+			}
+			if taken[item.alias] {
+				fmt.Printf("  ✗ %s: alias %s is already in use, skipped\n", item.key.Filename, item.alias)
+				continue
+			}
+			taken[item.alias] = true
+		}
+		rec.Alias = item.alias
+		if err := enforceKeyPolicy(rec, opts.AllowWeak); err != nil {
+			fmt.Printf("  ✗ %s: %v\n", item.key.Filename, err)
+			continue
+		}
+		item.rec = rec
+		byFingerprint[rec.Fingerprint] = item.alias
+		pending = append(pending, item)
+	}
+	if len(pending) == 0 {
+		fmt.Println("nothing to import")
+		return
+	}
+
+	// 3. 一次解锁，批量保存，再逐条解密核对
+	mk, err := unlockMasterKey()
+	if err != nil {
+		fatal(err)
+	}
+	imported, verified := 0, 0
+	for _, item := range pending {
+		if item.rec != nil {
+			if err := store.SaveRecord(b, item.rec, mk); err != nil {
+				fmt.Printf("  ✗ %s: %v\n", item.key.Filename, err)
+				continue
+			}
+			imported++
+			fmt.Printf("  ✓ imported %s as %s fingerprint=%s\n", item.key.Filename, item.alias, item.rec.Fingerprint)
+		}
+		if !item.fileChecked {
+			continue
+		}
+		stored, err := store.LoadRecord(b, item.alias, mk)
+		if err != nil || stored.Fingerprint != item.key.Fingerprint {
+			fmt.Printf("  ✗ %s: stored copy %s could not be verified, original kept\n", item.key.Filename, item.alias)
+			continue
+		}
+		item.verified = true
+		verified++
+	}
+	fmt.Printf("imported %d key(s), %d stored copies verified\n", imported, verified)
+
+	// 4. 处理已核对的原文件
+	var originals []*scanImportItem
+	for _, item := range pending {
+		if item.verified {
+			originals = append(originals, item)
+		}
+	}
+	switch opts.Originals {
+	case originalsKeep:
+		originals = pending
+		if len(originals) > 0 {
+			fmt.Println()
+			fmt.Println("⚠️  the plaintext originals are still on disk:")
+			for _, item := range originals {
+				fmt.Printf("    %s\n", item.key.Path)
+			}
+			fmt.Println("   rerun with --originals backup --backup-out <file> or --originals shred to remove them")
+		}
+		return
+	case originalsBackup:
+		if err := backupOriginals(b, originals, mk, opts); err != nil {
+			fatal(fmt.Errorf("backup failed, originals kept: %w", err))
+		}
+	}
+	for _, item := range originals {
+		if err := shredFile(item.key.Path); err != nil {
+			fmt.Printf("  ✗ %s: %v\n", item.key.Path, err)
+			continue
+		}
+		fmt.Printf("  ✓ removed %s\n", item.key.Path)
+	}
+}
+
+// backupOriginals 把已核对的密钥写入加密备份（fssh restore 可恢复），并确认备份可以解密
+func backupOriginals(b store.Backend, items []*scanImportItem, mk []byte, opts scanImportOptions) error {
+	mem := store.NewMemoryBackend()
+	for _, item := range items {
+		rec, err := store.LoadRecord(b, item.alias, mk)
+		if err != nil {
+			return err
+		}
+		if err := store.SaveRecord(mem, rec, mk); err != nil {
+			return err
+		}
+	}
+
+	var passphrase string
+	var err error
+	if opts.BackupPassFile != "" {
+		passphrase, err = resolvePassphrase("", false, opts.BackupPassFile, false, "")
+	} else {
+		passphrase, err = otp.PromptPasswordWithConfirm("请设置备份口令（至少12位）: ", "确认口令: ")
+	}
+	if err != nil {
+		return err
+	}
+	if err := otp.ValidatePasswordStrength(passphrase); err != nil {
+		return fmt.Errorf("口令强度不足: %w", err)
+	}
+
+	data, n, err := store.CreateBackup(mem, mk, passphrase)
+	if err != nil {
+		return err
+	}
+	// 随后会删除原文件：备份必须完整落盘，也不能覆盖检查之后才出现的同名文件
+	if err := fsutil.WriteFileExclusive(opts.BackupOut, data, 0600); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("output exists: %s", opts.BackupOut)
+		}
+		return err
+	}
+	bk, err := store.OpenBackup(data, passphrase)
+	if err != nil || len(bk.Records) != n {
+		return fmt.Errorf("backup %s could not be verified", opts.BackupOut)
+	}
+	fmt.Printf("✓ backed up %d key(s) to %s (restore with fssh restore --in %s)\n", n, opts.BackupOut, opts.BackupOut)
+	return nil
+}
+
+// shredFile 用随机数据覆盖文件并同步到磁盘后删除
+// 在 SSD 和写时复制文件系统（APFS、btrfs）上无法保证旧数据块被覆盖，主要防止文件被直接恢复
+func shredFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := io.CopyN(f, rand.Reader, fi.Size()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
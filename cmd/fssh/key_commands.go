@@ -0,0 +1,386 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fssh/internal/config"
+	"fssh/internal/keypolicy"
+	"fssh/internal/store"
+	usagestats "fssh/internal/usage"
+)
+
+// stringList 可重复指定的命令行参数，也接受逗号分隔
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, store.ParseTags(v)...)
+	return nil
+}
+
+// cmdKey 密钥元数据子命令入口
+func cmdKey() {
+	if len(os.Args) < 3 {
+		keyUsage()
+		os.Exit(2)
+	}
+	switch os.Args[2] {
+	case "set":
+		cmdKeySet()
+	case "show":
+		cmdKeyShow()
+	case "stats":
+		cmdKeyStats()
+	case "audit":
+		cmdKeyAudit()
+	default:
+		keyUsage()
+		os.Exit(2)
+	}
+}
+
+func keyUsage() {
+	fmt.Fprintf(os.Stderr, "usage: fssh key <set|show|stats|audit>\n")
+}
+
+// cmdKeySet 修改密钥的标签、备注、所有者、过期日期和预期主机
+// 元数据保存在记录的明文部分，不解密私钥；agent 依据它决定是否提供密钥，需要解锁 master key 计算 MAC
+func cmdKeySet() {
+	fs := flag.NewFlagSet("key set", flag.ExitOnError)
+	alias := fs.String("alias", "", "alias name")
+	var tags, untags, hosts, unhosts stringList
+	fs.Var(&tags, "tag", "add a tag (repeatable or comma-separated)")
+	fs.Var(&untags, "untag", "remove a tag (repeatable or comma-separated)")
+	clearTags := fs.Bool("clear-tags", false, "remove all tags")
+	notes := fs.String("notes", "", "free-form notes (empty to clear)")
+	owner := fs.String("owner", "", "owner (empty to clear)")
+	expires := fs.String("expires", "", "expiry date YYYY-MM-DD, or never")
+	fs.Var(&hosts, "host", "add an intended host pattern, e.g. *.prod.example.com (repeatable)")
+	fs.Var(&unhosts, "unhost", "remove an intended host pattern (repeatable)")
+	clearHosts := fs.Bool("clear-hosts", false, "remove all intended hosts")
+	fs.Parse(os.Args[3:])
+
+	if *alias == "" {
+		fatal(errors.New("alias is required"))
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if len(set) == 1 {
+		fatal(errors.New("nothing to change; see fssh key set -h"))
+	}
+	exp, err := store.ParseExpiry(*expires)
+	if err != nil {
+		fatal(err)
+	}
+
+	mk, err := unlockMasterKey()
+	if err != nil {
+		fatal(err)
+	}
+	meta, err := store.UpdateMeta(store.Default(), *alias, mk, func(m *store.KeyMeta) error {
+		if *clearTags {
+			m.Tags = nil
+		}
+		m.RemoveTags(untags...)
+		if err := m.AddTags(tags...); err != nil {
+			return err
+		}
+		if *clearHosts {
+			m.Hosts = nil
+		}
+		for _, h := range unhosts {
+			for i := 0; i < len(m.Hosts); i++ {
+				if m.Hosts[i] == h {
+					m.Hosts = append(m.Hosts[:i], m.Hosts[i+1:]...)
+					i--
+				}
+			}
+		}
+		for _, h := range hosts {
+			dup := false
+			for _, existing := range m.Hosts {
+				dup = dup || existing == h
+			}
+			if !dup {
+				m.Hosts = append(m.Hosts, h)
+			}
+		}
+		if set["notes"] {
+			m.Notes = *notes
+		}
+		if set["owner"] {
+			m.Owner = *owner
+		}
+		if set["expires"] {
+			m.Expires = exp
+		}
+		return nil
+	})
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("updated %s\n", *alias)
+	printKeyMeta(meta)
+}
+
+// cmdKeyShow 显示密钥的元数据
+func cmdKeyShow() {
+	fs := flag.NewFlagSet("key show", flag.ExitOnError)
+	alias := fs.String("alias", "", "alias name")
+	fs.Parse(os.Args[3:])
+
+	if *alias == "" {
+		fatal(errors.New("alias is required"))
+	}
+	ef, err := store.Default().Get(*alias)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("alias:       %s\n", ef.Alias)
+	fmt.Printf("fingerprint: %s\n", ef.Fingerprint)
+	fmt.Printf("created:     %s\n", ef.CreatedAt)
+	if ef.Comment != "" {
+		fmt.Printf("comment:     %s\n", ef.Comment)
+	}
+	printKeyMeta(ef.Meta)
+}
+
+func printKeyMeta(m *store.KeyMeta) {
+	if m.IsZero() {
+		return
+	}
+	if len(m.Tags) > 0 {
+		fmt.Printf("tags:        %s\n", strings.Join(m.Tags, ", "))
+	}
+	if m.Owner != "" {
+		fmt.Printf("owner:       %s\n", m.Owner)
+	}
+	if m.Expires != "" {
+		suffix := ""
+		if m.Expired(time.Now()) {
+			suffix = " (expired)"
+		}
+		fmt.Printf("expires:     %s%s\n", m.Expires, suffix)
+	}
+	if len(m.Hosts) > 0 {
+		fmt.Printf("hosts:       %s\n", strings.Join(m.Hosts, ", "))
+	}
+	if m.Notes != "" {
+		fmt.Printf("notes:       %s\n", m.Notes)
+	}
+}
+
+// keyMetaSummary 列表中附加的一行元数据摘要
+func keyMetaSummary(m *store.KeyMeta) string {
+	if m.IsZero() {
+		return ""
+	}
+	var parts []string
+	if len(m.Tags) > 0 {
+		parts = append(parts, "tags="+strings.Join(m.Tags, ","))
+	}
+	if m.Owner != "" {
+		parts = append(parts, "owner="+m.Owner)
+	}
+	if m.Expires != "" {
+		e := "expires=" + m.Expires
+		if m.Expired(time.Now()) {
+			e += "(expired)"
+		}
+		parts = append(parts, e)
+	}
+	if len(m.Hosts) > 0 {
+		parts = append(parts, "hosts="+strings.Join(m.Hosts, ","))
+	}
+	return " " + strings.Join(parts, " ")
+}
+
+// cmdKeyStats 显示 agent 记录的密钥使用统计
+// agent 每分钟批量写入一次，最近的签名可能尚未计入
+func cmdKeyStats() {
+	fs := flag.NewFlagSet("key stats", flag.ExitOnError)
+	alias := fs.String("alias", "", "only this key")
+	sortBy := fs.String("sort", "last-used", "sort order: last-used, count or alias")
+	fs.Parse(os.Args[3:])
+
+	files, err := store.Default().List()
+	if err != nil {
+		fatal(err)
+	}
+	if *alias != "" {
+		var found []store.EncryptedFile
+		for _, ef := range files {
+			if ef.Alias == *alias {
+				found = append(found, ef)
+			}
+		}
+		if len(found) == 0 {
+			fatal(fmt.Errorf("%w: %s", store.ErrNotFound, *alias))
+		}
+		files = found
+	}
+	stats, err := usagestats.Load()
+	if err != nil {
+		fatal(err)
+	}
+
+	switch *sortBy {
+	case "last-used":
+		sort.SliceStable(files, func(i, j int) bool {
+			return stats.Get(files[i].Fingerprint).LastUsedTime().After(stats.Get(files[j].Fingerprint).LastUsedTime())
+		})
+	case "count":
+		sort.SliceStable(files, func(i, j int) bool {
+			return useCount(stats.Get(files[i].Fingerprint)) > useCount(stats.Get(files[j].Fingerprint))
+		})
+	case "alias":
+	default:
+		fatal(errors.New("--sort must be last-used, count or alias"))
+	}
+
+	fmt.Printf("%-20s %8s  %-25s %s\n", "ALIAS", "SIGNS", "LAST USED", "LAST DESTINATION")
+	for _, ef := range files {
+		u := stats.Get(ef.Fingerprint)
+		dest := "-"
+		if u != nil && u.LastDestination != "" {
+			dest = u.LastDestination
+		}
+		fmt.Printf("%-20s %8d  %-25s %s\n", ef.Alias, useCount(u), lastUsedString(u), dest)
+	}
+}
+
+func useCount(u *usagestats.KeyUsage) uint64 {
+	if u == nil {
+		return 0
+	}
+	return u.Count
+}
+
+// lastUsedString 最近使用时间及距今天数
+func lastUsedString(u *usagestats.KeyUsage) string {
+	t := u.LastUsedTime()
+	if t.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%s(%dd)", t.Format("2006-01-02"), int(time.Since(t).Hours()/24))
+}
+
+// staleKeys 返回在 cutoff 之后没有使用过的密钥
+// 从未使用的密钥以创建时间为准，避免刚导入的密钥被当作闲置
+func staleKeys(files []store.EncryptedFile, stats *usagestats.File, cutoff time.Time) []store.EncryptedFile {
+	var out []store.EncryptedFile
+	for _, ef := range files {
+		last := stats.Get(ef.Fingerprint).LastUsedTime()
+		if last.IsZero() {
+			last, _ = time.Parse(time.RFC3339, ef.CreatedAt)
+		}
+		if last.Before(cutoff) {
+			out = append(out, ef)
+		}
+	}
+	return out
+}
+
+// parseAge 解析时长，除 time.ParseDuration 的格式外还支持 d（天）和 w（周）
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q (e.g. 90d, 12w, 720h)", s)
+	}
+	return d, nil
+}
+
+// loadKeyPolicy 读取 config.json 中的密钥强度策略
+func loadKeyPolicy() (keypolicy.Policy, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return keypolicy.Policy{}, err
+	}
+	if err := cfg.KeyPolicy.Validate(); err != nil {
+		return keypolicy.Policy{}, err
+	}
+	return cfg.KeyPolicy, nil
+}
+
+// enforceKeyPolicy 检查新导入或生成的密钥是否满足策略
+// allowWeak 时只打印警告，仍然允许保存
+func enforceKeyPolicy(rec *store.Record, allowWeak bool) error {
+	policy, err := loadKeyPolicy()
+	if err != nil {
+		return err
+	}
+	pub, err := rec.PublicKey()
+	if err != nil {
+		return err
+	}
+	info, err := keypolicy.Inspect(pub)
+	if err != nil {
+		return err
+	}
+	if err := policy.Check(info); err != nil {
+		if allowWeak {
+			fmt.Fprintf(os.Stderr, "warning: %v (accepted because of --allow-weak)\n", err)
+			return nil
+		}
+		return fmt.Errorf("%w; use --allow-weak to accept it anyway", err)
+	}
+	return nil
+}
+
+// cmdKeyAudit 列出已有密钥的类型、位数以及是否满足 key_policy
+// 只读取记录中的公钥，不需要解锁；存在不满足策略的密钥时退出码为 1
+func cmdKeyAudit() {
+	fs := flag.NewFlagSet("key audit", flag.ExitOnError)
+	fs.Parse(os.Args[3:])
+
+	policy, err := loadKeyPolicy()
+	if err != nil {
+		fatal(err)
+	}
+	files, err := store.Default().List()
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("policy: %s\n", policy)
+	fmt.Printf("%-20s %-10s %6s  %s\n", "ALIAS", "TYPE", "BITS", "STATUS")
+	violations := 0
+	for i := range files {
+		ef := &files[i]
+		status := "ok"
+		kind, bits := "?", "?"
+		pub, err := ef.PublicKey()
+		if err == nil {
+			var info keypolicy.KeyInfo
+			if info, err = keypolicy.Inspect(pub); err == nil {
+				kind, bits = info.String(), strconv.Itoa(info.Bits)
+				err = policy.Check(info)
+			}
+		}
+		if err != nil {
+			status = strings.TrimPrefix(err.Error(), keypolicy.ErrViolation.Error()+": ")
+			violations++
+		}
+		fmt.Printf("%-20s %-10s %6s  %s\n", ef.Alias, kind, bits, status)
+	}
+	if violations > 0 {
+		fmt.Printf("\n%d of %d keys do not satisfy the policy\n", violations, len(files))
+		os.Exit(1)
+	}
+}
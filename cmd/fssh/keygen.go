@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"fssh/internal/otp"
+	"fssh/internal/store"
+)
+
+// cmdKeygen 在内存中生成新密钥并直接加密保存到密钥库
+// 私钥明文不落盘，只输出公钥（可选写入 .pub 文件）
+func cmdKeygen() {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	keyType := fs.String("type", store.KeyTypeEd25519, "key type: "+strings.Join(store.KeyTypes(), ", "))
+	alias := fs.String("alias", "", "alias name")
+	comment := fs.String("comment", "", "public key comment (default user@host)")
+	pubOut := fs.String("pub-out", "", "also write the public key to this path (e.g. ~/.ssh/id_work.pub)")
+	force := fs.Bool("force", false, "overwrite an existing alias or .pub file")
+	allowWeak := fs.Bool("allow-weak", false, "generate even if the key type does not satisfy key_policy")
+	fs.Parse(os.Args[2:])
+
+	if *alias == "" {
+		fatal(errors.New("alias is required"))
+	}
+	b := store.Default()
+	if !*force {
+		if _, err := b.Get(*alias); err == nil {
+			fatal(fmt.Errorf("alias exists: %s (use --force to replace it)", *alias))
+		}
+		if *pubOut != "" {
+			if _, err := os.Stat(*pubOut); err == nil {
+				fatal(fmt.Errorf("output exists: %s", *pubOut))
+			}
+		}
+	}
+	if *comment == "" {
+		*comment = otp.DefaultAccount()
+	}
+
+	// 1. 先生成密钥（RSA 可能需要数秒），再解锁 master key
+	rec, err := store.GenerateRecord(*alias, *keyType, *comment)
+	if err != nil {
+		fatal(err)
+	}
+	if err := enforceKeyPolicy(rec, *allowWeak); err != nil {
+		fatal(err)
+	}
+	pub, err := rec.PublicKey()
+	if err != nil {
+		fatal(err)
+	}
+
+	// 2. 加密保存
+	mk, err := unlockMasterKey()
+	if err != nil {
+		fatal(err)
+	}
+	if err := store.SaveRecord(b, rec, mk); err != nil {
+		fatal(err)
+	}
+
+	// 3. 输出公钥
+	line := store.AuthorizedKey(pub, *comment)
+	if *pubOut != "" {
+		if err := os.WriteFile(*pubOut, []byte(line+"\n"), 0644); err != nil {
+			fatal(err)
+		}
+	}
+	fmt.Printf("generated %s (%s) fingerprint=%s\n", rec.Alias, *keyType, rec.Fingerprint)
+	if *pubOut != "" {
+		fmt.Printf("public key written to %s\n", *pubOut)
+	}
+	fmt.Println(line)
+}
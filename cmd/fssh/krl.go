@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"fssh/internal/ca"
+	"fssh/internal/fsutil"
+	"fssh/internal/krl"
+	"fssh/internal/store"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// cmdKRL 密钥吊销列表子命令入口
+func cmdKRL() {
+	if len(os.Args) < 3 {
+		krlUsage()
+		os.Exit(2)
+	}
+	switch os.Args[2] {
+	case "add":
+		cmdKRLAdd()
+	case "list":
+		cmdKRLList()
+	case "build":
+		cmdKRLBuild()
+	case "check":
+		cmdKRLCheck()
+	default:
+		krlUsage()
+		os.Exit(2)
+	}
+}
+
+func krlUsage() {
+	fmt.Fprintf(os.Stderr, "usage: fssh krl <add|list|build|check>\n")
+}
+
+// cmdKRLAdd 向吊销数据库添加证书序列号、证书 key ID 或公钥
+// 只修改数据库，需要再运行 fssh krl build 生成 KRL 文件并分发到服务器
+func cmdKRLAdd() {
+	fs := flag.NewFlagSet("krl add", flag.ExitOnError)
+	caArg := fs.String("ca", "", "CA key (alias, public key file or string) for --serial and --id")
+	serials := fs.String("serial", "", "certificate serial numbers to revoke, e.g. 5, 5-9 or 1,3,7-9 (requires --ca)")
+	var ids, keys rawList
+	fs.Var(&ids, "id", "certificate key ID to revoke; without --ca it is revoked for every CA (repeatable)")
+	fs.Var(&keys, "key", "key to revoke: alias, public key file, certificate file or SHA256 fingerprint (repeatable)")
+	reason := fs.String("reason", "", "reason recorded in the revocation database")
+	fs.Parse(os.Args[3:])
+
+	if *serials == "" && len(ids) == 0 && len(keys) == 0 {
+		fatal(errors.New("at least one of --serial, --id or --key is required"))
+	}
+	var caKey ssh.PublicKey
+	caLine := ""
+	if *caArg != "" {
+		pk, err := resolvePublicKey(*caArg)
+		if err != nil {
+			fatal(err)
+		}
+		caKey, caLine = pk, krl.FormatKey(pk)
+	}
+
+	var revs []*krl.Revocation
+	if *serials != "" {
+		if caKey == nil {
+			fatal(errors.New("--serial requires --ca"))
+		}
+		ranges, err := parseSerials(*serials)
+		if err != nil {
+			fatal(err)
+		}
+		for _, r := range ranges {
+			revs = append(revs, &krl.Revocation{Kind: krl.KindSerial, CA: caLine, SerialMin: r.Min, SerialMax: r.Max, Label: issuedKeyIDs(caKey, r)})
+		}
+	}
+	for _, id := range ids {
+		revs = append(revs, &krl.Revocation{Kind: krl.KindKeyID, CA: caLine, KeyID: id})
+	}
+	for _, k := range keys {
+		rev, err := keyRevocation(k)
+		if err != nil {
+			fatal(err)
+		}
+		revs = append(revs, rev)
+	}
+
+	err := krl.Update(func(db *krl.Database) error {
+		for _, r := range revs {
+			r.Reason = *reason
+			added, err := db.Add(r)
+			if err != nil {
+				return err
+			}
+			label := ""
+			if r.Label != "" {
+				label = " (" + r.Label + ")"
+			}
+			if added {
+				fmt.Printf("✓ revoked %s%s\n", r, label)
+			} else {
+				fmt.Printf("- %s%s is already revoked\n", r, label)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println("run fssh krl build --out <file> to regenerate the KRL")
+}
+
+// keyRevocation 把 --key 参数转换为吊销记录：证书按 CA 和序列号吊销，指纹和公钥直接吊销
+func keyRevocation(arg string) (*krl.Revocation, error) {
+	if strings.HasPrefix(arg, "SHA256:") {
+		return &krl.Revocation{Kind: krl.KindFingerprint, Fingerprint: arg}, nil
+	}
+	pk, err := resolvePublicKey(arg)
+	if err != nil {
+		return nil, err
+	}
+	if cert, ok := pk.(*ssh.Certificate); ok {
+		if cert.Serial == 0 {
+			return nil, fmt.Errorf("%s has serial 0 and cannot be revoked by serial; use --id %q --ca <ca>", arg, cert.KeyId)
+		}
+		return &krl.Revocation{Kind: krl.KindSerial, CA: krl.FormatKey(cert.SignatureKey), SerialMin: cert.Serial, SerialMax: cert.Serial, Label: cert.KeyId}, nil
+	}
+	label := ""
+	if _, err := store.Default().Get(arg); err == nil {
+		label = arg
+	}
+	return &krl.Revocation{Kind: krl.KindKey, Key: krl.FormatKey(pk), Label: label}, nil
+}
+
+// parseSerials 解析 5、5-9、1,3,7-9 形式的序列号列表
+func parseSerials(s string) ([]krl.SerialRange, error) {
+	var out []krl.SerialRange
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		min, err := strconv.ParseUint(lo, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid serial %q", part)
+		}
+		max := min
+		if isRange {
+			if max, err = strconv.ParseUint(hi, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid serial %q", part)
+			}
+		}
+		if min == 0 || min > max {
+			return nil, fmt.Errorf("invalid serial range %q (serial 0 cannot be revoked)", part)
+		}
+		out = append(out, krl.SerialRange{Min: min, Max: max})
+	}
+	return out, nil
+}
+
+// issuedKeyIDs 从签发日志中找出区间内证书的 key ID，便于确认吊销对象
+func issuedKeyIDs(caKey ssh.PublicKey, r krl.SerialRange) string {
+	issued, err := ca.LoadLog()
+	if err != nil {
+		return ""
+	}
+	caFP := ssh.FingerprintSHA256(caKey)
+	var ids []string
+	for _, is := range issued {
+		if is.CAFingerprint == caFP && is.Serial >= r.Min && is.Serial <= r.Max {
+			ids = append(ids, is.KeyID)
+		}
+	}
+	return strings.Join(ids, ", ")
+}
+
+// cmdKRLList 列出吊销数据库
+func cmdKRLList() {
+	db, err := krl.Load()
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("%-28s %-20s %-25s %s\n", "REVOKED", "CA", "ADDED", "LABEL / REASON")
+	for _, r := range db.Revocations {
+		caName := "-"
+		if r.CA != "" {
+			if pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(r.CA)); err == nil {
+				caName = ssh.FingerprintSHA256(pk)[:19]
+			}
+		} else if r.Kind == krl.KindKeyID {
+			caName = "any"
+		}
+		note := r.Label
+		if r.Reason != "" {
+			if note != "" {
+				note += " / "
+			}
+			note += r.Reason
+		}
+		fmt.Printf("%-28s %-20s %-25s %s\n", r.String(), caName, r.Added, note)
+	}
+	fmt.Printf("\n%d revocation(s), last built KRL version %d, database: %s\n", len(db.Revocations), db.KRLVersion, krl.Path())
+}
+
+// cmdKRLBuild 由吊销数据库生成 OpenSSH 二进制 KRL，供 sshd_config 的 RevokedKeys 使用
+func cmdKRLBuild() {
+	fs := flag.NewFlagSet("krl build", flag.ExitOnError)
+	out := fs.String("out", "", "output path (e.g. revoked.krl)")
+	comment := fs.String("comment", "", "comment stored in the KRL")
+	fs.Parse(os.Args[3:])
+
+	if *out == "" {
+		fatal(errors.New("out is required"))
+	}
+	var version uint64
+	var count int
+	err := krl.Update(func(db *krl.Database) error {
+		db.KRLVersion++
+		k, err := db.KRL(db.KRLVersion, *comment)
+		if err != nil {
+			return err
+		}
+		data, err := k.Marshal()
+		if err != nil {
+			return err
+		}
+		version, count = db.KRLVersion, len(db.Revocations)
+		return fsutil.WriteFileAtomic(*out, data, 0644)
+	})
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("wrote %s: KRL version %d with %d revocation(s)\n", *out, version, count)
+	fmt.Println("deploy it to your servers and set RevokedKeys in sshd_config")
+}
+
+// cmdKRLCheck 检查公钥或证书是否被吊销；默认检查吊销数据库，--krl 检查已生成的 KRL 文件
+// 有被吊销的密钥时退出码为 1
+func cmdKRLCheck() {
+	fs := flag.NewFlagSet("krl check", flag.ExitOnError)
+	krlFile := fs.String("krl", "", "check against this KRL file instead of the revocation database")
+	fs.Parse(os.Args[3:])
+
+	if fs.NArg() == 0 {
+		fatal(errors.New("usage: fssh krl check [--krl file] <pubkey|cert|alias>..."))
+	}
+	var k *krl.KRL
+	if *krlFile != "" {
+		data, err := os.ReadFile(*krlFile)
+		if err != nil {
+			fatal(err)
+		}
+		if k, err = krl.Parse(data); err != nil {
+			fatal(err)
+		}
+	} else {
+		db, err := krl.Load()
+		if err != nil {
+			fatal(err)
+		}
+		if k, err = db.KRL(db.KRLVersion, ""); err != nil {
+			fatal(err)
+		}
+	}
+
+	revoked := 0
+	for _, arg := range fs.Args() {
+		pk, err := resolvePublicKey(arg)
+		if err != nil {
+			fatal(err)
+		}
+		if reason := k.Check(pk); reason != "" {
+			fmt.Printf("%s: REVOKED (%s)\n", arg, reason)
+			revoked++
+		} else {
+			fmt.Printf("%s: ok\n", arg)
+		}
+	}
+	if revoked > 0 {
+		os.Exit(1)
+	}
+}
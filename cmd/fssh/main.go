@@ -2,25 +2,36 @@ package main
 
 import (
     "bufio"
-    "crypto/rand"
-    "encoding/json"
     "errors"
     "flag"
     "fmt"
     "io"
     "os"
-    "path/filepath"
     "strings"
+    "time"
 
+    "fssh/internal/auth"
     "fssh/internal/store"
     "fssh/internal/keychain"
     "fssh/internal/config"
     "fssh/internal/log"
+    "fssh/internal/profile"
+    usagestats "fssh/internal/usage"
     agentserver "fssh/internal/agent"
     "golang.org/x/term"
 )
 
 func main() {
+    args, err := applyProfileFlag(os.Args)
+    if err != nil {
+        fatal(err)
+    }
+    os.Args = args
+    if len(os.Args) < 2 || os.Args[1] != "profile" {
+        if err := checkProfile(); err != nil {
+            fatal(err)
+        }
+    }
     if len(os.Args) < 2 {
         runShell()
         return
@@ -31,24 +42,56 @@ func main() {
         cmdInit()
     case "import":
         cmdImport()
+    case "keygen":
+        cmdKeygen()
     case "list":
         cmdList()
     case "export":
         cmdExport()
+    case "share":
+        cmdShare()
+    case "encrypt":
+        cmdEncrypt()
+    case "decrypt":
+        cmdDecrypt()
+    case "ca":
+        cmdCA()
+    case "krl":
+        cmdKRL()
+    case "pubkey":
+        cmdPubkey()
     case "status":
         cmdStatus()
+    case "doctor":
+        cmdDoctor()
     case "agent":
         cmdAgent()
     case "remove":
         cmdRemove()
     case "rekey":
         cmdRekey()
+    case "backup":
+        cmdBackup()
+    case "restore":
+        cmdRestore()
+    case "escrow":
+        cmdEscrow()
+    case "key", "keys":
+        cmdKey()
     case "shell":
         runShell()
     case "sshd-align":
         cmdAlignSSHD()
     case "config-gen":
         cmdConfigGen()
+    case "otp":
+        cmdOTP()
+    case "switch-mode":
+        cmdSwitchMode()
+    case "store":
+        cmdStore()
+    case "profile":
+        cmdProfile()
     default:
         usage()
         os.Exit(2)
@@ -56,16 +99,18 @@ func main() {
 }
 
 func usage() {
-    fmt.Fprintf(os.Stderr, "usage: fssh <init|import|list|export|remove|rekey|status|agent|shell|sshd-align|config-gen>\n")
+    fmt.Fprintf(os.Stderr, "usage: fssh [--profile name] <init|import|keygen|key|list|export|share|encrypt|decrypt|ca|krl|pubkey|remove|rekey|backup|restore|escrow|status|doctor|agent|shell|sshd-align|config-gen|otp|switch-mode|store|profile>\n")
 }
 
 func cmdInit() {
     fs := flag.NewFlagSet("init", flag.ExitOnError)
     force := fs.Bool("force", false, "recreate master key if exists")
-    mode := fs.String("mode", "", "authentication mode: touchid or otp (empty = interactive prompt)")
+    mode := fs.String("mode", "", "authentication mode: touchid, otp or passphrase (empty = interactive prompt)")
     seedTTL := fs.Int("seed-unlock-ttl", 3600, "OTP seed cache time (seconds), OTP mode only")
-    algorithm := fs.String("algorithm", "SHA1", "TOTP algorithm: SHA1, SHA256, SHA512, OTP mode only")
-    digits := fs.Int("digits", 6, "TOTP digits: 6 or 8, OTP mode only")
+    otpType := fs.String("otp-type", "totp", "OTP type: totp or hotp, OTP mode only")
+    algorithm := fs.String("algorithm", "SHA1", "OTP algorithm: SHA1, SHA256, SHA512, OTP mode only")
+    digits := fs.Int("digits", 6, "OTP digits: 6 or 8, OTP mode only")
+    skew := fs.Int("otp-skew", 1, "allowed TOTP clock skew in periods (1-5), OTP mode only")
     interactive := fs.Bool("interactive", false, "run full setup wizard")
     nonInteractive := fs.Bool("non-interactive", false, "disable all interactive prompts")
     fs.Parse(os.Args[2:])
@@ -75,14 +120,14 @@ func cmdInit() {
     shouldRunInteractive := *interactive || (isTTY && *mode == "" && !*nonInteractive)
 
     if shouldRunInteractive {
-        runInteractiveSetup(*force, *seedTTL, *algorithm, *digits)
+        runInteractiveSetup(*force, *seedTTL, *otpType, *algorithm, *digits, *skew)
     } else {
-        runLegacyInit(*force, *mode, *seedTTL, *algorithm, *digits)
+        runLegacyInit(*force, *mode, *seedTTL, *otpType, *algorithm, *digits, *skew)
     }
 }
 
 // runLegacyInit executes the original non-interactive initialization
-func runLegacyInit(force bool, mode string, seedTTL int, algorithm string, digits int) {
+func runLegacyInit(force bool, mode string, seedTTL int, otpType string, algorithm string, digits int, skew int) {
     // Default to touchid if mode not specified
     if mode == "" {
         mode = "touchid"
@@ -93,23 +138,54 @@ func runLegacyInit(force bool, mode string, seedTTL int, algorithm string, digit
     case "touchid":
         initTouchIDMode(force)
     case "otp":
-        initOTPMode(force, seedTTL, algorithm, digits)
+        initOTPMode(force, seedTTL, otpType, algorithm, digits, skew)
+    case "passphrase":
+        initPassphraseMode(force)
     default:
-        fatal(fmt.Errorf("不支持的认证模式: %s (支持 touchid 或 otp)", mode))
+        fatal(fmt.Errorf("不支持的认证模式: %s (支持 touchid、otp 或 passphrase)", mode))
     }
 }
 
 func cmdImport() {
     fs := flag.NewFlagSet("import", flag.ExitOnError)
     alias := fs.String("alias", "", "alias name")
-    file := fs.String("file", "", "path to private key file")
+    file := fs.String("file", "", "path to private key file (OpenSSH/PEM, PuTTY .ppk v2/v3 or PKCS#12 .p12/.pfx)")
     pass := fs.String("passphrase", "", "DEPRECATED: passphrase in CLI may leak; prefer --ask-passphrase or --passphrase-file or --passphrase-stdin")
     ask := fs.Bool("ask-passphrase", false, "read passphrase securely from TTY")
     passFile := fs.String("passphrase-file", "", "read passphrase from file path")
     passStdin := fs.Bool("passphrase-stdin", false, "read passphrase from stdin")
     comment := fs.String("comment", "", "optional comment")
+    allowWeak := fs.Bool("allow-weak", false, "import even if the key does not satisfy key_policy")
+    shared := fs.String("shared", "", "import a key shared with fssh share, decrypted with the matching key in this store")
+    scan := fs.String("scan", "", "import every private key found in this directory (e.g. ~/.ssh)")
+    originals := fs.String("originals", originalsKeep, "with --scan, what to do with verified plaintext originals: keep, backup or shred")
+    backupOut := fs.String("backup-out", "", "with --originals backup, encrypted backup file to write")
+    backupPassFile := fs.String("backup-passphrase-file", "", "with --originals backup, read the backup passphrase from file path")
+    yes := fs.Bool("yes", false, "with --scan, accept the suggested selection and aliases without prompting")
     fs.Parse(os.Args[2:])
 
+    if *shared != "" {
+        if *file != "" || *scan != "" {
+            fatal(errors.New("--shared cannot be combined with --file or --scan"))
+        }
+        cmdImportShared(*shared, *alias, *allowWeak)
+        return
+    }
+    if *scan != "" {
+        if *alias != "" || *file != "" {
+            fatal(errors.New("--scan cannot be combined with --alias or --file"))
+        }
+        cmdImportScan(scanImportOptions{
+            Dir:            *scan,
+            Originals:      *originals,
+            BackupOut:      *backupOut,
+            BackupPassFile: *backupPassFile,
+            Yes:            *yes,
+            AllowWeak:      *allowWeak,
+        })
+        return
+    }
+
     if *alias == "" || *file == "" {
         fatal(errors.New("alias and file are required"))
     }
@@ -129,9 +205,12 @@ func cmdImport() {
     if err != nil {
         fatal(err)
     }
+    if err := enforceKeyPolicy(rec, *allowWeak); err != nil {
+        fatal(err)
+    }
 
     // 4. 私钥验证成功后，再要求 Touch ID 获取 master key
-    mk, err := keychain.LoadMasterKey()
+    mk, err := unlockMasterKey()
     if err != nil {
         fatal(err)
     }
@@ -144,29 +223,48 @@ func cmdImport() {
 }
 
 func cmdList() {
-    dir := store.KeysDir()
-    entries, err := os.ReadDir(dir)
-    if err != nil && !os.IsNotExist(err) {
+    fs := flag.NewFlagSet("list", flag.ExitOnError)
+    tag := fs.String("tag", "", "only keys with all of these tags (comma-separated)")
+    owner := fs.String("owner", "", "only keys with this owner")
+    host := fs.String("host", "", "only keys intended for this host")
+    hideExpired := fs.Bool("hide-expired", false, "hide expired keys")
+    stale := fs.String("stale", "", "only keys not used for this long, e.g. 90d (never-used keys count from their creation)")
+    fs.Parse(os.Args[2:])
+    var staleAge time.Duration
+    if *stale != "" {
+        d, err := parseAge(*stale)
+        if err != nil {
+            fatal(err)
+        }
+        staleAge = d
+    }
+    files, err := store.Default().List()
+    if err != nil {
         fatal(err)
     }
-    if len(entries) == 0 {
+    if len(files) == 0 {
         fmt.Println("no keys imported")
         return
     }
-    for _, e := range entries {
-        if e.IsDir() || !strings.HasSuffix(e.Name(), ".enc") {
-            continue
-        }
-        p := filepath.Join(dir, e.Name())
-        data, err := os.ReadFile(p)
-        if err != nil {
+    filter := store.Filter{Tags: store.ParseTags(*tag), Owner: *owner, Host: *host, IncludeExpired: !*hideExpired}
+    files = filter.Apply(files, time.Now())
+    var stats *usagestats.File
+    if staleAge > 0 {
+        if stats, err = usagestats.Load(); err != nil {
             fatal(err)
         }
-        var m store.EncryptedFile
-        if err := json.Unmarshal(data, &m); err != nil {
-            fatal(err)
+        files = staleKeys(files, stats, time.Now().Add(-staleAge))
+    }
+    if len(files) == 0 {
+        fmt.Println("no keys match")
+        return
+    }
+    for _, m := range files {
+        extra := keyMetaSummary(m.Meta)
+        if stats != nil {
+            extra += " last_used=" + lastUsedString(stats.Get(m.Fingerprint))
         }
-        fmt.Printf("alias=%s fingerprint=%s created=%s\n", m.Alias, m.Fingerprint, m.CreatedAt)
+        fmt.Printf("alias=%s fingerprint=%s created=%s%s\n", m.Alias, m.Fingerprint, m.CreatedAt, extra)
     }
 }
 
@@ -174,6 +272,7 @@ func cmdExport() {
     fs := flag.NewFlagSet("export", flag.ExitOnError)
     alias := fs.String("alias", "", "alias name")
     out := fs.String("out", "", "output path")
+    format := fs.String("format", store.ExportOpenSSH, "output format: openssh, pkcs8 (unencrypted only), pem (unencrypted RSA/ECDSA)")
     pass := fs.String("passphrase", "", "DEPRECATED: passphrase in CLI may leak; prefer --ask-passphrase or --passphrase-file or --passphrase-stdin")
     ask := fs.Bool("ask-passphrase", false, "read passphrase securely from TTY")
     passFile := fs.String("passphrase-file", "", "read passphrase from file path")
@@ -183,12 +282,16 @@ func cmdExport() {
     if *alias == "" || *out == "" {
         fatal(errors.New("alias and out are required"))
     }
+    formatNames := map[string]string{store.ExportOpenSSH: "OpenSSH", store.ExportPKCS8: "PKCS#8 PEM", store.ExportPEM: "PEM"}
+    if _, ok := formatNames[*format]; !ok {
+        fatal(fmt.Errorf("unsupported format %q (supported: openssh, pkcs8, pem)", *format))
+    }
     if !*force {
         if _, err := os.Stat(*out); err == nil {
             fatal(fmt.Errorf("output exists: %s", *out))
         }
     }
-    mk, err := keychain.LoadMasterKey()
+    mk, err := unlockMasterKey()
     if err != nil {
         fatal(err)
     }
@@ -196,16 +299,16 @@ func cmdExport() {
     if err != nil {
         fatal(err)
     }
-    p, err := resolvePassphrase(*pass, *ask, *passFile, *passStdin, "Export PEM passphrase (optional, press Enter for none): ")
+    p, err := resolvePassphrase(*pass, *ask, *passFile, *passStdin, "Export passphrase (optional, press Enter for none): ")
     if err != nil { fatal(err) }
-    pemBytes, err := store.ExportPKCS8PEM(rec, p)
+    pemBytes, err := store.ExportPrivateKey(rec, *format, p)
     if err != nil {
         fatal(err)
     }
     if err := os.WriteFile(*out, pemBytes, 0600); err != nil {
         fatal(err)
     }
-    fmt.Printf("exported %s to %s (PKCS#8 PEM)%s\n", rec.Alias, *out, func() string { if p != "" { return " with passphrase" } ; return "" }())
+    fmt.Printf("exported %s to %s (%s)%s\n", rec.Alias, *out, formatNames[*format], func() string { if p != "" { return " with passphrase" } ; return "" }())
 }
 
 func cmdStatus() {
@@ -213,10 +316,12 @@ func cmdStatus() {
     if err != nil {
         fatal(err)
     }
+    fmt.Printf("profile=%s\n", profile.Current())
     fmt.Printf("master_key=%v\n", exists)
-    dir := store.KeysDir()
-    _, err = os.Stat(dir)
-    fmt.Printf("store_dir=%s exists=%v\n", dir, err == nil)
+    if b, ok := store.Default().(store.Relocatable); ok {
+        _, err = os.Stat(b.Root())
+        fmt.Printf("store_dir=%s exists=%v\n", b.Root(), err == nil)
+    }
 }
 
 func cmdAgent() {
@@ -225,9 +330,11 @@ func cmdAgent() {
     sock := fs.String("socket", cfg.Socket, "unix socket path for SSH agent")
     require := fs.Bool("require-touch-id-per-sign", cfg.RequireTouchPerSign, "require Touch ID on every signature")
     ttl := fs.Int("unlock-ttl-seconds", cfg.UnlockTTLSeconds, "Touch ID unlock TTL in seconds (secure mode)")
+    tag := fs.String("tag", strings.Join(cfg.AgentTags, ","), "only offer keys with all of these tags (comma-separated)")
+    rejectSHA1 := fs.Bool("reject-sha1", cfg.AgentRejectSHA1, "refuse ssh-rsa (SHA-1) signatures when the client does not request rsa-sha2")
     fs.Parse(os.Args[2:])
     log.Init(cfg)
-    err := agentserver.StartWithOptions(*sock, *require, *ttl)
+    err := agentserver.StartWithOptions(*sock, *require, *ttl, store.Filter{Tags: store.ParseTags(*tag)}, *rejectSHA1)
     if err != nil {
         fatal(err)
     }
@@ -238,31 +345,26 @@ func cmdRemove() {
     alias := fs.String("alias", "", "alias name")
     fs.Parse(os.Args[2:])
     if *alias == "" { fatal(errors.New("alias is required")) }
-    if _, err := keychain.LoadMasterKey(); err != nil { fatal(err) }
-    path := filepath.Join(store.KeysDir(), *alias+".enc")
-    if err := os.Remove(path); err != nil { fatal(err) }
+    if _, err := unlockMasterKey(); err != nil { fatal(err) }
+    if err := store.Default().Delete(*alias); err != nil { fatal(err) }
     fmt.Printf("removed %s\n", *alias)
 }
 
-func cmdRekey() {
-    fs := flag.NewFlagSet("rekey", flag.ExitOnError)
-    fs.Parse(os.Args[2:])
-    old, err := keychain.LoadMasterKey()
-    if err != nil { fatal(err) }
-    newk := make([]byte, 32)
-    if _, err := io.ReadFull(rand.Reader, newk); err != nil { fatal(err) }
-    dir := store.KeysDir()
-    entries, err := os.ReadDir(dir)
-    if err != nil && !os.IsNotExist(err) { fatal(err) }
-    for _, e := range entries {
-        if e.IsDir() || !strings.HasSuffix(e.Name(), ".enc") { continue }
-        alias := strings.TrimSuffix(e.Name(), ".enc")
-        rec, err := store.LoadDecryptedRecord(alias, old)
-        if err != nil { fatal(err) }
-        if err := store.SaveEncryptedRecord(rec, newk); err != nil { fatal(err) }
-    }
-    if err := keychain.StoreMasterKey(newk, true); err != nil { fatal(err) }
-    fmt.Println("rekeyed master key and re-encrypted all records")
+// unlockMasterKey 为命令行操作解锁 master key
+// Touch ID 和 OTP 模式使用 Keychain 中的 master key（OTP 初始化时写入），口令模式提示输入口令
+func unlockMasterKey() ([]byte, error) {
+    mode, err := auth.LoadMode()
+    if err != nil {
+        return nil, err
+    }
+    if mode == auth.ModePassphrase {
+        provider, err := auth.GetAuthProvider(0)
+        if err != nil {
+            return nil, err
+        }
+        return provider.UnlockMasterKey()
+    }
+    return keychain.LoadMasterKey()
 }
 
 func fatal(err error) {
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"fssh/internal/crypt"
+	"fssh/internal/otp"
+)
+
+// cmdOTP OTP 管理子命令入口
+func cmdOTP() {
+	if len(os.Args) < 3 {
+		otpUsage()
+		os.Exit(2)
+	}
+	switch os.Args[2] {
+	case "show-enrollment":
+		cmdOTPShowEnrollment()
+	case "recover":
+		cmdOTPRecover()
+	case "passwd":
+		cmdOTPPasswd()
+	case "recovery-codes":
+		cmdOTPRecoveryCodes()
+	case "kdf-bench":
+		cmdOTPKDFBench()
+	default:
+		otpUsage()
+		os.Exit(2)
+	}
+}
+
+func otpUsage() {
+	fmt.Fprintf(os.Stderr, "usage: fssh otp <show-enrollment|recover|passwd|recovery-codes|kdf-bench>\n")
+}
+
+// loadOTPConfig 加载 OTP 配置，未初始化时给出提示
+func loadOTPConfig() *otp.Config {
+	if !otp.ConfigExists() {
+		fatal(errors.New("OTP 未配置，请运行: fssh init --mode otp"))
+	}
+	cfg, err := otp.LoadConfig(otp.ConfigPath())
+	if err != nil {
+		fatal(err)
+	}
+	return cfg
+}
+
+// cmdOTPShowEnrollment 重新显示认证器注册信息（需要 OTP 密码）
+func cmdOTPShowEnrollment() {
+	fs := flag.NewFlagSet("otp show-enrollment", flag.ExitOnError)
+	fs.Parse(os.Args[3:])
+
+	cfg := loadOTPConfig()
+
+	password, err := otp.PromptPassword("请输入 OTP 密码: ")
+	if err != nil {
+		fatal(err)
+	}
+	seed, err := otp.DecryptSeed(cfg, password)
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Println()
+	fmt.Println("⚠️  以下信息可用于生成验证码，请勿截屏或分享")
+	fmt.Println()
+	if err := otp.DisplayEnrollment(seed, cfg); err != nil {
+		fatal(err)
+	}
+}
+
+// cmdOTPRecover 使用恢复码找回 OTP seed，并强制设置新密码
+// 恢复码只能使用一次，成功后在配置中标记为已使用
+func cmdOTPRecover() {
+	fs := flag.NewFlagSet("otp recover", flag.ExitOnError)
+	fs.Parse(os.Args[3:])
+
+	cfg := loadOTPConfig()
+	if otp.RemainingRecoveryCodes(cfg) == 0 {
+		fatal(errors.New("没有可用的恢复码"))
+	}
+
+	fmt.Println("使用恢复码恢复 OTP 访问")
+	fmt.Println()
+
+	code, err := otp.PromptInput("请输入恢复码 (XXXX-XXXX-XXXX-XXXX): ")
+	if err != nil {
+		fatal(err)
+	}
+	code = otp.NormalizeRecoveryCode(code)
+
+	seed, idx, err := otp.RecoverSeed(cfg, code)
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Println("✓ 恢复码验证成功，必须设置新的 OTP 密码")
+	fmt.Println()
+
+	password, err := otp.PromptPasswordWithConfirm(
+		"请设置新的 OTP 密码（至少12位）: ",
+		"确认密码: ",
+	)
+	if err != nil {
+		fatal(err)
+	}
+	if err := otp.ValidatePasswordStrength(password); err != nil {
+		fatal(fmt.Errorf("密码强度不足: %w", err))
+	}
+
+	var remaining int
+	err = otp.UpdateConfig(func(c *otp.Config) error {
+		// 重新检查，防止恢复码在此期间已被使用
+		if ok, i := otp.VerifyRecoveryCode(code, c.RecoveryCodesHash); !ok || i != idx {
+			return errors.New("恢复码已失效")
+		}
+		if err := otp.SealSeed(c, seed, password); err != nil {
+			return err
+		}
+		otp.MarkRecoveryCodeUsed(c, idx)
+		remaining = otp.RemainingRecoveryCodes(c)
+		return nil
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Println()
+	fmt.Println("✓ 已设置新的 OTP 密码，所用恢复码已失效")
+	fmt.Printf("剩余恢复码: %d\n", remaining)
+	fmt.Println()
+	fmt.Println("如果认证器也已丢失，请运行: fssh otp show-enrollment")
+}
+
+// cmdOTPPasswd 修改 OTP 密码
+// 用旧密码解密 seed，再用新密码（新的 salt 和 nonce）重新加密
+// seed 不变，因此 master key 和已导入的密钥不受影响
+func cmdOTPPasswd() {
+	fs := flag.NewFlagSet("otp passwd", flag.ExitOnError)
+	fs.Parse(os.Args[3:])
+
+	cfg := loadOTPConfig()
+
+	oldPassword, err := otp.PromptPassword("请输入当前 OTP 密码: ")
+	if err != nil {
+		fatal(err)
+	}
+	seed, err := otp.DecryptSeed(cfg, oldPassword)
+	if err != nil {
+		fatal(err)
+	}
+
+	newPassword, err := otp.PromptPasswordWithConfirm(
+		"请设置新的 OTP 密码（至少12位）: ",
+		"确认密码: ",
+	)
+	if err != nil {
+		fatal(err)
+	}
+	if err := otp.ValidatePasswordStrength(newPassword); err != nil {
+		fatal(fmt.Errorf("密码强度不足: %w", err))
+	}
+
+	err = otp.UpdateConfig(func(c *otp.Config) error {
+		// 确认加密的 seed 在此期间没有被其他进程修改
+		if c.EncryptedSeed != cfg.EncryptedSeed {
+			return errors.New("OTP 配置已被修改，请重试")
+		}
+		return otp.SealSeed(c, seed, newPassword)
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Println("✓ OTP 密码已修改")
+	fmt.Println("  正在运行的 agent 需要重启后才会使用新密码")
+}
+
+// cmdOTPRecoveryCodes 查看恢复码状态，或使用 --regenerate 重新生成
+// 重新生成会使所有旧恢复码失效
+func cmdOTPRecoveryCodes() {
+	fs := flag.NewFlagSet("otp recovery-codes", flag.ExitOnError)
+	regenerate := fs.Bool("regenerate", false, "generate a new set of recovery codes (invalidates old ones)")
+	count := fs.Int("count", 10, "number of recovery codes to generate")
+	fs.Parse(os.Args[3:])
+
+	cfg := loadOTPConfig()
+
+	if !*regenerate {
+		fmt.Printf("剩余恢复码: %d/%d\n", otp.RemainingRecoveryCodes(cfg), len(cfg.RecoveryCodesHash))
+		if len(cfg.RecoveryCodesHash) > 0 && len(cfg.RecoverySeeds) == 0 {
+			fmt.Println("⚠️  当前恢复码由旧版本生成，无法用于恢复访问，建议重新生成")
+		}
+		fmt.Println("重新生成: fssh otp recovery-codes --regenerate")
+		return
+	}
+
+	if *count < 1 || *count > 20 {
+		fatal(errors.New("count must be between 1 and 20"))
+	}
+
+	password, err := otp.PromptPassword("请输入 OTP 密码: ")
+	if err != nil {
+		fatal(err)
+	}
+	seed, err := otp.DecryptSeed(cfg, password)
+	if err != nil {
+		fatal(err)
+	}
+
+	codes, err := otp.GenerateRecoveryCodes(*count)
+	if err != nil {
+		fatal(err)
+	}
+
+	err = otp.UpdateConfig(func(c *otp.Config) error {
+		if c.EncryptedSeed != cfg.EncryptedSeed {
+			return errors.New("OTP 配置已被修改，请重试")
+		}
+		return otp.SetRecoveryCodes(c, seed, codes)
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Println()
+	fmt.Println("✓ 已重新生成恢复码，旧恢复码全部失效")
+	fmt.Println()
+	otp.DisplayRecoveryCodes(codes)
+}
+
+// cmdOTPKDFBench 在本机上测量 KDF 耗时，选择约为目标耗时的参数
+// 使用 --apply 时用选出的参数重新加密 OTP seed（需要 OTP 密码）
+func cmdOTPKDFBench() {
+	fs := flag.NewFlagSet("otp kdf-bench", flag.ExitOnError)
+	algorithm := fs.String("algorithm", crypt.KDFArgon2id, "kdf algorithm: argon2id or scrypt")
+	target := fs.Duration("target", 500*time.Millisecond, "target derivation time")
+	apply := fs.Bool("apply", false, "re-encrypt the OTP seed with the selected parameters")
+	fs.Parse(os.Args[3:])
+
+	if *target <= 0 {
+		fatal(errors.New("target must be positive"))
+	}
+
+	fmt.Printf("正在测量 %s（目标 %s）...\n", *algorithm, *target)
+	params, elapsed, err := crypt.BenchmarkKDF(*algorithm, *target)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("推荐参数: %s（本机耗时 %s）\n", params, elapsed.Round(time.Millisecond))
+
+	if !*apply {
+		fmt.Println("应用到 OTP 配置: fssh otp kdf-bench --apply")
+		return
+	}
+
+	cfg := loadOTPConfig()
+	fmt.Printf("当前参数: %s\n", cfg.SeedKDF())
+
+	password, err := otp.PromptPassword("请输入 OTP 密码: ")
+	if err != nil {
+		fatal(err)
+	}
+	seed, err := otp.DecryptSeed(cfg, password)
+	if err != nil {
+		fatal(err)
+	}
+
+	err = otp.UpdateConfig(func(c *otp.Config) error {
+		if c.EncryptedSeed != cfg.EncryptedSeed {
+			return errors.New("OTP 配置已被修改，请重试")
+		}
+		return otp.SealSeedWithKDF(c, seed, password, params)
+	})
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println("✓ 已使用新的 KDF 参数重新加密 OTP seed")
+}
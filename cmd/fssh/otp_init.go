@@ -13,7 +13,7 @@ import (
 )
 
 // initOTPMode 初始化 OTP 认证模式
-func initOTPMode(force bool, seedTTL int, algorithm string, digits int) {
+func initOTPMode(force bool, seedTTL int, otpType string, algorithm string, digits int, skew int) {
 	// 检查是否已存在 OTP 配置
 	if otp.ConfigExists() && !force {
 		fmt.Println("OTP 配置已存在，使用 --force 覆盖")
@@ -36,9 +36,11 @@ func initOTPMode(force bool, seedTTL int, algorithm string, digits int) {
 	opts := &otp.InitOptions{
 		Password:         password,
 		SeedUnlockTTL:    seedTTL,
+		Type:             otpType,
 		Algorithm:        algorithm,
 		Digits:           digits,
 		Period:           30,
+		SkewSteps:        skew,
 		GenerateRecovery: true,
 	}
 
@@ -60,7 +62,11 @@ func initOTPMode(force bool, seedTTL int, algorithm string, digits int) {
 	}
 
 	// 显示结果
-	if err := otp.DisplayInitResult(seed, recoveryCodes, algorithm, digits, 30); err != nil {
+	cfg, err := otp.LoadConfig(otp.ConfigPath())
+	if err != nil {
+		fatal(err)
+	}
+	if err := otp.DisplayInitResult(seed, recoveryCodes, cfg); err != nil {
 		fatal(err)
 	}
 
@@ -127,6 +133,52 @@ func initTouchIDMode(force bool) {
 	fmt.Println("✓ 已成功初始化 master key (Touch ID 保护)")
 }
 
+// initPassphraseMode 初始化口令认证模式
+// master key 由口令加密保存，不依赖 Touch ID 或 Keychain
+func initPassphraseMode(force bool) {
+	if auth.PassphraseConfigExists() && !force {
+		fmt.Println("口令配置已存在，使用 --force 覆盖")
+		return
+	}
+
+	fmt.Println("初始化口令认证模式")
+	fmt.Println()
+
+	passphrase, err := promptNewPassphrase()
+	if err != nil {
+		fatal(err)
+	}
+
+	mk := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, mk); err != nil {
+		fatal(err)
+	}
+	if err := auth.InitPassphrase(mk, passphrase); err != nil {
+		fatal(err)
+	}
+
+	if err := auth.SaveMode(auth.ModePassphrase); err != nil {
+		fatal(fmt.Errorf("保存认证模式失败: %w", err))
+	}
+
+	fmt.Println("✓ 已成功初始化 master key (口令保护)")
+}
+
+// promptNewPassphrase 提示设置主口令并校验强度
+func promptNewPassphrase() (string, error) {
+	passphrase, err := otp.PromptPasswordWithConfirm(
+		"请设置主口令（至少12位）: ",
+		"确认口令: ",
+	)
+	if err != nil {
+		return "", err
+	}
+	if err := otp.ValidatePasswordStrength(passphrase); err != nil {
+		return "", fmt.Errorf("口令强度不足: %w", err)
+	}
+	return passphrase, nil
+}
+
 // deriveMasterKeyFromSeed 从 OTP seed 派生 master key
 // 使用与 OTPProvider 相同的方法，确保一致性
 func deriveMasterKeyFromSeed(seed []byte, opts *otp.InitOptions) ([]byte, error) {
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"fssh/internal/keychain"
+	"fssh/internal/profile"
+)
+
+// applyProfileFlag 处理子命令之前的全局 --profile 参数，返回去掉该参数后的参数列表
+// 支持 --profile name、--profile=name 以及单横线形式
+func applyProfileFlag(args []string) ([]string, error) {
+	out := []string{args[0]}
+	i := 1
+	for i < len(args) {
+		a := args[i]
+		var name string
+		switch {
+		case a == "--profile" || a == "-profile":
+			if i+1 >= len(args) {
+				return nil, errors.New("--profile requires a name")
+			}
+			name = args[i+1]
+			i += 2
+		case strings.HasPrefix(a, "--profile=") || strings.HasPrefix(a, "-profile="):
+			name = a[strings.Index(a, "=")+1:]
+			i++
+		default:
+			// 第一个非全局参数即子命令，其后的参数原样保留
+			return append(out, args[i:]...), nil
+		}
+		if err := profile.Select(name); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// checkProfile 确认当前 profile 合法且已创建
+func checkProfile() error {
+	name := profile.Current()
+	if err := profile.Validate(name); err != nil {
+		return err
+	}
+	if !profile.Exists(name) {
+		return fmt.Errorf("profile %s 不存在，请先运行: fssh profile create %s", name, name)
+	}
+	return nil
+}
+
+// cmdProfile profile 管理子命令入口
+func cmdProfile() {
+	if len(os.Args) < 3 {
+		profileUsage()
+		os.Exit(2)
+	}
+	switch os.Args[2] {
+	case "list":
+		cmdProfileList()
+	case "create":
+		cmdProfileCreate()
+	case "delete":
+		cmdProfileDelete()
+	default:
+		profileUsage()
+		os.Exit(2)
+	}
+}
+
+func profileUsage() {
+	fmt.Fprintf(os.Stderr, "usage: fssh profile <list|create <name>|delete <name> [--force]>\n")
+}
+
+func cmdProfileList() {
+	names, err := profile.List()
+	if err != nil {
+		fatal(err)
+	}
+	current := profile.Current()
+	for _, name := range names {
+		marker := " "
+		if name == current {
+			marker = "*"
+		}
+		fmt.Printf("%s %-16s %s\n", marker, name, profile.DirFor(name))
+	}
+}
+
+// parseProfileArgs 解析 "<name> [flags]" 或 "[flags] <name>" 形式的参数
+func parseProfileArgs(fs *flag.FlagSet) string {
+	fs.Parse(os.Args[3:])
+	if fs.NArg() < 1 {
+		profileUsage()
+		os.Exit(2)
+	}
+	name := fs.Arg(0)
+	fs.Parse(fs.Args()[1:])
+	return name
+}
+
+func cmdProfileCreate() {
+	fs := flag.NewFlagSet("profile create", flag.ExitOnError)
+	name := parseProfileArgs(fs)
+	if err := profile.Create(name); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("已创建 profile %s: %s\n", name, profile.DirFor(name))
+	fmt.Println()
+	fmt.Println("下一步:")
+	fmt.Printf("  fssh --profile %s init\n", name)
+	fmt.Printf("  或 export %s=%s\n", profile.EnvVar, name)
+}
+
+func cmdProfileDelete() {
+	fs := flag.NewFlagSet("profile delete", flag.ExitOnError)
+	force := fs.Bool("force", false, "delete without confirmation")
+	name := parseProfileArgs(fs)
+	if name == profile.DefaultName {
+		fatal(errors.New("不能删除 default profile"))
+	}
+	if !profile.Exists(name) {
+		fatal(fmt.Errorf("profile %s 不存在", name))
+	}
+
+	if !*force {
+		fmt.Printf("将删除 profile %s 的所有密钥和配置: %s\n", name, profile.DirFor(name))
+		fmt.Print("输入 profile 名称确认: ")
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(line) != name {
+			fmt.Println("已取消")
+			return
+		}
+	}
+
+	if err := keychain.DeleteProfileMasterKey(name); err != nil {
+		fatal(err)
+	}
+	if err := profile.Delete(name); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("已删除 profile %s\n", name)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"fssh/internal/store"
+)
+
+// cmdPubkey 输出密钥的公钥或指纹
+// 只读取记录中的公钥元数据，不需要解锁 master key
+func cmdPubkey() {
+	fs := flag.NewFlagSet("pubkey", flag.ExitOnError)
+	alias := fs.String("alias", "", "alias name")
+	format := fs.String("format", store.PubAuthorizedKeys, "output format: authorized_keys, pem, fingerprint-md5, fingerprint-sha256")
+	fs.Parse(os.Args[2:])
+
+	if *alias == "" {
+		fatal(errors.New("alias is required"))
+	}
+	ef, err := store.Default().Get(*alias)
+	if err != nil {
+		fatal(err)
+	}
+	pub, err := ef.PublicKey()
+	if err != nil {
+		fatal(fmt.Errorf("%s: %w", *alias, err))
+	}
+	comment := ef.Comment
+	if comment == "" {
+		comment = ef.Alias
+	}
+	out, err := store.FormatPublicKey(pub, *format, comment)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(out)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"fssh/internal/auth"
+	"fssh/internal/crypt"
+	"fssh/internal/keychain"
+	"fssh/internal/otp"
+	"fssh/internal/store"
+)
+
+// rekey 日志中与认证模式相关的参数
+const (
+	rekeyParamMode             = "mode"
+	rekeyParamOTPMasterKeySalt = "otp_master_key_salt"
+	rekeyParamOTPOldSalt       = "otp_old_master_key_salt" // 撤销时恢复
+	rekeyParamPassphraseConfig = "passphrase_config"
+)
+
+// cmdRekey 更换 master key 并重新加密所有记录
+// 顺序：写入日志（含由旧 key 加密的新 key）→ 暂存重新加密的记录 → 替换密钥目录 → 认证方式保存新 key → 清理
+// 任何一步中断后都可以用 --resume 继续或 --rollback 撤销
+func cmdRekey() {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	resume := fs.Bool("resume", false, "finish an interrupted rekey")
+	rollback := fs.Bool("rollback", false, "undo an interrupted rekey")
+	fs.Parse(os.Args[2:])
+
+	if *resume && *rollback {
+		fatal(errors.New("--resume and --rollback are mutually exclusive"))
+	}
+	mode, err := auth.LoadMode()
+	if err != nil {
+		fatal(err)
+	}
+
+	switch {
+	case *resume:
+		r, cur := openRekey(mode)
+		finishRekey(r, mode, cur)
+	case *rollback:
+		r, _ := openRekey(mode)
+		if r.AuthUpdated() {
+			fatal(store.ErrRekeyCommitted)
+		}
+		// 先恢复认证配置再删除日志，失败时可以再次运行 --rollback
+		if err := restoreRekeyAuth(mode, r); err != nil {
+			fatal(err)
+		}
+		if err := r.Rollback(); err != nil {
+			fatal(err)
+		}
+		fmt.Println("已撤销 rekey，密钥库恢复为原 master key")
+	default:
+		if store.RekeyInProgress() {
+			fatal(store.ErrRekeyInProgress)
+		}
+		oldKey, newKey, params, err := prepareRekey(mode)
+		if err != nil {
+			fatal(err)
+		}
+		r, err := store.StartRekey(oldKey, newKey, params)
+		if err != nil {
+			fatal(err)
+		}
+		finishRekey(r, mode, oldKey)
+	}
+}
+
+// openRekey 用当前认证方式解锁并读取未完成的 rekey
+func openRekey(mode auth.AuthMode) (*store.Rekey, []byte) {
+	if !store.RekeyInProgress() {
+		fatal(errors.New("没有未完成的 rekey"))
+	}
+	cur, err := unlockMasterKey()
+	if err != nil {
+		fatal(err)
+	}
+	r, err := store.OpenRekey(cur)
+	if err != nil {
+		fatal(err)
+	}
+	if m := r.Journal.Params[rekeyParamMode]; m != string(mode) {
+		fatal(fmt.Errorf("rekey 开始于 %s 模式，当前为 %s 模式", m, mode))
+	}
+	return r, cur
+}
+
+// prepareRekey 解锁旧 master key 并生成新 master key
+// 新 key 在各认证模式下的保存方式所需的数据放入日志参数，恢复时无需再次输入密码
+func prepareRekey(mode auth.AuthMode) (oldKey, newKey []byte, params map[string]string, err error) {
+	params = map[string]string{rekeyParamMode: string(mode)}
+
+	switch mode {
+	case auth.ModeOTP:
+		// OTP 模式的 master key 由 seed 派生，更换 master key salt 即可得到新 key
+		oldKey, err = keychain.LoadMasterKey()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		cfg := loadOTPConfig()
+		password, err := otp.PromptPassword("请输入 OTP 密码: ")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		seed, err := otp.DecryptSeed(cfg, password)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		salt := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, nil, nil, err
+		}
+		newKey = crypt.HKDF(seed, salt, []byte("fssh-master-key-v1"), 32)
+		params[rekeyParamOTPMasterKeySalt] = base64.StdEncoding.EncodeToString(salt)
+		params[rekeyParamOTPOldSalt] = cfg.MasterKeySalt
+
+	case auth.ModePassphrase:
+		passphrase, err := otp.PromptPassword("请输入主口令: ")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		oldKey, err = auth.UnwrapWithPassphrase(passphrase)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		newKey, err = randomMasterKey()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		sealed, err := auth.SealPassphraseConfig(newKey, passphrase)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		params[rekeyParamPassphraseConfig] = base64.StdEncoding.EncodeToString(sealed)
+
+	default:
+		oldKey, err = keychain.LoadMasterKey()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		newKey, err = randomMasterKey()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return oldKey, newKey, params, nil
+}
+
+// finishRekey 从日志记录的位置继续执行 rekey 直到完成
+func finishRekey(r *store.Rekey, mode auth.AuthMode, oldKey []byte) {
+	hint := "（运行 fssh rekey --resume 继续或 fssh rekey --rollback 撤销）"
+	if !r.AuthUpdated() {
+		n, err := r.Stage(oldKey)
+		if err != nil {
+			fatal(fmt.Errorf("重新加密失败: %w%s", err, hint))
+		}
+		if n > 0 {
+			fmt.Printf("已重新加密 %d 个记录\n", n)
+		}
+		if err := r.Swap(); err != nil {
+			fatal(fmt.Errorf("替换密钥目录失败: %w%s", err, hint))
+		}
+		if err := persistRekeyKey(mode, r); err != nil {
+			fatal(fmt.Errorf("保存新 master key 失败: %w%s", err, hint))
+		}
+		if err := r.MarkCommitted(); err != nil {
+			fatal(fmt.Errorf("更新 rekey 日志失败: %w（运行 fssh rekey --resume 完成）", err))
+		}
+	}
+	kept, err := r.Finish()
+	if err != nil {
+		fatal(err)
+	}
+	if kept != "" {
+		fmt.Fprintf(os.Stderr, "警告: 旧密钥目录中有未重新加密的记录文件，已保留在 %s\n", kept)
+	}
+	fmt.Println("rekeyed master key and re-encrypted all records")
+	fmt.Println("如果 agent 正在运行，请重启 agent")
+}
+
+// persistRekeyKey 让当前认证方式保存新 master key，可重复执行
+// Keychain 中的 key 原地更新，失败时旧 key 仍在，--resume 和 --rollback 都能解锁
+func persistRekeyKey(mode auth.AuthMode, r *store.Rekey) error {
+	switch mode {
+	case auth.ModeOTP:
+		salt := r.Journal.Params[rekeyParamOTPMasterKeySalt]
+		if salt == "" {
+			return errors.New("rekey 日志缺少 OTP master key salt")
+		}
+		// 先更新 Keychain：之后写 salt 失败时 Keychain 已是新 key，--resume 会重写 salt
+		if err := keychain.ReplaceMasterKey(r.NewKey()); err != nil {
+			return err
+		}
+		return otp.UpdateConfig(func(cfg *otp.Config) error {
+			cfg.MasterKeySalt = salt
+			return nil
+		})
+
+	case auth.ModePassphrase:
+		sealed, err := base64.StdEncoding.DecodeString(r.Journal.Params[rekeyParamPassphraseConfig])
+		if err != nil || len(sealed) == 0 {
+			return errors.New("rekey 日志缺少口令配置")
+		}
+		return auth.WritePassphraseConfig(sealed)
+
+	default:
+		return keychain.ReplaceMasterKey(r.NewKey())
+	}
+}
+
+// restoreRekeyAuth 撤销时恢复认证方式的配置
+// OTP 模式下 master key 由 seed 和 salt 派生，salt 可能已写入新值，需要改回旧值
+func restoreRekeyAuth(mode auth.AuthMode, r *store.Rekey) error {
+	if mode != auth.ModeOTP {
+		return nil
+	}
+	old := r.Journal.Params[rekeyParamOTPOldSalt]
+	if old == "" {
+		// 旧版本的日志没有记录旧 salt
+		if cfg := loadOTPConfig(); cfg.MasterKeySalt == r.Journal.Params[rekeyParamOTPMasterKeySalt] {
+			return errors.New("OTP 配置已使用新 salt，但 rekey 日志中没有旧 salt，无法撤销，请运行 fssh rekey --resume 完成")
+		}
+		return nil
+	}
+	return otp.UpdateConfig(func(cfg *otp.Config) error {
+		cfg.MasterKeySalt = old
+		return nil
+	})
+}
+
+// randomMasterKey 生成随机 master key
+func randomMasterKey() ([]byte, error) {
+	mk := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, mk); err != nil {
+		return nil, err
+	}
+	return mk, nil
+}
@@ -3,16 +3,14 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"runtime"
 
-	"fssh/internal/config"
 	"fssh/internal/keychain"
 	"fssh/internal/otp"
 )
 
 // runInteractiveSetup orchestrates the complete interactive setup wizard
-func runInteractiveSetup(force bool, seedTTL int, algorithm string, digits int) {
+func runInteractiveSetup(force bool, seedTTL int, otpType string, algorithm string, digits int, skew int) {
 	printWelcome()
 
 	// Step 1: Check if already initialized
@@ -32,7 +30,7 @@ func runInteractiveSetup(force bool, seedTTL int, algorithm string, digits int)
 	if authMode == "touchid" {
 		initTouchIDMode(force)
 	} else {
-		initOTPMode(force, seedTTL, algorithm, digits)
+		initOTPMode(force, seedTTL, otpType, algorithm, digits, skew)
 	}
 
 	// Step 4: Binary installation
@@ -172,14 +170,7 @@ func printStepHeader(step, total int, title string) {
 
 // printSetupComplete prints the completion message
 func printSetupComplete() {
-	// Get socket path
-	home, _ := os.UserHomeDir()
-	socketPath := filepath.Join(home, ".fssh", "agent.sock")
-
-	// Try to load config to get actual socket path
-	if cfg, err := config.Load(); err == nil && cfg.Socket != "" {
-		socketPath = cfg.Socket
-	}
+	socketPath := agentSocketPath()
 
 	fmt.Println()
 	fmt.Println("╔══════════════════════════════════════════════════════════╗")
@@ -4,18 +4,14 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"path/filepath"
 	"time"
+
+	"fssh/internal/config"
 )
 
 // startAgent starts the fssh agent and verifies it's running
 func startAgent() error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	socketPath := filepath.Join(home, ".fssh", "agent.sock")
+	socketPath := agentSocketPath()
 
 	// Check if agent is already running
 	if _, err := os.Stat(socketPath); err == nil {
@@ -57,3 +53,12 @@ func startAgent() error {
 	fmt.Println()
 	return fmt.Errorf("agent did not start within 10 seconds")
 }
+
+// agentSocketPath returns the agent socket of the current profile,
+// honouring the socket option in its config.json
+func agentSocketPath() string {
+	if cfg, err := config.Load(); err == nil && cfg.Socket != "" {
+		return cfg.Socket
+	}
+	return config.DefaultSocket()
+}
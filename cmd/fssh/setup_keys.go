@@ -7,7 +7,6 @@ import (
 	"strconv"
 	"strings"
 
-	"fssh/internal/keychain"
 	"fssh/internal/otp"
 	"fssh/internal/store"
 	"golang.org/x/crypto/ssh"
@@ -19,6 +18,7 @@ type SSHKeyInfo struct {
 	Filename    string
 	IsEncrypted bool
 	Alias       string // Suggested alias
+	Fingerprint string // Empty if the key is encrypted and has no readable public key
 }
 
 // importSSHKeys scans ~/.ssh/ and interactively imports discovered keys
@@ -43,6 +43,25 @@ func importSSHKeys() error {
 		return nil
 	}
 
+	// Skip keys that are already in the store
+	byFingerprint, taken, err := existingKeys(store.Default())
+	if err != nil {
+		return err
+	}
+	var fresh []*SSHKeyInfo
+	for _, key := range keys {
+		if alias, ok := byFingerprint[key.Fingerprint]; ok && key.Fingerprint != "" {
+			fmt.Printf("  - %s is already imported as '%s'\n", key.Filename, alias)
+			continue
+		}
+		fresh = append(fresh, key)
+	}
+	keys = fresh
+	if len(keys) == 0 {
+		fmt.Println("All SSH private keys in ~/.ssh/ are already imported")
+		return nil
+	}
+
 	// Display found keys
 	fmt.Printf("Found %d SSH private key(s):\n", len(keys))
 	fmt.Println()
@@ -105,7 +124,7 @@ func importSSHKeys() error {
 	}
 
 	// Load master key
-	mk, err := keychain.LoadMasterKey()
+	mk, err := unlockMasterKey()
 	if err != nil {
 		return fmt.Errorf("failed to load master key: %w", err)
 	}
@@ -119,7 +138,7 @@ func importSSHKeys() error {
 		fmt.Printf("[%d/%d] Importing %s...\n", i+1, len(keysToImport), key.Filename)
 
 		// Prompt for alias with suggestion
-		suggestedAlias := generateAlias(key.Filename)
+		suggestedAlias := uniqueAlias(generateAlias(key.Filename), taken)
 		aliasPrompt := fmt.Sprintf("  Alias [%s]: ", suggestedAlias)
 		alias, err := otp.PromptInput(aliasPrompt)
 		if err != nil {
@@ -130,6 +149,10 @@ func importSSHKeys() error {
 		if alias == "" {
 			alias = suggestedAlias
 		}
+		if taken[alias] {
+			fmt.Printf("  ❌ Alias '%s' is already in use\n", alias)
+			continue
+		}
 
 		// Read key file
 		keyData, err := os.ReadFile(key.Path)
@@ -154,6 +177,10 @@ func importSSHKeys() error {
 			fmt.Printf("  ❌ Failed to parse key: %v\n", err)
 			continue
 		}
+		if err := enforceKeyPolicy(rec, false); err != nil {
+			fmt.Printf("  ❌ Rejected: %v\n", err)
+			continue
+		}
 
 		// Save encrypted record
 		if err := store.SaveEncryptedRecord(rec, mk); err != nil {
@@ -162,6 +189,7 @@ func importSSHKeys() error {
 		}
 
 		fmt.Printf("  ✓ Imported as '%s' (fingerprint: %s)\n", rec.Alias, rec.Fingerprint)
+		taken[rec.Alias] = true
 		successCount++
 	}
 
@@ -210,7 +238,6 @@ func scanSSHDirectory(sshDir string) ([]*SSHKeyInfo, error) {
 
 		// Skip known non-key files
 		if strings.HasSuffix(filename, ".pub") ||
-			strings.HasSuffix(filename, ".ppk") ||
 			filename == "config" ||
 			filename == "known_hosts" ||
 			filename == "authorized_keys" {
@@ -226,8 +253,9 @@ func scanSSHDirectory(sshDir string) ([]*SSHKeyInfo, error) {
 			}
 		}
 
-		// Also check files without extensions (potential custom keys)
-		if !isStandardKey && !strings.Contains(filename, ".") {
+		// Also check files without extensions (potential custom keys) and
+		// PuTTY / PKCS#12 key files
+		if !isStandardKey && (!strings.Contains(filename, ".") || keyFileExtension(filename) != "") {
 			isStandardKey = true
 		}
 
@@ -255,30 +283,80 @@ func analyzeKeyFile(path string) *SSHKeyInfo {
 	}
 
 	// Try to parse as private key
-	_, err = ssh.ParseRawPrivateKey(data)
+	key, err := store.ParsePrivateKeyBytes(data, "")
 
 	info := &SSHKeyInfo{
 		Path:     path,
 		Filename: filepath.Base(path),
 	}
 
+	var pub ssh.PublicKey
 	if err != nil {
 		// Check if it's an encrypted key
-		if _, ok := err.(*ssh.PassphraseMissingError); ok {
-			info.IsEncrypted = true
-		} else {
+		missing, ok := err.(*ssh.PassphraseMissingError)
+		if !ok {
 			// Not a valid private key
 			return nil
 		}
+		info.IsEncrypted = true
+		pub = missing.PublicKey
+	} else if signer, err := ssh.NewSignerFromKey(key); err == nil {
+		pub = signer.PublicKey()
+	}
+
+	// Encrypted PEM and PKCS#12 files don't expose the public key; fall back to the .pub file
+	if pub == nil {
+		if pubData, err := os.ReadFile(path + ".pub"); err == nil {
+			pub, _, _, _, _ = ssh.ParseAuthorizedKey(pubData)
+		}
+	}
+	if pub != nil {
+		info.Fingerprint = ssh.FingerprintSHA256(pub)
 	}
 
 	return info
 }
 
+// keyFileExtension returns the extension of PuTTY and PKCS#12 key files, or ""
+func keyFileExtension(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch ext {
+	case ".ppk", ".p12", ".pfx":
+		return ext
+	}
+	return ""
+}
+
+// existingKeys returns the aliases in the store indexed by fingerprint, and
+// the set of aliases already taken
+func existingKeys(b store.Backend) (map[string]string, map[string]bool, error) {
+	files, err := b.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	byFingerprint := make(map[string]string, len(files))
+	taken := make(map[string]bool, len(files))
+	for _, ef := range files {
+		byFingerprint[ef.Fingerprint] = ef.Alias
+		taken[ef.Alias] = true
+	}
+	return byFingerprint, taken, nil
+}
+
+// uniqueAlias returns base, base-2, base-3 ... whichever is not taken yet
+func uniqueAlias(base string, taken map[string]bool) string {
+	alias := base
+	for i := 2; taken[alias]; i++ {
+		alias = fmt.Sprintf("%s-%d", base, i)
+	}
+	return alias
+}
+
 // generateAlias generates a suggested alias from the filename
 func generateAlias(filename string) string {
-	// Remove "id_" prefix if present
+	// Remove "id_" prefix and PuTTY / PKCS#12 extensions if present
 	alias := strings.TrimPrefix(filename, "id_")
+	alias = alias[:len(alias)-len(keyFileExtension(alias))]
 
 	// Remove common suffixes
 	alias = strings.TrimSuffix(alias, "_sk")
@@ -5,9 +5,18 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+
+	"fssh/internal/profile"
 )
 
-const plistLabel = "com.fssh.agent"
+// launchAgentLabel returns the LaunchAgent label of the current profile;
+// each profile runs its own agent
+func launchAgentLabel() string {
+	if profile.IsDefault() {
+		return "com.fssh.agent"
+	}
+	return "com.fssh.agent." + profile.Current()
+}
 
 // setupLaunchAgent configures macOS LaunchAgent for auto-start
 func setupLaunchAgent() error {
@@ -17,7 +26,7 @@ func setupLaunchAgent() error {
 	}
 
 	launchAgentsDir := filepath.Join(home, "Library", "LaunchAgents")
-	plistPath := filepath.Join(launchAgentsDir, plistLabel+".plist")
+	plistPath := filepath.Join(launchAgentsDir, launchAgentLabel()+".plist")
 
 	// Check if already exists
 	if _, err := os.Stat(plistPath); err == nil {
@@ -55,25 +64,32 @@ func setupLaunchAgent() error {
 
 // generatePlistContent generates the plist file content
 func generatePlistContent() string {
+	args := "      <string>/usr/local/bin/fssh</string>\n"
+	logPath := "/tmp/fssh-agent.log"
+	if !profile.IsDefault() {
+		args += "      <string>--profile</string>\n"
+		args += "      <string>" + profile.Current() + "</string>\n"
+		logPath = "/tmp/fssh-agent-" + profile.Current() + ".log"
+	}
+	args += "      <string>agent</string>\n"
+
 	return `<?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
 <plist version="1.0">
   <dict>
     <key>Label</key>
-    <string>com.fssh.agent</string>
+    <string>` + launchAgentLabel() + `</string>
     <key>ProgramArguments</key>
     <array>
-      <string>/usr/local/bin/fssh</string>
-      <string>agent</string>
-    </array>
+` + args + `    </array>
     <key>RunAtLoad</key>
     <true/>
     <key>KeepAlive</key>
     <true/>
     <key>StandardOutPath</key>
-    <string>/tmp/fssh-agent.log</string>
+    <string>` + logPath + `</string>
     <key>StandardErrorPath</key>
-    <string>/tmp/fssh-agent.log</string>
+    <string>` + logPath + `</string>
   </dict>
 </plist>
 `
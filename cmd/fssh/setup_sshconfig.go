@@ -18,7 +18,7 @@ func addToSSHConfig() error {
 	}
 
 	sshConfigPath := filepath.Join(home, ".ssh", "config")
-	socketPath := filepath.Join(home, ".fssh", "agent.sock")
+	socketPath := agentSocketPath()
 
 	// Check if config file exists
 	var existingContent []byte
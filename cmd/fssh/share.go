@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"fssh/internal/store"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// cmdShare 把密钥加密给同事的 SSH 公钥（ed25519 或 RSA），替代导出明文私钥
+// 接收方用 fssh import --shared 和自己密钥库中对应的私钥解密导入
+func cmdShare() {
+	fs := flag.NewFlagSet("share", flag.ExitOnError)
+	alias := fs.String("alias", "", "alias of the key to share")
+	to := fs.String("to", "", "recipient's SSH public key file (ed25519 or RSA, authorized_keys format)")
+	out := fs.String("out", "", "output path (e.g. deploy.fsshshare)")
+	force := fs.Bool("force", false, "overwrite output if exists")
+	fs.Parse(os.Args[2:])
+
+	if *alias == "" || *to == "" || *out == "" {
+		fatal(errors.New("alias, to and out are required"))
+	}
+	if !*force {
+		if _, err := os.Stat(*out); err == nil {
+			fatal(fmt.Errorf("output exists: %s", *out))
+		}
+	}
+	pubBytes, err := os.ReadFile(*to)
+	if err != nil {
+		fatal(err)
+	}
+	recipient, comment, _, _, err := ssh.ParseAuthorizedKey(pubBytes)
+	if err != nil {
+		fatal(fmt.Errorf("parse %s: %w", *to, err))
+	}
+
+	mk, err := unlockMasterKey()
+	if err != nil {
+		fatal(err)
+	}
+	rec, err := store.LoadDecryptedRecord(*alias, mk)
+	if err != nil {
+		fatal(err)
+	}
+	if rec.Fingerprint == ssh.FingerprintSHA256(recipient) {
+		fatal(errors.New("the recipient key is the key being shared"))
+	}
+	data, err := store.CreateShare(rec, recipient)
+	if err != nil {
+		fatal(err)
+	}
+	if err := os.WriteFile(*out, data, 0600); err != nil {
+		fatal(err)
+	}
+	who := ssh.FingerprintSHA256(recipient)
+	if comment != "" {
+		who = comment + " " + who
+	}
+	fmt.Printf("shared %s with %s to %s\n", rec.Alias, who, *out)
+	fmt.Printf("the recipient imports it with: fssh import --shared %s\n", *out)
+}
+
+// cmdImportShared 用本地密钥库中接收方的私钥解密分享文件并导入
+func cmdImportShared(path, alias string, allowWeak bool) {
+	if store.RekeyInProgress() {
+		fatal(store.ErrRekeyInProgress)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fatal(err)
+	}
+	share, err := store.ParseShare(data)
+	if err != nil {
+		fatal(err)
+	}
+
+	b := store.Default()
+	byFingerprint, taken, err := existingKeys(b)
+	if err != nil {
+		fatal(err)
+	}
+	identity, ok := byFingerprint[share.Recipient]
+	if !ok {
+		fatal(fmt.Errorf("no key in this store matches the recipient %s (%s)", share.Recipient, share.RecipientType))
+	}
+
+	mk, err := unlockMasterKey()
+	if err != nil {
+		fatal(err)
+	}
+	idRec, err := store.LoadRecord(b, identity, mk)
+	if err != nil {
+		fatal(err)
+	}
+	rec, err := share.Open(idRec)
+	if err != nil {
+		fatal(err)
+	}
+	if existing, ok := byFingerprint[rec.Fingerprint]; ok {
+		fmt.Printf("%s is already imported as %s\n", rec.Alias, existing)
+		return
+	}
+	if alias != "" {
+		rec.Alias = alias
+	}
+	if taken[rec.Alias] {
+		fatal(fmt.Errorf("alias %s is already in use; choose another with --alias", rec.Alias))
+	}
+	if err := enforceKeyPolicy(rec, allowWeak); err != nil {
+		fatal(err)
+	}
+	if err := store.SaveRecord(b, rec, mk); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("imported %s fingerprint=%s (decrypted with %s)\n", rec.Alias, rec.Fingerprint, identity)
+}
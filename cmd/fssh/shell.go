@@ -63,7 +63,7 @@ func runShell() {
         }
     }
 
-    commands := []string{"list", "search", "connect", "add", "edit", "delete", "show", "info", "global", "suspend", "help", "exit", "quit"}
+    commands := []string{"list", "search", "connect", "add", "edit", "delete", "show", "info", "keys", "global", "suspend", "help", "exit", "quit"}
     l := setupLiner(commands, ctx.hosts, ctx.hostnames, ctx.ips, ctx.ids)
     ctx.liner = l
     defer func() {
@@ -105,6 +105,7 @@ func runShell() {
             fmt.Println("  delete <host>     - Delete host")
             fmt.Println("  show <host>       - Show host details")
             fmt.Println("  info <id|alias|hostname|ip> - Show host info by any identifier")
+            fmt.Println("  keys [tag ...]    - List imported keys and their metadata, filtered by tags")
             fmt.Println("  global [show|edit|set|unset] - Manage global SSH config (Host *)")
             fmt.Println("    global show           - Display current global config")
             fmt.Println("    global edit           - Edit global config interactively")
@@ -151,6 +152,12 @@ func runShell() {
             }
             continue
         }
+        if line == "keys" || strings.HasPrefix(line, "keys ") {
+            if err := cmdKeys(strings.TrimSpace(line[4:])); err != nil {
+                fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            }
+            continue
+        }
         if strings.HasPrefix(line, "global") {
             args := ""
             if len(line) > 6 {
@@ -2,9 +2,8 @@ package main
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"fssh/internal/sshconfig"
 	"fssh/internal/store"
@@ -127,12 +126,14 @@ func cmdAdd(ctx *ShellContext) error {
 			for _, key := range ctx.importedKeys {
 				fmt.Printf("  - %s\n", key)
 			}
+			if intended := keysIntendedFor(cfg.Hostname); len(intended) > 0 {
+				fmt.Printf("\nKeys intended for %s: %s\n", cfg.Hostname, strings.Join(intended, ", "))
+			}
 			fmt.Println("\nNote: All imported keys are available via the agent")
 		}
 
 		// Always set IdentityAgent for fssh usage
-		home, _ := os.UserHomeDir()
-		cfg.IdentityAgent = filepath.Join(home, ".fssh", "agent.sock")
+		cfg.IdentityAgent = agentSocketPath()
 
 	case "2":
 		line, _ := ctx.liner.Prompt("IdentityFile path: ")
@@ -299,8 +300,7 @@ func cmdEdit(ctx *ShellContext, args string) error {
 
 		switch strings.TrimSpace(choice) {
 		case "1":
-			home, _ := os.UserHomeDir()
-			cfg.IdentityAgent = filepath.Join(home, ".fssh", "agent.sock")
+			cfg.IdentityAgent = agentSocketPath()
 			cfg.IdentityFile = nil
 		case "2":
 			idFile, _ := ctx.liner.Prompt("IdentityFile path: ")
@@ -557,27 +557,55 @@ func resolveHostQuery(ctx *ShellContext, query string) string {
 	return ""
 }
 
+// cmdKeys lists imported keys with their metadata, optionally filtered by tags
+func cmdKeys(args string) error {
+	files, err := store.Default().List()
+	if err != nil {
+		return err
+	}
+	filter := store.Filter{Tags: strings.FieldsFunc(args, func(r rune) bool { return r == ' ' || r == ',' }), IncludeExpired: true}
+	files = filter.Apply(files, time.Now())
+	if len(files) == 0 {
+		fmt.Println("No matching keys")
+		return nil
+	}
+	for _, ef := range files {
+		fmt.Printf("%s\t%s%s\n", ef.Alias, ef.Fingerprint, keyMetaSummary(ef.Meta))
+	}
+	return nil
+}
+
+// keysIntendedFor returns aliases of unexpired keys whose intended hosts match hostname
+// Keys without intended hosts are not listed, since they apply everywhere
+func keysIntendedFor(hostname string) []string {
+	files, err := store.Default().List()
+	if err != nil {
+		return nil
+	}
+	var aliases []string
+	for _, ef := range files {
+		if ef.Meta == nil || len(ef.Meta.Hosts) == 0 {
+			continue
+		}
+		if ef.Meta.MatchesHost(hostname) && !ef.Meta.Expired(time.Now()) {
+			aliases = append(aliases, ef.Alias)
+		}
+	}
+	return aliases
+}
+
 // --- Helper functions ---
 
 // listImportedKeys lists all imported private keys
 func listImportedKeys() ([]string, error) {
-	dir := store.KeysDir()
-	entries, err := os.ReadDir(dir)
+	files, err := store.Default().List()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []string{}, nil
-		}
 		return nil, err
 	}
 
-	var keys []string
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".enc") {
-			continue
-		}
-		// Remove .enc suffix to get alias
-		alias := strings.TrimSuffix(e.Name(), ".enc")
-		keys = append(keys, alias)
+	keys := make([]string, 0, len(files))
+	for _, ef := range files {
+		keys = append(keys, ef.Alias)
 	}
 
 	return keys, nil
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"fssh/internal/store"
+)
+
+// cmdStore 密钥库管理子命令入口
+func cmdStore() {
+	if len(os.Args) < 3 {
+		storeUsage()
+		os.Exit(2)
+	}
+	switch os.Args[2] {
+	case "migrate":
+		cmdStoreMigrate()
+	default:
+		storeUsage()
+		os.Exit(2)
+	}
+}
+
+func storeUsage() {
+	fmt.Fprintf(os.Stderr, "usage: fssh store <migrate>\n")
+}
+
+// cmdStoreMigrate 将 fingerpass/v1 记录升级为元数据受认证的 fssh/v2 格式，并为旧记录的标签、过期时间等补上 MAC
+func cmdStoreMigrate() {
+	b := store.Default()
+	files, err := b.List()
+	if err != nil {
+		fatal(err)
+	}
+	var pending []string
+	for _, ef := range files {
+		if store.NeedsMigration(&ef) {
+			pending = append(pending, ef.Alias)
+		}
+	}
+	if len(pending) == 0 {
+		fmt.Println("所有记录均已是 " + store.VersionV2 + " 格式，元数据均已认证")
+		return
+	}
+
+	mk, err := unlockMasterKey()
+	if err != nil {
+		fatal(err)
+	}
+	failed := 0
+	for _, alias := range pending {
+		if _, err := store.MigrateRecord(b, alias, mk); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", alias, err)
+			failed++
+			continue
+		}
+		fmt.Printf("✓ %s\n", alias)
+	}
+	fmt.Printf("已升级 %d 个记录到 %s\n", len(pending)-failed, store.VersionV2)
+	if failed > 0 {
+		fatal(fmt.Errorf("%d 个记录升级失败，原文件未改动", failed))
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"fssh/internal/auth"
+	"fssh/internal/keychain"
+	"fssh/internal/otp"
+	"fssh/internal/store"
+)
+
+// cmdSwitchMode 切换认证模式并用新的 master key 重新加密整个密钥库
+// 顺序：解锁当前模式 → 设置新模式 → 暂存重新加密的记录 → 替换密钥目录 → 更新 auth_mode.json
+// 任何一步失败都会撤销之前的步骤
+func cmdSwitchMode() {
+	fs := flag.NewFlagSet("switch-mode", flag.ExitOnError)
+	to := fs.String("to", "", "target authentication mode: otp, touchid or passphrase")
+	seedTTL := fs.Int("seed-unlock-ttl", 3600, "OTP seed cache time (seconds), OTP mode only")
+	otpType := fs.String("otp-type", "totp", "OTP type: totp or hotp, OTP mode only")
+	algorithm := fs.String("algorithm", "SHA1", "OTP algorithm: SHA1, SHA256, SHA512, OTP mode only")
+	digits := fs.Int("digits", 6, "OTP digits: 6 or 8, OTP mode only")
+	skew := fs.Int("otp-skew", 1, "allowed TOTP clock skew in periods (1-5), OTP mode only")
+	fs.Parse(os.Args[2:])
+
+	target := auth.AuthMode(*to)
+	switch target {
+	case auth.ModeTouchID, auth.ModeOTP, auth.ModePassphrase:
+	default:
+		fatal(errors.New("--to must be one of: otp, touchid, passphrase"))
+	}
+
+	if store.RekeyInProgress() {
+		fatal(store.ErrRekeyInProgress)
+	}
+
+	current, err := auth.LoadMode()
+	if err != nil {
+		fatal(err)
+	}
+	if current == target {
+		fmt.Printf("当前已是 %s 模式\n", target)
+		return
+	}
+
+	fmt.Printf("切换认证模式: %s → %s\n", current, target)
+	fmt.Println()
+
+	// 1. 用当前认证方式解锁旧 master key
+	fmt.Println("[1/4] 使用当前认证方式解锁")
+	oldMK, err := unlockMasterKey()
+	if err != nil {
+		fatal(fmt.Errorf("解锁失败: %w", err))
+	}
+
+	// 2. 设置新认证方式，得到新 master key
+	fmt.Println("[2/4] 设置新的认证方式")
+	var (
+		newMK     []byte
+		otpResult *otpSetupResult
+	)
+	undo, err := snapshotAuthState(current, oldMK)
+	if err != nil {
+		fatal(err)
+	}
+	switch target {
+	case auth.ModeTouchID:
+		newMK, err = setupTouchIDKey()
+	case auth.ModeOTP:
+		otpResult, err = setupOTPKey(&otp.InitOptions{
+			SeedUnlockTTL:    *seedTTL,
+			Type:             *otpType,
+			Algorithm:        *algorithm,
+			Digits:           *digits,
+			Period:           30,
+			SkewSteps:        *skew,
+			GenerateRecovery: true,
+		})
+		if otpResult != nil {
+			newMK = otpResult.masterKey
+		}
+	case auth.ModePassphrase:
+		newMK, err = setupPassphraseKey()
+	}
+	if err != nil {
+		undo()
+		fatal(fmt.Errorf("设置 %s 失败: %w", target, err))
+	}
+
+	// 3. 重新加密所有记录到暂存目录，然后整体替换
+	fmt.Println("[3/4] 重新加密密钥库")
+	re, err := store.StageReencryption(oldMK, newMK)
+	if err != nil {
+		undo()
+		fatal(fmt.Errorf("重新加密失败（密钥库未改动）: %w", err))
+	}
+	if err := re.Commit(); err != nil {
+		re.Abort()
+		undo()
+		fatal(fmt.Errorf("替换密钥目录失败（密钥库未改动）: %w", err))
+	}
+
+	// 4. 最后才更新认证模式
+	fmt.Println("[4/4] 更新认证模式")
+	if err := auth.SaveMode(target); err != nil {
+		if rbErr := re.Rollback(); rbErr != nil {
+			fmt.Fprintf(os.Stderr, "警告: 恢复密钥目录失败，旧记录保存在 %s: %v\n", re.BackupDir(), rbErr)
+		}
+		undo()
+		fatal(fmt.Errorf("保存认证模式失败: %w", err))
+	}
+	if err := re.Cleanup(); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
+
+	// Keychain 中的旧 master key 已无法解密任何记录
+	if target == auth.ModePassphrase {
+		_ = keychain.DeleteMasterKey()
+	}
+
+	fmt.Println()
+	fmt.Printf("✓ 已切换到 %s 模式，重新加密了 %d 个密钥\n", target, re.Count)
+	if otpResult != nil {
+		if err := otp.DisplayInitResult(otpResult.seed, otpResult.recoveryCodes, otpResult.config); err != nil {
+			fatal(err)
+		}
+	}
+	fmt.Println("如果 agent 正在运行，请重启 agent")
+}
+
+// otpSetupResult OTP 模式设置结果，切换完成后再显示注册信息
+type otpSetupResult struct {
+	seed          []byte
+	recoveryCodes []string
+	config        *otp.Config
+	masterKey     []byte
+}
+
+// setupTouchIDKey 生成新 master key 并存入 Keychain
+func setupTouchIDKey() ([]byte, error) {
+	mk := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, mk); err != nil {
+		return nil, err
+	}
+	if err := keychain.StoreMasterKey(mk, true); err != nil {
+		return nil, err
+	}
+	return mk, nil
+}
+
+// setupOTPKey 初始化 OTP 配置并从 seed 派生新 master key
+func setupOTPKey(opts *otp.InitOptions) (*otpSetupResult, error) {
+	password, err := otp.PromptPasswordWithConfirm(
+		"请设置 OTP 密码（至少12位）: ",
+		"确认密码: ",
+	)
+	if err != nil {
+		return nil, err
+	}
+	opts.Password = password
+
+	seed, codes, err := otp.Initialize(opts)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := otp.LoadConfig(otp.ConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	mk, err := deriveMasterKeyFromSeed(seed, opts)
+	if err != nil {
+		return nil, err
+	}
+	// 与 initOTPMode 一致：命令行操作使用 Keychain 中的 master key
+	if err := keychain.StoreMasterKey(mk, true); err != nil {
+		return nil, err
+	}
+	return &otpSetupResult{seed: seed, recoveryCodes: codes, config: cfg, masterKey: mk}, nil
+}
+
+// setupPassphraseKey 生成新 master key 并用口令加密保存
+func setupPassphraseKey() ([]byte, error) {
+	passphrase, err := promptNewPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	mk := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, mk); err != nil {
+		return nil, err
+	}
+	if err := auth.InitPassphrase(mk, passphrase); err != nil {
+		return nil, err
+	}
+	return mk, nil
+}
+
+// snapshotAuthState 记录切换前的认证状态，返回用于撤销的函数
+// 恢复 OTP/口令配置文件的原内容，以及 Keychain 中的旧 master key
+func snapshotAuthState(current auth.AuthMode, oldMK []byte) (func(), error) {
+	restoreOTP, err := snapshotFile(otp.ConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	restorePassphrase, err := snapshotFile(auth.PassphraseConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		restoreOTP()
+		restorePassphrase()
+		if current == auth.ModeTouchID || current == auth.ModeOTP {
+			_ = keychain.StoreMasterKey(oldMK, true)
+		} else {
+			_ = keychain.DeleteMasterKey()
+		}
+	}, nil
+}
+
+// snapshotFile 读取文件当前内容，返回把文件恢复到该状态的函数
+func snapshotFile(path string) (func(), error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return func() { _ = os.Remove(path) }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = os.WriteFile(path, data, 0600) }, nil
+}
@@ -4,12 +4,12 @@ go 1.21
 
 require (
 	github.com/keybase/go-keychain v0.0.1
+	github.com/peterh/liner v1.2.2
 	golang.org/x/crypto v0.32.0
 	golang.org/x/term v0.28.0
 )
 
 require (
 	github.com/mattn/go-runewidth v0.0.3 // indirect
-	github.com/peterh/liner v1.2.2 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 )
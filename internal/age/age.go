@@ -0,0 +1,334 @@
+// Package age 实现 age v1 文件格式（age-encryption.org/v1）中 fssh 需要的部分：
+// ssh-ed25519 接收方、STREAM 分块加密和 ASCII armor。
+// 生成的文件可以用 age -d -i ~/.ssh/id_ed25519 解密，age -R 加密给 ssh-ed25519 公钥的文件也能用 fssh decrypt 解密
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	intro        = "age-encryption.org/v1\n"
+	stanzaPrefix = "-> "
+	footerPrefix = "---"
+
+	fileKeySize   = 16
+	streamNonce   = 16
+	chunkSize     = 64 * 1024
+	bodyLineChars = 64
+)
+
+// b64 age 使用不带填充的标准 Base64
+var b64 = base64.RawStdEncoding
+
+// ErrIncorrectIdentity 接收方记录不是发给该身份的
+var ErrIncorrectIdentity = errors.New("incorrect identity for recipient block")
+
+// ErrNoIdentityMatch 没有任何身份能解开文件密钥
+var ErrNoIdentityMatch = errors.New("no identity matched any of the recipients")
+
+// Stanza 文件头中的一条接收方记录
+type Stanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+// Recipient 把文件密钥加密给一个接收方
+type Recipient interface {
+	Wrap(fileKey []byte) (*Stanza, error)
+}
+
+// Identity 从接收方记录中解出文件密钥；记录不属于自己时返回 ErrIncorrectIdentity
+type Identity interface {
+	Unwrap(s *Stanza) ([]byte, error)
+}
+
+// Encrypt 用随机文件密钥加密明文，并为每个接收方写入一条记录
+func Encrypt(plaintext []byte, recipients ...Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients specified")
+	}
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+	var hdr bytes.Buffer
+	hdr.WriteString(intro)
+	for _, r := range recipients {
+		s, err := r.Wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		writeStanza(&hdr, s)
+	}
+	hdr.WriteString(footerPrefix)
+	mac := headerMAC(fileKey, hdr.Bytes())
+	hdr.WriteString(" " + b64.EncodeToString(mac) + "\n")
+
+	nonce := make([]byte, streamNonce)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	hdr.Write(nonce)
+	payload, err := sealStream(streamKey(fileKey, nonce), plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return append(hdr.Bytes(), payload...), nil
+}
+
+// Decrypt 解密 age 文件（二进制或 armor），依次尝试每个身份
+func Decrypt(data []byte, identities ...Identity) ([]byte, error) {
+	if IsArmored(data) {
+		var err error
+		if data, err = dearmor(data); err != nil {
+			return nil, err
+		}
+	}
+	stanzas, signed, mac, rest, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	var fileKey []byte
+	for _, id := range identities {
+		for _, s := range stanzas {
+			key, err := id.Unwrap(s)
+			if errors.Is(err, ErrIncorrectIdentity) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			fileKey = key
+			break
+		}
+		if fileKey != nil {
+			break
+		}
+	}
+	if fileKey == nil {
+		return nil, ErrNoIdentityMatch
+	}
+	if !hmac.Equal(headerMAC(fileKey, signed), mac) {
+		return nil, errors.New("age: header MAC mismatch, the file is corrupt or was modified")
+	}
+	if len(rest) < streamNonce {
+		return nil, errors.New("age: missing payload nonce")
+	}
+	return openStream(streamKey(fileKey, rest[:streamNonce]), rest[streamNonce:])
+}
+
+// Stanzas 返回文件头中的接收方记录，不解密，用于选择解密身份
+func Stanzas(data []byte) ([]*Stanza, error) {
+	if IsArmored(data) {
+		var err error
+		if data, err = dearmor(data); err != nil {
+			return nil, err
+		}
+	}
+	stanzas, _, _, _, err := parseHeader(data)
+	return stanzas, err
+}
+
+// writeStanza 写入一条记录：参数行和按 64 列折行的 Body，最后一行必须短于 64 列（可能为空行）
+func writeStanza(w *bytes.Buffer, s *Stanza) {
+	w.WriteString(stanzaPrefix + s.Type)
+	for _, a := range s.Args {
+		w.WriteString(" " + a)
+	}
+	w.WriteString("\n")
+	body := b64.EncodeToString(s.Body)
+	for len(body) >= bodyLineChars {
+		w.WriteString(body[:bodyLineChars] + "\n")
+		body = body[bodyLineChars:]
+	}
+	w.WriteString(body + "\n")
+}
+
+// parseHeader 解析文件头，返回接收方记录、MAC 覆盖的部分、MAC 和其后的数据
+func parseHeader(data []byte) (stanzas []*Stanza, signed, mac, rest []byte, err error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	readLine := func() (string, error) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", errors.New("age: unexpected end of header")
+		}
+		return strings.TrimSuffix(line, "\n"), nil
+	}
+	first, err := readLine()
+	if err != nil || first+"\n" != intro {
+		return nil, nil, nil, nil, errors.New("age: not an age-encryption.org/v1 file")
+	}
+	consumed := len(intro)
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		if strings.HasPrefix(line, footerPrefix+" ") {
+			if mac, err = b64.DecodeString(strings.TrimPrefix(line, footerPrefix+" ")); err != nil {
+				return nil, nil, nil, nil, fmt.Errorf("age: invalid header MAC: %w", err)
+			}
+			signed = data[:consumed+len(footerPrefix)]
+			rest = data[consumed+len(line)+1:]
+			return stanzas, signed, mac, rest, nil
+		}
+		if !strings.HasPrefix(line, stanzaPrefix) {
+			return nil, nil, nil, nil, fmt.Errorf("age: malformed header line %q", line)
+		}
+		consumed += len(line) + 1
+		fields := strings.Split(strings.TrimPrefix(line, stanzaPrefix), " ")
+		s := &Stanza{Type: fields[0], Args: fields[1:]}
+		var body strings.Builder
+		for {
+			l, err := readLine()
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			consumed += len(l) + 1
+			if len(l) > bodyLineChars {
+				return nil, nil, nil, nil, errors.New("age: stanza body line too long")
+			}
+			body.WriteString(l)
+			if len(l) < bodyLineChars {
+				break
+			}
+		}
+		if s.Body, err = b64.DecodeString(body.String()); err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("age: invalid stanza body: %w", err)
+		}
+		stanzas = append(stanzas, s)
+	}
+}
+
+// headerMAC HMAC-SHA256(HKDF(fileKey, "header"), 头部直到 "---")
+func headerMAC(fileKey, signed []byte) []byte {
+	h := hmac.New(sha256.New, hkdfKey(fileKey, nil, "header"))
+	h.Write(signed)
+	return h.Sum(nil)
+}
+
+// streamKey 由文件密钥和 16 字节 nonce 派生负载密钥
+func streamKey(fileKey, nonce []byte) []byte {
+	return hkdfKey(fileKey, nonce, "payload")
+}
+
+func hkdfKey(secret, salt []byte, info string) []byte {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// chunkNonce STREAM 分块 nonce：11 字节大端计数器加 1 字节最后一块标志
+func chunkNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	for i := 10; i >= 3; i-- {
+		nonce[i] = byte(counter)
+		counter >>= 8
+	}
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// sealStream 按 64 KiB 分块加密，空明文也会产生一个最后块
+func sealStream(key, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	for counter := uint64(0); ; counter++ {
+		n := len(plaintext)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		last := n == len(plaintext)
+		out = aead.Seal(out, chunkNonce(counter, last), plaintext[:n], nil)
+		plaintext = plaintext[n:]
+		if last {
+			return out, nil
+		}
+	}
+}
+
+// openStream 解密 STREAM 负载，检查最后块标志以发现截断
+func openStream(key, ciphertext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	encChunk := chunkSize + aead.Overhead()
+	var out []byte
+	for counter := uint64(0); ; counter++ {
+		n := len(ciphertext)
+		if n > encChunk {
+			n = encChunk
+		}
+		last := n == len(ciphertext)
+		out, err = aead.Open(out, chunkNonce(counter, last), ciphertext[:n], nil)
+		if err != nil {
+			return nil, errors.New("age: payload authentication failed, the file is corrupt or truncated")
+		}
+		if counter > 0 && last && n == aead.Overhead() {
+			return nil, errors.New("age: empty final chunk")
+		}
+		ciphertext = ciphertext[n:]
+		if last {
+			return out, nil
+		}
+	}
+}
+
+// ASCII armor：PEM 风格，标准 Base64（带填充），每行 64 列
+const (
+	armorBegin = "-----BEGIN AGE ENCRYPTED FILE-----"
+	armorEnd   = "-----END AGE ENCRYPTED FILE-----"
+)
+
+// IsArmored 数据是否为 armor 格式
+func IsArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(armorBegin))
+}
+
+// Armor 把二进制 age 文件编码为 armor 格式，便于粘贴到文本中
+func Armor(data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(armorBegin + "\n")
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > bodyLineChars {
+		b.WriteString(enc[:bodyLineChars] + "\n")
+		enc = enc[bodyLineChars:]
+	}
+	b.WriteString(enc + "\n")
+	b.WriteString(armorEnd + "\n")
+	return b.Bytes()
+}
+
+func dearmor(data []byte) ([]byte, error) {
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n")), "\n")
+	if len(lines) < 2 || lines[0] != armorBegin || lines[len(lines)-1] != armorEnd {
+		return nil, errors.New("age: malformed armor")
+	}
+	out, err := base64.StdEncoding.DecodeString(strings.Join(lines[1:len(lines)-1], ""))
+	if err != nil {
+		return nil, fmt.Errorf("age: malformed armor: %w", err)
+	}
+	return out, nil
+}
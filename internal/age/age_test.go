@@ -0,0 +1,132 @@
+package age
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 以下文件由 filippo.io/age v1.0.0 加密（agessh 接收方 + armor），私钥种子为 32 个 0x42
+const referenceFile = `-----BEGIN AGE ENCRYPTED FILE-----
+YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IHNzaC1lZDI1NTE5IFpzck9WQSBLTE5w
+dGtYbFMvdE0wNm1oMEpGT2poOWQ5UzdHMTlCV09CdSt4dUdwcnpVCnAyZlI2M3RP
+MzZ0RnEwaVlUc1FIVHl3MEhBVlVZSTM5anJyYm13UTVxK1UKLS0tIGcwc05PaWI0
+Si9idFgrMyttSGlXeFQ1N0JuRUd1SStlcHVtWVNpNGxhcEUKgeuATG7UbAn7/aTN
+AhF8b+PRCsc4fKrjvqeuQBiyqhwwPTlMyv7m4jAnoX0tlfJ+63QCsjE91V2P3w==
+-----END AGE ENCRYPTED FILE-----
+`
+
+func testKey(t *testing.T) (*Ed25519Recipient, *Ed25519Identity) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spk, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewEd25519Recipient(spk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := NewEd25519Identity(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, id
+}
+
+func TestDecryptReference(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{0x42}, ed25519.SeedSize))
+	id, err := NewEd25519Identity(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decrypt([]byte(referenceFile), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello from filippo.io/age\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	r, id := testKey(t)
+	// 覆盖空文件、单块、恰好一整块和多块
+	for _, size := range []int{0, 5, chunkSize, chunkSize + 1, 200000} {
+		msg := make([]byte, size)
+		rand.Read(msg)
+		ct, err := Encrypt(msg, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, data := range map[string][]byte{"binary": ct, "armor": Armor(ct)} {
+			got, err := Decrypt(data, id)
+			if err != nil {
+				t.Fatalf("size=%d %s: %v", size, name, err)
+			}
+			if !bytes.Equal(got, msg) {
+				t.Fatalf("size=%d %s: plaintext mismatch", size, name)
+			}
+		}
+	}
+}
+
+func TestDecryptMultipleRecipients(t *testing.T) {
+	r1, _ := testKey(t)
+	r2, id2 := testKey(t)
+	ct, err := Encrypt([]byte("secret"), r1, r2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stanzas, err := Stanzas(ct)
+	if err != nil || len(stanzas) != 2 {
+		t.Fatalf("Stanzas() = %d, %v", len(stanzas), err)
+	}
+	if got, err := Decrypt(ct, id2); err != nil || string(got) != "secret" {
+		t.Fatalf("Decrypt() = %q, %v", got, err)
+	}
+}
+
+func TestDecryptWrongIdentity(t *testing.T) {
+	r, _ := testKey(t)
+	_, other := testKey(t)
+	ct, err := Encrypt([]byte("x"), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(ct, other); !errors.Is(err, ErrNoIdentityMatch) {
+		t.Fatalf("got %v, want ErrNoIdentityMatch", err)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	r, id := testKey(t)
+	ct, err := Encrypt(bytes.Repeat([]byte("a"), chunkSize+10), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 分别篡改文件头和最后一块，以及截掉最后一块
+	hdrEnd := bytes.Index(ct, []byte("\n---")) + 5
+	for name, data := range map[string][]byte{
+		"header":    flip(ct, hdrEnd),
+		"payload":   flip(ct, len(ct)-1),
+		"truncated": ct[:len(ct)-20],
+	} {
+		if _, err := Decrypt(data, id); err == nil {
+			t.Errorf("%s: Decrypt succeeded", name)
+		}
+	}
+}
+
+func flip(data []byte, i int) []byte {
+	out := append([]byte(nil), data...)
+	out[i] ^= 1
+	return out
+}
@@ -0,0 +1,158 @@
+package age
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"fssh/internal/crypt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/ssh"
+)
+
+const ed25519Label = "age-encryption.org/v1/ssh-ed25519"
+
+// Ed25519Recipient ssh-ed25519 接收方：公钥转换为 X25519，共享秘密再用公钥派生的 tweak 调整（与 age 的 agessh 相同）
+type Ed25519Recipient struct {
+	sshKey ssh.PublicKey
+	x25519 *ecdh.PublicKey
+}
+
+// NewEd25519Recipient 由 ssh-ed25519 公钥创建接收方
+func NewEd25519Recipient(pk ssh.PublicKey) (*Ed25519Recipient, error) {
+	if pk.Type() != ssh.KeyAlgoED25519 {
+		return nil, fmt.Errorf("unsupported recipient key type %s (only ssh-ed25519)", pk.Type())
+	}
+	cpk, ok := pk.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, errors.New("invalid ssh-ed25519 public key")
+	}
+	x, err := crypt.Ed25519PublicToX25519(cpk.CryptoPublicKey().(ed25519.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	return &Ed25519Recipient{sshKey: pk, x25519: x}, nil
+}
+
+// Wrap 实现 Recipient
+func (r *Ed25519Recipient) Wrap(fileKey []byte) (*Stanza, error) {
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := eph.ECDH(r.x25519)
+	if err != nil {
+		return nil, err
+	}
+	wrappingKey, err := ed25519WrappingKey(r.sshKey, shared, eph.PublicKey().Bytes(), r.x25519.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	body, err := aeadSeal(wrappingKey, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Stanza{
+		Type: "ssh-ed25519",
+		Args: []string{Tag(r.sshKey), b64.EncodeToString(eph.PublicKey().Bytes())},
+		Body: body,
+	}, nil
+}
+
+// Ed25519Identity ssh-ed25519 身份，私钥转换为 X25519
+type Ed25519Identity struct {
+	sshKey ssh.PublicKey
+	x25519 *ecdh.PrivateKey
+}
+
+// NewEd25519Identity 由 ed25519 私钥创建身份
+func NewEd25519Identity(priv ed25519.PrivateKey) (*Ed25519Identity, error) {
+	pk, err := ssh.NewPublicKey(priv.Public())
+	if err != nil {
+		return nil, err
+	}
+	x, err := crypt.Ed25519PrivateToX25519(priv)
+	if err != nil {
+		return nil, err
+	}
+	return &Ed25519Identity{sshKey: pk, x25519: x}, nil
+}
+
+// Unwrap 实现 Identity
+func (i *Ed25519Identity) Unwrap(s *Stanza) ([]byte, error) {
+	if s.Type != "ssh-ed25519" || len(s.Args) != 2 || s.Args[0] != Tag(i.sshKey) {
+		return nil, ErrIncorrectIdentity
+	}
+	ephBytes, err := b64.DecodeString(s.Args[1])
+	if err != nil {
+		return nil, fmt.Errorf("age: invalid ssh-ed25519 recipient block: %w", err)
+	}
+	eph, err := ecdh.X25519().NewPublicKey(ephBytes)
+	if err != nil {
+		return nil, fmt.Errorf("age: invalid ssh-ed25519 recipient block: %w", err)
+	}
+	shared, err := i.x25519.ECDH(eph)
+	if err != nil {
+		return nil, err
+	}
+	wrappingKey, err := ed25519WrappingKey(i.sshKey, shared, ephBytes, i.x25519.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := aeadOpen(wrappingKey, s.Body)
+	if err != nil {
+		// 标签只有 4 字节，可能碰撞，解不开时视为不是发给自己的
+		return nil, ErrIncorrectIdentity
+	}
+	return fileKey, nil
+}
+
+// Tag ssh-ed25519 记录中的公钥标签：SSH 公钥编码 SHA-256 的前 4 字节
+// 用于不解锁私钥就判断文件是否发给某个公钥
+func Tag(pk ssh.PublicKey) string {
+	h := sha256.Sum256(pk.Marshal())
+	return b64.EncodeToString(h[:4])
+}
+
+// ed25519WrappingKey 用 tweak = HKDF(salt=公钥编码, info=label) 调整共享秘密，
+// 再以 HKDF(共享秘密, salt=临时公钥||接收方公钥, info=label) 派生包装密钥
+func ed25519WrappingKey(pk ssh.PublicKey, shared, ephemeral, recipient []byte) ([]byte, error) {
+	tweak, err := ecdh.X25519().NewPrivateKey(hkdfKey(nil, pk.Marshal(), ed25519Label))
+	if err != nil {
+		return nil, err
+	}
+	sharedPoint, err := ecdh.X25519().NewPublicKey(shared)
+	if err != nil {
+		return nil, err
+	}
+	tweaked, err := tweak.ECDH(sharedPoint)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	return hkdfKey(tweaked, salt, ed25519Label), nil
+}
+
+// aeadSeal 用全零 nonce 的 ChaCha20-Poly1305 加密文件密钥，每个包装密钥只使用一次
+func aeadSeal(key, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, make([]byte, aead.NonceSize()), plaintext, nil), nil
+}
+
+func aeadOpen(key, ciphertext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) != fileKeySize+aead.Overhead() {
+		return nil, errors.New("age: invalid wrapped file key size")
+	}
+	return aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext, nil)
+}
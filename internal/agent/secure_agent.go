@@ -7,10 +7,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"time"
 
-	"encoding/json"
 	"fssh/internal/auth"
 	"fssh/internal/log"
 	"fssh/internal/store"
@@ -21,17 +19,16 @@ import (
 
 type secureAgent struct {
 	authProvider auth.AuthProvider
+	store        store.Backend
+	filter       store.Filter // 只提供满足条件的密钥
 }
 
-func newSecureAgentWithTTL(ttlSeconds int) (*secureAgent, error) {
-	// 获取认证提供者
-	provider, err := auth.GetAuthProvider(ttlSeconds)
-	if err != nil {
-		return nil, err
-	}
-
+// newSecureAgent 使用调用方的认证提供者，启动时预先解锁的 master key 缓存因此对签名有效
+func newSecureAgent(provider auth.AuthProvider, filter store.Filter) *secureAgent {
 	agent := &secureAgent{
 		authProvider: provider,
+		store:        store.Default(),
+		filter:       filter,
 	}
 
 	// 加载密钥计数用于日志
@@ -39,34 +36,61 @@ func newSecureAgentWithTTL(ttlSeconds int) (*secureAgent, error) {
 	log.Info("创建安全 agent", map[string]interface{}{
 		"auth_mode": provider.Mode(),
 		"key_count": len(metas),
+		"tags":      filter.Tags,
 	})
 
-	return agent, nil
+	return agent
 }
 
-// loadMetas 动态加载所有加密私钥的元数据
+// loadMetas 动态加载满足筛选条件的加密私钥元数据
+// 每次请求都重新筛选，密钥过期后立即不再提供
+// 这里还没有 master key，无法校验元数据 MAC：没有 MAC 的记录直接跳过，MAC 本身在签名解锁后由 unlockRecord 校验
 func (a *secureAgent) loadMetas() ([]store.EncryptedFile, error) {
-	dir := store.KeysDir()
-	entries, err := os.ReadDir(dir)
-	if err != nil && !os.IsNotExist(err) {
+	files, err := a.store.List()
+	if err != nil {
 		return nil, err
 	}
-	var metas []store.EncryptedFile
-	for _, e := range entries {
-		if e.IsDir() || filepath.Ext(e.Name()) != ".enc" {
+	out := files[:0]
+	for _, ef := range files {
+		if ef.MetaMAC == "" {
+			log.Warn("记录的元数据未认证，不提供该密钥（运行 fssh store migrate）", map[string]interface{}{"alias": ef.Alias})
 			continue
 		}
-		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
-		if err != nil {
-			continue
-		}
-		var m store.EncryptedFile
-		if err := jsonUnmarshal(b, &m); err != nil {
-			continue
+		out = append(out, ef)
+	}
+	return a.filter.Apply(out, time.Now()), nil
+}
+
+// unlockRecord 查找指纹对应的密钥，解锁 master key 并解密
+// 筛选依据的元数据在解锁后校验 MAC，被篡改（如清除过期时间、修改标签或主机）的密钥不提供签名
+func (a *secureAgent) unlockRecord(fp string) (*store.Record, error) {
+	// 动态加载最新的密钥列表
+	metas, err := a.loadMetas()
+	if err != nil {
+		return nil, err
+	}
+
+	var ef *store.EncryptedFile
+	for i := range metas {
+		if metas[i].Fingerprint == fp {
+			ef = &metas[i]
+			break
 		}
-		metas = append(metas, m)
 	}
-	return metas, nil
+	if ef == nil {
+		return nil, errors.New("key not found")
+	}
+
+	// 使用 AuthProvider 解锁 master key
+	mk, err := a.authProvider.UnlockMasterKey()
+	if err != nil {
+		return nil, fmt.Errorf("认证失败: %w", err)
+	}
+	if err := store.VerifyMeta(ef, mk); err != nil {
+		log.Warn("记录的元数据校验失败，拒绝签名", map[string]interface{}{"alias": ef.Alias, "error": err.Error()})
+		return nil, fmt.Errorf("%s: %w", ef.Alias, err)
+	}
+	return store.LoadRecord(a.store, ef.Alias, mk)
 }
 
 func (a *secureAgent) List() ([]*xagent.Key, error) {
@@ -97,30 +121,7 @@ func (a *secureAgent) List() ([]*xagent.Key, error) {
 func (a *secureAgent) Sign(pubkey ssh.PublicKey, data []byte) (*ssh.Signature, error) {
 	fp := ssh.FingerprintSHA256(pubkey)
 
-	// 动态加载最新的密钥列表
-	metas, err := a.loadMetas()
-	if err != nil {
-		return nil, err
-	}
-
-	var alias string
-	for _, m := range metas {
-		if m.Fingerprint == fp {
-			alias = m.Alias
-			break
-		}
-	}
-	if alias == "" {
-		return nil, errors.New("key not found")
-	}
-
-	// 使用 AuthProvider 解锁 master key
-	mk, err := a.authProvider.UnlockMasterKey()
-	if err != nil {
-		return nil, fmt.Errorf("认证失败: %w", err)
-	}
-
-	rec, err := store.LoadDecryptedRecord(alias, mk)
+	rec, err := a.unlockRecord(fp)
 	if err != nil {
 		return nil, err
 	}
@@ -145,30 +146,7 @@ func (a *secureAgent) Sign(pubkey ssh.PublicKey, data []byte) (*ssh.Signature, e
 func (a *secureAgent) SignWithFlags(pubkey ssh.PublicKey, data []byte, flags xagent.SignatureFlags) (*ssh.Signature, error) {
 	fp := ssh.FingerprintSHA256(pubkey)
 
-	// 动态加载最新的密钥列表
-	metas, err := a.loadMetas()
-	if err != nil {
-		return nil, err
-	}
-
-	var alias string
-	for _, m := range metas {
-		if m.Fingerprint == fp {
-			alias = m.Alias
-			break
-		}
-	}
-	if alias == "" {
-		return nil, errors.New("key not found")
-	}
-
-	// 使用 AuthProvider 解锁 master key
-	mk, err := a.authProvider.UnlockMasterKey()
-	if err != nil {
-		return nil, fmt.Errorf("认证失败: %w", err)
-	}
-
-	rec, err := store.LoadDecryptedRecord(alias, mk)
+	rec, err := a.unlockRecord(fp)
 	if err != nil {
 		return nil, err
 	}
@@ -229,5 +207,3 @@ func (a *secureAgent) RemoveAll() error                  { return nil }
 func (a *secureAgent) Lock(passphrase []byte) error      { return nil }
 func (a *secureAgent) Unlock(passphrase []byte) error    { return nil }
 func (a *secureAgent) Signers() ([]ssh.Signer, error)    { return nil, errors.New("unsupported") }
-
-func jsonUnmarshal(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
@@ -5,22 +5,28 @@ import (
     "fmt"
     "net"
     "os"
+    "os/signal"
     "path/filepath"
+    "syscall"
+    "time"
 
     "fssh/internal/auth"
+    "fssh/internal/config"
     "fssh/internal/log"
     "fssh/internal/store"
+    "fssh/internal/usage"
     xagent "golang.org/x/crypto/ssh/agent"
 )
 
 func defaultSocket() string {
-    home, _ := os.UserHomeDir()
-    return filepath.Join(home, ".fssh", "agent.sock")
+    return config.DefaultSocket()
 }
 
-func Start(socketPath string) error { return StartWithOptions(socketPath, true, 0) }
+func Start(socketPath string) error { return StartWithOptions(socketPath, true, 0, store.Filter{}, false) }
 
-func StartWithOptions(socketPath string, requireTouchPerSign bool, ttlSeconds int) error {
+// StartWithOptions 启动 agent，只提供满足 filter 的密钥（过期密钥始终不提供）
+// rejectSHA1 时拒绝不带 RSA-SHA2 标志的 RSA 签名请求
+func StartWithOptions(socketPath string, requireTouchPerSign bool, ttlSeconds int, filter store.Filter, rejectSHA1 bool) error {
     log.Info("启动 fssh SSH 认证代理", nil)
 
     if socketPath == "" {
@@ -37,9 +43,10 @@ func StartWithOptions(socketPath string, requireTouchPerSign bool, ttlSeconds in
         "mode": provider.Mode(),
     })
 
-    // OTP 模式：启动时预先解锁
-    if provider.Mode() == auth.ModeOTP && requireTouchPerSign {
-        if err := preUnlockOTP(); err != nil {
+    // OTP 模式：启动时预先解锁，master key 缓存在 provider 中，首次签名不再提示
+    // 没有缓存（TTL 为 0）时跳过：预先输入的验证码会被记为已使用，同一周期内签名时再次输入会被当作重放拒绝
+    if provider.Mode() == auth.ModeOTP && requireTouchPerSign && ttlSeconds > 0 {
+        if err := preUnlockOTP(provider); err != nil {
             return err
         }
     }
@@ -55,24 +62,25 @@ func StartWithOptions(socketPath string, requireTouchPerSign bool, ttlSeconds in
 
     var ag xagent.Agent
     if requireTouchPerSign {
-        sa, err := newSecureAgentWithTTL(ttlSeconds)
-        if err != nil { ln.Close(); return err }
-        ag = sa
+        ag = newSecureAgent(provider, filter)
         log.Info("安全模式: 每次签名需要认证", map[string]interface{}{
             "ttl_seconds": ttlSeconds,
         })
     } else {
-        // 便利模式：启动时解密所有私钥
+        // 便利模式：启动时解密所有私钥，过期时间也只在启动时判断
         mk, err := provider.UnlockMasterKey()
         if err != nil { ln.Close(); return err }
         keyring := xagent.NewKeyring()
-        dir := store.KeysDir()
-        entries, err := os.ReadDir(dir)
+        backend := store.Default()
+        files, err := backend.List()
         if err == nil {
-            for _, e := range entries {
-                if e.IsDir() || filepath.Ext(e.Name()) != ".enc" { continue }
-                alias := e.Name()[:len(e.Name())-4]
-                rec, err := store.LoadDecryptedRecord(alias, mk)
+            for _, ef := range filter.Apply(files, time.Now()) {
+                // 筛选依据的元数据必须未被篡改
+                if err := store.VerifyMeta(&ef, mk); err != nil {
+                    log.Warn("记录的元数据校验失败，不提供该密钥", map[string]interface{}{"alias": ef.Alias, "error": err.Error()})
+                    continue
+                }
+                rec, err := store.LoadRecord(backend, ef.Alias, mk)
                 if err != nil { continue }
                 pk, err := x509.ParsePKCS8PrivateKey(rec.PKCS8DER)
                 if err != nil { continue }
@@ -83,6 +91,14 @@ func StartWithOptions(socketPath string, requireTouchPerSign bool, ttlSeconds in
         log.Info("便利模式: 启动时解密所有私钥", nil)
     }
 
+    if rejectSHA1 {
+        ag = &sha1Guard{Agent: ag}
+        log.Info("拒绝 ssh-rsa (SHA-1) 签名", nil)
+    }
+
+    // 使用统计在内存中累积，定期批量写入，退出时写入剩余部分
+    recorder := usage.NewRecorder(usage.DefaultFlushInterval)
+
     go func() {
         for {
             conn, err := ln.Accept()
@@ -90,7 +106,7 @@ func StartWithOptions(socketPath string, requireTouchPerSign bool, ttlSeconds in
                 return
             }
             go func(c net.Conn) {
-                _ = xagent.ServeAgent(ag, c)
+                _ = xagent.ServeAgent(&usageAgent{Agent: ag, rec: recorder}, c)
                 c.Close()
             }(conn)
         }
@@ -105,25 +121,26 @@ func StartWithOptions(socketPath string, requireTouchPerSign bool, ttlSeconds in
     fmt.Println()
 
     // Block until interrupted
-    select {}
+    sigCh := make(chan os.Signal, 1)
+    signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+    <-sigCh
+    ln.Close()
+    _ = os.Remove(socketPath)
+    if err := recorder.Close(); err != nil {
+        log.Warn("写入使用统计失败", map[string]interface{}{"error": err.Error()})
+    }
+    return nil
 }
 
 // preUnlockOTP OTP 模式启动时预先解锁
-// 提示用户输入密码和验证码，避免首次 SSH 连接时等待
-func preUnlockOTP() error {
+// 提示用户输入密码和验证码，解锁结果缓存在 provider 中，避免首次 SSH 连接时等待
+func preUnlockOTP(provider auth.AuthProvider) error {
     fmt.Println()
     fmt.Println("OTP 认证初始化")
     fmt.Println("==============")
 
-    // 创建临时 provider 进行预热
-    provider, err := auth.NewOTPProvider(0) // TTL=0，不影响后续的缓存配置
-    if err != nil {
-        return err
-    }
-
     // 调用 UnlockMasterKey，会提示输入密码和验证码
-    _, err = provider.UnlockMasterKey()
-    if err != nil {
+    if _, err := provider.UnlockMasterKey(); err != nil {
         return fmt.Errorf("OTP 认证失败: %w", err)
     }
 
@@ -0,0 +1,54 @@
+package agentserver
+
+import (
+	"errors"
+
+	"fssh/internal/log"
+
+	"golang.org/x/crypto/ssh"
+	xagent "golang.org/x/crypto/ssh/agent"
+)
+
+// errSHA1Refused 拒绝 ssh-rsa（SHA-1）签名
+var errSHA1Refused = errors.New("ssh-rsa (SHA-1) signatures are refused by agent_reject_sha1")
+
+// sha1Guard 拒绝用 RSA 密钥生成 SHA-1 签名
+// 客户端不带 RSA-SHA2 标志请求签名时（旧版 OpenSSH 或只支持 ssh-rsa 的服务器），RSA 密钥只能产生 ssh-rsa 签名
+type sha1Guard struct {
+	xagent.Agent
+}
+
+func (g *sha1Guard) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	if key.Type() == ssh.KeyAlgoRSA {
+		return nil, g.refuse(key)
+	}
+	return g.Agent.Sign(key, data)
+}
+
+func (g *sha1Guard) SignWithFlags(key ssh.PublicKey, data []byte, flags xagent.SignatureFlags) (*ssh.Signature, error) {
+	if key.Type() == ssh.KeyAlgoRSA && flags&(xagent.SignatureFlagRsaSha256|xagent.SignatureFlagRsaSha512) == 0 {
+		return nil, g.refuse(key)
+	}
+	ext, ok := g.Agent.(xagent.ExtendedAgent)
+	if !ok {
+		if flags != 0 {
+			return nil, errors.New("signature flags not supported")
+		}
+		return g.Agent.Sign(key, data)
+	}
+	return ext.SignWithFlags(key, data, flags)
+}
+
+func (g *sha1Guard) Extension(extensionType string, contents []byte) ([]byte, error) {
+	if ext, ok := g.Agent.(xagent.ExtendedAgent); ok {
+		return ext.Extension(extensionType, contents)
+	}
+	return nil, xagent.ErrExtensionUnsupported
+}
+
+func (g *sha1Guard) refuse(key ssh.PublicKey) error {
+	log.Warn("拒绝 ssh-rsa (SHA-1) 签名", map[string]interface{}{
+		"fingerprint": ssh.FingerprintSHA256(key),
+	})
+	return errSHA1Refused
+}
@@ -0,0 +1,128 @@
+package agentserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fssh/internal/usage"
+
+	"golang.org/x/crypto/ssh"
+	xagent "golang.org/x/crypto/ssh/agent"
+)
+
+// usageAgent 包装单个连接上的 agent，签名成功后记录使用统计
+// 目标主机来自 OpenSSH 的 session-bind@openssh.com 扩展（8.9+），用户名来自被签名的认证请求
+type usageAgent struct {
+	xagent.Agent
+	rec  *usage.Recorder
+	host string // 最近一次 session-bind 的主机名或主机密钥指纹
+}
+
+func (a *usageAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	sig, err := a.Agent.Sign(key, data)
+	if err == nil {
+		a.record(key, data)
+	}
+	return sig, err
+}
+
+func (a *usageAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags xagent.SignatureFlags) (*ssh.Signature, error) {
+	ext, ok := a.Agent.(xagent.ExtendedAgent)
+	if !ok {
+		if flags != 0 {
+			return nil, errors.New("signature flags not supported")
+		}
+		return a.Sign(key, data)
+	}
+	sig, err := ext.SignWithFlags(key, data, flags)
+	if err == nil {
+		a.record(key, data)
+	}
+	return sig, err
+}
+
+func (a *usageAgent) Extension(extensionType string, contents []byte) ([]byte, error) {
+	if extensionType == "session-bind@openssh.com" {
+		var bind struct {
+			HostKey    []byte
+			SessionID  []byte
+			Signature  []byte
+			Forwarding bool
+		}
+		if err := ssh.Unmarshal(contents, &bind); err == nil {
+			if hk, err := ssh.ParsePublicKey(bind.HostKey); err == nil {
+				a.host = knownHostName(hk)
+			}
+		}
+	}
+	if ext, ok := a.Agent.(xagent.ExtendedAgent); ok {
+		return ext.Extension(extensionType, contents)
+	}
+	return nil, xagent.ErrExtensionUnsupported
+}
+
+func (a *usageAgent) record(key ssh.PublicKey, data []byte) {
+	dest := a.host
+	if user := userauthUser(data); user != "" {
+		if dest == "" {
+			dest = user
+		} else {
+			dest = user + "@" + dest
+		}
+	}
+	a.rec.Record(ssh.FingerprintSHA256(key), dest)
+}
+
+// userauthUser 从 SSH_MSG_USERAUTH_REQUEST 签名数据中取出用户名
+// 格式：string session_id, byte 50, string user, ...；不是认证请求时返回空
+func userauthUser(data []byte) string {
+	readString := func(b []byte) ([]byte, []byte, bool) {
+		if len(b) < 4 {
+			return nil, nil, false
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint64(len(b)-4) < uint64(n) {
+			return nil, nil, false
+		}
+		return b[4 : 4+n], b[4+n:], true
+	}
+	_, rest, ok := readString(data)
+	if !ok || len(rest) == 0 || rest[0] != 50 {
+		return ""
+	}
+	user, _, ok := readString(rest[1:])
+	if !ok {
+		return ""
+	}
+	return string(user)
+}
+
+// knownHostName 在 ~/.ssh/known_hosts 中查找主机密钥对应的主机名
+// 找不到或主机名已被哈希时返回主机密钥指纹
+func knownHostName(hk ssh.PublicKey) string {
+	home, _ := os.UserHomeDir()
+	data, err := os.ReadFile(filepath.Join(home, ".ssh", "known_hosts"))
+	if err == nil {
+		want := hk.Marshal()
+		for len(data) > 0 {
+			_, hosts, key, _, rest, err := ssh.ParseKnownHosts(data)
+			if err != nil {
+				break
+			}
+			data = rest
+			if !bytes.Equal(key.Marshal(), want) {
+				continue
+			}
+			for _, h := range hosts {
+				if !strings.HasPrefix(h, "|") {
+					return h
+				}
+			}
+		}
+	}
+	return ssh.FingerprintSHA256(hk)
+}
@@ -8,19 +8,22 @@ import (
 	"path/filepath"
 	"time"
 
+	"fssh/internal/fsutil"
 	"fssh/internal/keychain"
+	"fssh/internal/profile"
 )
 
 // AuthMode 认证模式类型
 type AuthMode string
 
 const (
-	ModeTouchID AuthMode = "touchid"
-	ModeOTP     AuthMode = "otp"
+	ModeTouchID    AuthMode = "touchid"
+	ModeOTP        AuthMode = "otp"
+	ModePassphrase AuthMode = "passphrase"
 )
 
 // AuthProvider 统一认证接口
-// 为 Touch ID 和 OTP 两种认证方式提供统一的抽象
+// 为 Touch ID、OTP 和口令三种认证方式提供统一的抽象
 type AuthProvider interface {
 	// UnlockMasterKey 解锁并返回 master key
 	// 可能需要用户交互（Touch ID 或密码+验证码）
@@ -39,7 +42,7 @@ type AuthProvider interface {
 }
 
 // GetAuthProvider 自动选择并创建认证提供者
-// 根据 auth_mode.json 或系统环境自动选择 Touch ID 或 OTP
+// 根据 auth_mode.json 或系统环境自动选择 Touch ID、OTP 或口令
 func GetAuthProvider(masterKeyTTL int) (AuthProvider, error) {
 	mode, err := LoadMode()
 	if err != nil {
@@ -50,7 +53,7 @@ func GetAuthProvider(masterKeyTTL int) (AuthProvider, error) {
 	case ModeTouchID:
 		provider := NewTouchIDProvider()
 		if !provider.IsAvailable() {
-			return nil, errors.New("Touch ID 不可用，请运行: fssh switch-to-otp")
+			return nil, errors.New("Touch ID 不可用，请运行: fssh switch-mode --to otp")
 		}
 		return provider, nil
 
@@ -64,6 +67,13 @@ func GetAuthProvider(masterKeyTTL int) (AuthProvider, error) {
 		}
 		return provider, nil
 
+	case ModePassphrase:
+		provider := NewPassphraseProvider(masterKeyTTL)
+		if !provider.IsAvailable() {
+			return nil, errors.New("口令模式未配置，请运行: fssh init --mode passphrase")
+		}
+		return provider, nil
+
 	default:
 		return nil, fmt.Errorf("未知认证模式: %s", mode)
 	}
@@ -130,8 +140,8 @@ func SaveMode(mode AuthMode) error {
 		return fmt.Errorf("创建配置目录失败: %w", err)
 	}
 
-	// 写入文件
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	// 原子写入文件
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("保存认证模式配置失败: %w", err)
 	}
 
@@ -140,6 +150,5 @@ func SaveMode(mode AuthMode) error {
 
 // modeConfigPath 返回认证模式配置文件路径
 func modeConfigPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".fssh", "auth_mode.json")
+	return filepath.Join(profile.Dir(), "auth_mode.json")
 }
@@ -1,8 +1,8 @@
 package auth
 
 import (
-	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"runtime"
 	"sync"
@@ -11,8 +11,6 @@ import (
 	"fssh/internal/crypt"
 	"fssh/internal/log"
 	"fssh/internal/otp"
-
-	"golang.org/x/crypto/pbkdf2"
 )
 
 // OTPProvider OTP 认证提供者
@@ -71,35 +69,23 @@ func (p *OTPProvider) unlockSeed() ([]byte, error) {
 		return nil, fmt.Errorf("读取密码失败: %w", err)
 	}
 
-	// 2. 解码加密参数
-	seedSalt, err := base64.StdEncoding.DecodeString(p.config.SeedSalt)
-	if err != nil {
-		return nil, fmt.Errorf("解码 seed salt 失败: %w", err)
-	}
-
-	seedNonce, err := base64.StdEncoding.DecodeString(p.config.SeedNonce)
-	if err != nil {
-		return nil, fmt.Errorf("解码 seed nonce 失败: %w", err)
-	}
-
-	encryptedSeed, err := base64.StdEncoding.DecodeString(p.config.EncryptedSeed)
+	// 2. 解密 OTP seed（按配置中的 KDF 参数派生密钥）
+	log.Debug("派生解密密钥", map[string]interface{}{
+		"kdf": p.config.SeedKDF().String(),
+	})
+	seed, err := otp.DecryptSeed(p.config, password)
 	if err != nil {
-		return nil, fmt.Errorf("解码加密 seed 失败: %w", err)
+		return nil, err
 	}
 
-	// 3. 派生解密密钥（PBKDF2）
-	log.Debug("派生解密密钥（PBKDF2 100k 迭代）", nil)
-	encKey := pbkdf2.Key([]byte(password), seedSalt, 100000, 32, sha256.New)
+	log.Info("OTP seed 已解锁", nil)
 
-	// 4. 解密 OTP seed
-	seed, err := crypt.DecryptAEAD(encKey, seedNonce, encryptedSeed, nil)
-	if err != nil {
-		return nil, fmt.Errorf("密码错误或配置文件损坏")
+	// 旧版 KDF 参数：借助刚输入的密码透明升级
+	if otp.NeedsKDFUpgrade(p.config) {
+		p.upgradeKDF(seed, password)
 	}
 
-	log.Info("OTP seed 已解锁", nil)
-
-	// 5. 更新缓存
+	// 3. 更新缓存
 	p.cachedSeed = seed
 	if ttl > 0 {
 		p.seedExpiry = time.Now().Add(time.Duration(ttl) * time.Second)
@@ -115,6 +101,35 @@ func (p *OTPProvider) unlockSeed() ([]byte, error) {
 	return seed, nil
 }
 
+// upgradeKDF 使用当前默认 KDF 参数重新加密 OTP seed
+// 调用方需持有 p.mu；升级失败不影响本次解锁
+func (p *OTPProvider) upgradeKDF(seed []byte, password string) {
+	old := p.config
+	var updated *otp.Config
+	err := otp.UpdateConfig(func(cfg *otp.Config) error {
+		if cfg.EncryptedSeed != old.EncryptedSeed {
+			return fmt.Errorf("OTP 配置已被修改")
+		}
+		if err := otp.SealSeed(cfg, seed, password); err != nil {
+			return err
+		}
+		updated = cfg
+		return nil
+	})
+	if err != nil {
+		log.Warn("升级 OTP seed KDF 参数失败", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	p.config = updated
+	log.Info("OTP seed KDF 参数已升级", map[string]interface{}{
+		"from": old.SeedKDF().String(),
+		"to":   updated.SeedKDF().String(),
+	})
+}
+
 // UnlockMasterKey 实现 AuthProvider 接口
 // 这是完整的双层认证流程：
 // 1. 密码解锁 OTP seed（可能使用缓存）
@@ -147,14 +162,13 @@ func (p *OTPProvider) UnlockMasterKey() ([]byte, error) {
 		return nil, fmt.Errorf("读取验证码失败: %w", err)
 	}
 
-	// 3. 验证 TOTP
-	log.Debug("验证 TOTP 验证码", nil)
-	valid := otp.Verify(seed, code, p.config.Algorithm, p.config.Digits, p.config.Period)
-	if !valid {
-		return nil, fmt.Errorf("验证码错误或已过期")
+	// 3. 验证 TOTP/HOTP（含重放保护）
+	log.Debug("验证 OTP 验证码", map[string]interface{}{"type": p.config.OTPType()})
+	if err := p.verifyCode(seed, code); err != nil {
+		return nil, err
 	}
 
-	log.Info("TOTP 验证成功", nil)
+	log.Info("OTP 验证成功", nil)
 
 	// 4. 从 seed 派生 master key
 	masterKeySalt, err := base64.StdEncoding.DecodeString(p.config.MasterKeySalt)
@@ -180,6 +194,82 @@ func (p *OTPProvider) UnlockMasterKey() ([]byte, error) {
 	return masterKey, nil
 }
 
+// nearMissReportThreshold 连续多少次出现相同的时钟偏移后提示用户
+const nearMissReportThreshold = 2
+
+// verifyCode 验证 TOTP/HOTP 验证码并持久化已使用的计数器
+// 计数器写入配置文件，同一验证码无法在其他进程或重启后被重放
+func (p *OTPProvider) verifyCode(seed []byte, code string) error {
+	var updated *otp.Config
+	var verifyErr error
+
+	err := otp.UpdateConfig(func(cfg *otp.Config) error {
+		if cfg.OTPType() == otp.TypeHOTP {
+			// HOTP：在配置锁内验证并推进计数器，同一验证码无法使用两次
+			err := otp.CheckHOTP(cfg, seed, code)
+			switch {
+			case err == nil:
+			case errors.Is(err, otp.ErrHOTPResync):
+				log.Warn("HOTP 验证码超出前向窗口，等待下一个验证码重新同步", map[string]interface{}{
+					"counter":        cfg.HOTPCounter,
+					"resync_counter": cfg.HOTPResyncCounter,
+				})
+				verifyErr = err
+			default:
+				return err
+			}
+			updated = cfg
+			return nil
+		}
+
+		counter, err := otp.VerifyCounter(seed, code, cfg.Algorithm, cfg.Digits, cfg.Period, cfg.SkewSteps, cfg.LastUsedCounter)
+		switch {
+		case err == nil:
+			cfg.LastUsedCounter = counter
+			cfg.NearMissSteps = 0
+			cfg.NearMissCount = 0
+
+		case errors.Is(err, otp.ErrInvalidCode):
+			// 验证码可能来自时钟偏移较大的认证器，记录偏移用于诊断
+			steps, ok := otp.DetectDrift(seed, code, cfg.Algorithm, cfg.Digits, cfg.Period, cfg.SkewSteps)
+			if !ok {
+				return err
+			}
+			if steps == cfg.NearMissSteps {
+				cfg.NearMissCount++
+			} else {
+				cfg.NearMissSteps = steps
+				cfg.NearMissCount = 1
+			}
+			log.Warn("TOTP 验证码超出时钟偏移窗口", map[string]interface{}{
+				"offset_seconds": steps * cfg.Period,
+				"count":          cfg.NearMissCount,
+			})
+			verifyErr = err
+			if cfg.NearMissCount >= nearMissReportThreshold {
+				verifyErr = fmt.Errorf("%w（检测到时钟偏移约 %d 秒，请同步本机或认证器的时间）", err, steps*cfg.Period)
+			}
+
+		default:
+			return err
+		}
+		updated = cfg
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, otp.ErrCodeReused) {
+			log.Warn("拒绝重放的 TOTP 验证码", nil)
+		}
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = updated
+	p.mu.Unlock()
+
+	return verifyErr
+}
+
 // IsAvailable 实现 AuthProvider 接口
 func (p *OTPProvider) IsAvailable() bool {
 	return p.config != nil && otp.ConfigExists()
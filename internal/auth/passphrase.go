@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fssh/internal/crypt"
+	"fssh/internal/fsutil"
+	"fssh/internal/log"
+	"fssh/internal/otp"
+	"fssh/internal/profile"
+)
+
+// passphraseConfig 口令模式配置文件结构
+// master key 由口令派生的密钥加密保存
+type passphraseConfig struct {
+	Version    string          `json:"version"`
+	KDF        crypt.KDFParams `json:"kdf"`
+	Salt       string          `json:"salt"`        // Base64 编码的 KDF salt (32 bytes)
+	Nonce      string          `json:"nonce"`       // Base64 编码的 AES-GCM nonce (12 bytes)
+	WrappedKey string          `json:"wrapped_key"` // Base64 编码的加密 master key
+	CreatedAt  string          `json:"created_at"`
+}
+
+// PassphraseConfigPath 返回口令模式配置文件路径
+func PassphraseConfigPath() string {
+	return filepath.Join(profile.Dir(), "passphrase.json")
+}
+
+// PassphraseConfigExists 检查口令模式配置是否存在
+func PassphraseConfigExists() bool {
+	_, err := os.Stat(PassphraseConfigPath())
+	return err == nil
+}
+
+// InitPassphrase 使用口令加密 master key 并保存
+func InitPassphrase(masterKey []byte, passphrase string) error {
+	data, err := SealPassphraseConfig(masterKey, passphrase)
+	if err != nil {
+		return err
+	}
+	return WritePassphraseConfig(data)
+}
+
+// SealPassphraseConfig 使用口令加密 master key，返回口令配置文件内容（不写入磁盘）
+func SealPassphraseConfig(masterKey []byte, passphrase string) ([]byte, error) {
+	kdf := crypt.DefaultKDFParams()
+	salt, err := crypt.RandBytes(rand.Reader, 32)
+	if err != nil {
+		return nil, fmt.Errorf("生成 salt 失败: %w", err)
+	}
+	nonce, err := crypt.RandBytes(rand.Reader, 12)
+	if err != nil {
+		return nil, fmt.Errorf("生成 nonce 失败: %w", err)
+	}
+	key, err := kdf.DeriveKey([]byte(passphrase), salt, 32)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := crypt.EncryptAEAD(key, nonce, masterKey, []byte("fssh-passphrase/v1"))
+	if err != nil {
+		return nil, fmt.Errorf("加密 master key 失败: %w", err)
+	}
+
+	cfg := passphraseConfig{
+		Version:    "fssh-passphrase/v1",
+		KDF:        kdf,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		WrappedKey: base64.StdEncoding.EncodeToString(ct),
+		CreatedAt:  time.Now().Format(time.RFC3339),
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化口令配置失败: %w", err)
+	}
+	return data, nil
+}
+
+// WritePassphraseConfig 原子地写入口令配置文件
+func WritePassphraseConfig(data []byte) error {
+	path := PassphraseConfigPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("创建配置目录失败: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("保存口令配置失败: %w", err)
+	}
+	return nil
+}
+
+// UnwrapWithPassphrase 使用口令解密 master key
+func UnwrapWithPassphrase(passphrase string) ([]byte, error) {
+	data, err := os.ReadFile(PassphraseConfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("读取口令配置失败: %w", err)
+	}
+	var cfg passphraseConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("解析口令配置失败: %w", err)
+	}
+	if cfg.Version != "fssh-passphrase/v1" {
+		return nil, fmt.Errorf("不支持的口令配置版本: %s", cfg.Version)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(cfg.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("解码 salt 失败: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(cfg.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("解码 nonce 失败: %w", err)
+	}
+	ct, err := base64.StdEncoding.DecodeString(cfg.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("解码 master key 密文失败: %w", err)
+	}
+
+	key, err := cfg.KDF.DeriveKey([]byte(passphrase), salt, 32)
+	if err != nil {
+		return nil, err
+	}
+	mk, err := crypt.DecryptAEAD(key, nonce, ct, []byte("fssh-passphrase/v1"))
+	if err != nil {
+		return nil, errors.New("口令错误或配置文件损坏")
+	}
+	return mk, nil
+}
+
+// PassphraseProvider 口令认证提供者
+// 不依赖 Touch ID 或认证器，master key 仅由口令保护
+type PassphraseProvider struct {
+	mu              sync.Mutex
+	cachedMasterKey []byte
+	masterKeyExpiry time.Time
+	masterKeyTTL    int // Master key 缓存时间（秒）
+}
+
+// NewPassphraseProvider 创建口令认证提供者
+func NewPassphraseProvider(masterKeyTTL int) *PassphraseProvider {
+	return &PassphraseProvider{masterKeyTTL: masterKeyTTL}
+}
+
+// UnlockMasterKey 实现 AuthProvider 接口
+func (p *PassphraseProvider) UnlockMasterKey() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.masterKeyTTL > 0 && time.Now().Before(p.masterKeyExpiry) {
+		log.Debug("Master key 缓存命中", map[string]interface{}{
+			"expires_at": p.masterKeyExpiry.UTC().Format(time.RFC3339),
+		})
+		return p.cachedMasterKey, nil
+	}
+
+	passphrase, err := otp.PromptPassword("请输入主口令: ")
+	if err != nil {
+		return nil, fmt.Errorf("读取口令失败: %w", err)
+	}
+	mk, err := UnwrapWithPassphrase(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.masterKeyTTL > 0 {
+		p.cachedMasterKey = mk
+		p.masterKeyExpiry = time.Now().Add(time.Duration(p.masterKeyTTL) * time.Second)
+		log.Info("Master key 已缓存", map[string]interface{}{
+			"ttl_seconds": p.masterKeyTTL,
+			"expires_at":  p.masterKeyExpiry.UTC().Format(time.RFC3339),
+		})
+	}
+	return mk, nil
+}
+
+// IsAvailable 实现 AuthProvider 接口
+func (p *PassphraseProvider) IsAvailable() bool {
+	return PassphraseConfigExists()
+}
+
+// Mode 实现 AuthProvider 接口
+func (p *PassphraseProvider) Mode() AuthMode {
+	return ModePassphrase
+}
+
+// ClearCache 实现 AuthProvider 接口
+func (p *PassphraseProvider) ClearCache() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cachedMasterKey != nil {
+		secureClear(p.cachedMasterKey)
+		p.cachedMasterKey = nil
+	}
+	p.masterKeyExpiry = time.Time{}
+}
@@ -0,0 +1,323 @@
+// Package ca 用密钥库中的密钥作为 SSH 证书颁发机构签发 OpenSSH 用户和主机证书
+// 每个 CA（按公钥指纹）的证书序列号计数器保存在 profile 目录下的 ca/state.json，
+// 每张签发的证书追加到 ca/issued.jsonl，供审计和生成吊销列表使用
+package ca
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"fssh/internal/fsutil"
+	"fssh/internal/profile"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const stateVersion = "fssh-ca/v1"
+
+// 证书类型
+const (
+	TypeUser = "user"
+	TypeHost = "host"
+)
+
+// clockSkew 证书生效时间提前量，避免服务器时钟略慢时新证书尚未生效
+const clockSkew = 5 * time.Minute
+
+// DefaultUserExtensions 与 ssh-keygen 默认相同的用户证书扩展
+var DefaultUserExtensions = []string{
+	"permit-X11-forwarding",
+	"permit-agent-forwarding",
+	"permit-port-forwarding",
+	"permit-pty",
+	"permit-user-rc",
+}
+
+// knownExtensions OpenSSH 支持的用户证书扩展；带 @ 的厂商扩展不检查
+var knownExtensions = map[string]bool{
+	"no-touch-required":       true,
+	"permit-X11-forwarding":   true,
+	"permit-agent-forwarding": true,
+	"permit-port-forwarding":  true,
+	"permit-pty":              true,
+	"permit-user-rc":          true,
+}
+
+// Request 一次签发请求
+type Request struct {
+	Type            string // TypeUser 或 TypeHost
+	KeyID           string
+	Principals      []string
+	Validity        time.Duration // 0 表示永久有效
+	CriticalOptions map[string]string
+	Extensions      map[string]string
+}
+
+// Issued 签发日志中的一条记录
+type Issued struct {
+	Time            string            `json:"time"`
+	CA              string            `json:"ca"` // 签发时的 CA 别名
+	CAFingerprint   string            `json:"ca_fingerprint"`
+	Serial          uint64            `json:"serial"`
+	Type            string            `json:"type"`
+	KeyID           string            `json:"key_id"`
+	KeyFingerprint  string            `json:"key_fingerprint"`
+	Principals      []string          `json:"principals"`
+	ValidAfter      string            `json:"valid_after"`
+	ValidBefore     string            `json:"valid_before"` // forever 表示永久有效
+	CriticalOptions map[string]string `json:"critical_options,omitempty"`
+	Extensions      map[string]string `json:"extensions,omitempty"`
+}
+
+// state ca/state.json 的内容
+type state struct {
+	Version string            `json:"version"`
+	Serials map[string]uint64 `json:"serials"` // CA 指纹 -> 最近签发的序列号
+}
+
+// Dir 返回当前 profile 的 CA 数据目录
+func Dir() string {
+	return filepath.Join(profile.Dir(), "ca")
+}
+
+func statePath() string { return filepath.Join(Dir(), "state.json") }
+
+// LogPath 返回签发日志路径
+func LogPath() string { return filepath.Join(Dir(), "issued.jsonl") }
+
+// Validate 检查请求：主机证书不能带选项和扩展，关键选项只接受 OpenSSH 支持的名称
+func (r *Request) Validate() error {
+	switch r.Type {
+	case TypeUser, TypeHost:
+	default:
+		return fmt.Errorf("unsupported certificate type %q", r.Type)
+	}
+	if len(r.Principals) == 0 {
+		return errors.New("at least one principal is required")
+	}
+	if r.Validity < 0 {
+		return errors.New("validity must not be negative")
+	}
+	if r.Type == TypeHost {
+		if len(r.CriticalOptions) > 0 || len(r.Extensions) > 0 {
+			return errors.New("host certificates do not support critical options or extensions")
+		}
+		return nil
+	}
+	for name, value := range r.CriticalOptions {
+		switch name {
+		case "force-command":
+			if value == "" {
+				return errors.New("force-command requires a command")
+			}
+		case "source-address":
+			if err := checkSourceAddress(value); err != nil {
+				return err
+			}
+		case "verify-required":
+			if value != "" {
+				return errors.New("verify-required does not take a value")
+			}
+		default:
+			return fmt.Errorf("unsupported critical option %q (supported: force-command, source-address, verify-required)", name)
+		}
+	}
+	for name := range r.Extensions {
+		if !knownExtensions[name] && !strings.Contains(name, "@") {
+			return fmt.Errorf("unknown extension %q (vendor extensions must contain @)", name)
+		}
+	}
+	return nil
+}
+
+// checkSourceAddress source-address 是逗号分隔的 CIDR 或 IP 地址
+func checkSourceAddress(value string) error {
+	if value == "" {
+		return errors.New("source-address requires a list of addresses")
+	}
+	for _, a := range strings.Split(value, ",") {
+		if _, _, err := net.ParseCIDR(a); err == nil {
+			continue
+		}
+		if net.ParseIP(a) == nil {
+			return fmt.Errorf("source-address: invalid address %q", a)
+		}
+	}
+	return nil
+}
+
+// Sign 用 CA 签发证书，分配下一个序列号并写入签发日志
+// 序列号分配、签名和记录在同一个文件锁内完成，并发签发不会得到相同的序列号
+func Sign(authority ssh.Signer, caAlias string, pub ssh.PublicKey, req Request) (*ssh.Certificate, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if _, ok := pub.(*ssh.Certificate); ok {
+		return nil, errors.New("the key to sign is already a certificate")
+	}
+	caFP := ssh.FingerprintSHA256(authority.PublicKey())
+	if caFP == ssh.FingerprintSHA256(pub) {
+		return nil, errors.New("refusing to sign the CA key itself")
+	}
+
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             pub,
+		KeyId:           req.KeyID,
+		ValidPrincipals: req.Principals,
+		ValidAfter:      uint64(now.Add(-clockSkew).Unix()),
+		ValidBefore:     ssh.CertTimeInfinity,
+		CertType:        ssh.UserCert,
+		Permissions: ssh.Permissions{
+			CriticalOptions: req.CriticalOptions,
+			Extensions:      req.Extensions,
+		},
+	}
+	if req.Type == TypeHost {
+		cert.CertType = ssh.HostCert
+	}
+	if req.Validity > 0 {
+		cert.ValidBefore = uint64(now.Add(req.Validity).Unix())
+	}
+
+	err := withLock(func() error {
+		st, err := loadState()
+		if err != nil {
+			return err
+		}
+		cert.Serial = st.Serials[caFP] + 1
+		if err := cert.SignCert(rand.Reader, authority); err != nil {
+			return err
+		}
+		st.Serials[caFP] = cert.Serial
+		data, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := fsutil.WriteFileAtomic(statePath(), data, 0600); err != nil {
+			return err
+		}
+		return appendLog(&Issued{
+			Time:            now.Format(time.RFC3339),
+			CA:              caAlias,
+			CAFingerprint:   caFP,
+			Serial:          cert.Serial,
+			Type:            req.Type,
+			KeyID:           req.KeyID,
+			KeyFingerprint:  ssh.FingerprintSHA256(pub),
+			Principals:      req.Principals,
+			ValidAfter:      FormatCertTime(cert.ValidAfter),
+			ValidBefore:     FormatCertTime(cert.ValidBefore),
+			CriticalOptions: req.CriticalOptions,
+			Extensions:      req.Extensions,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// FormatCertTime 把证书时间格式化为 RFC3339，永久有效时返回 forever
+func FormatCertTime(t uint64) string {
+	if t == ssh.CertTimeInfinity {
+		return "forever"
+	}
+	return time.Unix(int64(t), 0).Format(time.RFC3339)
+}
+
+// LoadLog 读取签发日志，按签发顺序返回；日志不存在时返回空
+func LoadLog() ([]*Issued, error) {
+	f, err := os.Open(LogPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []*Issued
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var is Issued
+		if err := json.Unmarshal(sc.Bytes(), &is); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", LogPath(), line, err)
+		}
+		out = append(out, &is)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func loadState() (*state, error) {
+	st := &state{Version: stateVersion, Serials: map[string]uint64{}}
+	data, err := os.ReadFile(statePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", statePath(), err)
+	}
+	if st.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported CA state version: %s", st.Version)
+	}
+	if st.Serials == nil {
+		st.Serials = map[string]uint64{}
+	}
+	return st, nil
+}
+
+// appendLog 追加一条签发记录并同步到磁盘
+func appendLog(is *Issued) error {
+	data, err := json.Marshal(is)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(LogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// withLock 在 ca 目录的文件锁内执行 fn
+func withLock(fn func() error) error {
+	if err := os.MkdirAll(Dir(), 0700); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(filepath.Join(Dir(), ".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+	return fn()
+}
@@ -6,30 +6,38 @@ import (
     "os"
     "path/filepath"
     "strings"
+
+    "fssh/internal/keypolicy"
+    "fssh/internal/profile"
 )
 
 type Config struct {
-    Socket               string `json:"socket"`
-    RequireTouchPerSign  bool   `json:"require_touch_id_per_sign"`
-    LogOut               string `json:"log_out"`
-    LogErr               string `json:"log_err"`
-    UnlockTTLSeconds     int    `json:"unlock_ttl_seconds"`
-    LogLevel             string `json:"log_level"`
-    LogFormat            string `json:"log_format"`
-    LogTimeFormat        string `json:"log_time_format"`
+    Socket               string           `json:"socket"`
+    RequireTouchPerSign  bool             `json:"require_touch_id_per_sign"`
+    LogOut               string           `json:"log_out"`
+    LogErr               string           `json:"log_err"`
+    UnlockTTLSeconds     int              `json:"unlock_ttl_seconds"`
+    LogLevel             string           `json:"log_level"`
+    LogFormat            string           `json:"log_format"`
+    LogTimeFormat        string           `json:"log_time_format"`
+    Vault                string           `json:"vault"`             // 单文件密钥保险库路径，为空时使用 profile 下的 keys 目录
+    AgentTags            []string         `json:"agent_tags"`        // agent 只提供带有全部这些标签的密钥
+    KeyPolicy            keypolicy.Policy `json:"key_policy"`        // 导入和生成密钥时检查的强度策略
+    AgentRejectSHA1      bool             `json:"agent_reject_sha1"` // agent 拒绝 ssh-rsa（SHA-1）签名请求
+}
+
+// DefaultSocket 返回当前 profile 的默认 agent socket 路径
+func DefaultSocket() string {
+    return filepath.Join(profile.Dir(), "agent.sock")
 }
 
-func defaultSocket() string {
-    home, _ := os.UserHomeDir()
-    return filepath.Join(home, ".fssh", "agent.sock")
+// Path 返回当前 profile 的 config.json 路径
+func Path() string {
+    return filepath.Join(profile.Dir(), "config.json")
 }
 
 func Load() (*Config, error) {
-    home, err := os.UserHomeDir()
-    if err != nil {
-        return nil, err
-    }
-    p := filepath.Join(home, ".fssh", "config.json")
+    p := Path()
     b, err := os.ReadFile(p)
     if err != nil {
         if errors.Is(err, os.ErrNotExist) {
@@ -46,12 +54,13 @@ func Load() (*Config, error) {
     c.Socket = expandHome(c.Socket)
     c.LogOut = expandHome(c.LogOut)
     c.LogErr = expandHome(c.LogErr)
+    c.Vault = expandHome(c.Vault)
     c.ApplyDefaults()
     return &c, nil
 }
 
 func (c *Config) ApplyDefaults() {
-    if c.Socket == "" { c.Socket = defaultSocket() }
+    if c.Socket == "" { c.Socket = DefaultSocket() }
     // default true unless explicitly set false in file
     if !c.RequireTouchPerSign { c.RequireTouchPerSign = true }
     if c.LogLevel == "" { c.LogLevel = "info" }
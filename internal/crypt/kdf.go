@@ -0,0 +1,145 @@
+package crypt
+
+import (
+    "crypto/sha256"
+    "errors"
+    "fmt"
+    "runtime"
+    "time"
+
+    "golang.org/x/crypto/argon2"
+    "golang.org/x/crypto/pbkdf2"
+    "golang.org/x/crypto/scrypt"
+)
+
+// 支持的口令派生算法
+const (
+    KDFPBKDF2SHA256 = "pbkdf2-sha256"
+    KDFScrypt       = "scrypt"
+    KDFArgon2id     = "argon2id"
+)
+
+// KDFParams 口令派生算法及其参数，随密文一起保存以便日后升级
+type KDFParams struct {
+    Algorithm string `json:"algorithm"`
+
+    // pbkdf2-sha256
+    Iterations int `json:"iterations,omitempty"`
+
+    // scrypt
+    N int `json:"n,omitempty"`
+    R int `json:"r,omitempty"`
+    P int `json:"p,omitempty"`
+
+    // argon2id
+    Time      uint32 `json:"time,omitempty"`
+    MemoryKiB uint32 `json:"memory_kib,omitempty"`
+    Threads   uint8  `json:"threads,omitempty"`
+}
+
+// LegacyKDFParams 早期版本硬编码的参数：PBKDF2-SHA256 100,000 次迭代
+func LegacyKDFParams() KDFParams {
+    return KDFParams{Algorithm: KDFPBKDF2SHA256, Iterations: 100000}
+}
+
+// DefaultKDFParams 新配置使用的默认参数：Argon2id t=3 m=64MiB p=4
+func DefaultKDFParams() KDFParams {
+    return KDFParams{Algorithm: KDFArgon2id, Time: 3, MemoryKiB: 64 * 1024, Threads: 4}
+}
+
+// IsLegacy 是否为需要升级的旧算法
+func (p KDFParams) IsLegacy() bool {
+    return p.Algorithm == KDFPBKDF2SHA256
+}
+
+func (p KDFParams) Validate() error {
+    switch p.Algorithm {
+    case KDFPBKDF2SHA256:
+        if p.Iterations < 1 { return errors.New("pbkdf2: iterations must be positive") }
+    case KDFScrypt:
+        if p.N < 2 || p.N&(p.N-1) != 0 { return errors.New("scrypt: N must be a power of two") }
+        if p.R < 1 || p.P < 1 { return errors.New("scrypt: r and p must be positive") }
+    case KDFArgon2id:
+        if p.Time < 1 || p.MemoryKiB < 8*uint32(p.Threads) || p.Threads < 1 {
+            return errors.New("argon2id: invalid time/memory/threads")
+        }
+    default:
+        return fmt.Errorf("unsupported kdf: %s", p.Algorithm)
+    }
+    return nil
+}
+
+// DeriveKey 从口令派生 keyLen 字节的密钥
+func (p KDFParams) DeriveKey(password []byte, salt []byte, keyLen int) ([]byte, error) {
+    if err := p.Validate(); err != nil {
+        return nil, err
+    }
+    switch p.Algorithm {
+    case KDFPBKDF2SHA256:
+        return pbkdf2.Key(password, salt, p.Iterations, keyLen, sha256.New), nil
+    case KDFScrypt:
+        return scrypt.Key(password, salt, p.N, p.R, p.P, keyLen)
+    default:
+        return argon2.IDKey(password, salt, p.Time, p.MemoryKiB, p.Threads, uint32(keyLen)), nil
+    }
+}
+
+func (p KDFParams) String() string {
+    switch p.Algorithm {
+    case KDFPBKDF2SHA256:
+        return fmt.Sprintf("%s iterations=%d", p.Algorithm, p.Iterations)
+    case KDFScrypt:
+        return fmt.Sprintf("%s N=%d r=%d p=%d", p.Algorithm, p.N, p.R, p.P)
+    case KDFArgon2id:
+        return fmt.Sprintf("%s t=%d m=%dMiB p=%d", p.Algorithm, p.Time, p.MemoryKiB/1024, p.Threads)
+    }
+    return p.Algorithm
+}
+
+// 基准测试时允许使用的最大内存，避免 agent 常驻时占用过多
+const (
+    benchMaxArgon2MemoryKiB = 256 * 1024
+    benchMaxScryptN         = 1 << 20
+)
+
+// BenchmarkKDF 在本机上测量并选择耗时约为 target 的参数
+// argon2id 先增加内存再增加迭代次数；scrypt 翻倍 N 直到接近目标耗时
+func BenchmarkKDF(algorithm string, target time.Duration) (KDFParams, time.Duration, error) {
+    switch algorithm {
+    case KDFArgon2id:
+        threads := runtime.NumCPU()
+        if threads > 4 { threads = 4 }
+        p := KDFParams{Algorithm: KDFArgon2id, Time: 1, MemoryKiB: 64 * 1024, Threads: uint8(threads)}
+        d, err := measureKDF(p)
+        if err != nil { return p, 0, err }
+        for d*2 <= target && p.MemoryKiB*2 <= benchMaxArgon2MemoryKiB {
+            p.MemoryKiB *= 2
+            if d, err = measureKDF(p); err != nil { return p, 0, err }
+        }
+        if d < target {
+            p.Time = uint32((target + d/2) / d)
+            if p.Time < 1 { p.Time = 1 }
+            if d, err = measureKDF(p); err != nil { return p, 0, err }
+        }
+        return p, d, nil
+    case KDFScrypt:
+        p := KDFParams{Algorithm: KDFScrypt, N: 1 << 14, R: 8, P: 1}
+        d, err := measureKDF(p)
+        if err != nil { return p, 0, err }
+        for d*3/2 < target && p.N*2 <= benchMaxScryptN {
+            p.N *= 2
+            if d, err = measureKDF(p); err != nil { return p, 0, err }
+        }
+        return p, d, nil
+    }
+    return KDFParams{}, 0, fmt.Errorf("unsupported kdf for benchmark: %s", algorithm)
+}
+
+func measureKDF(p KDFParams) (time.Duration, error) {
+    salt := make([]byte, 32)
+    start := time.Now()
+    if _, err := p.DeriveKey([]byte("fssh-kdf-bench"), salt, 32); err != nil {
+        return 0, err
+    }
+    return time.Since(start), nil
+}
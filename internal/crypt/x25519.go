@@ -0,0 +1,54 @@
+package crypt
+
+import (
+    "crypto/ecdh"
+    "crypto/ed25519"
+    "crypto/sha512"
+    "errors"
+    "math/big"
+)
+
+// curve25519P 域的素数 2^255 - 19
+var curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// Ed25519PublicToX25519 把 Ed25519 公钥转换为 X25519 公钥（与 age、libsodium 相同的双有理映射 u = (1+y)/(1-y)）
+func Ed25519PublicToX25519(pub ed25519.PublicKey) (*ecdh.PublicKey, error) {
+    if len(pub) != ed25519.PublicKeySize {
+        return nil, errors.New("invalid ed25519 public key")
+    }
+    // 小端编码的 y 坐标，最高位是 x 的符号位
+    le := make([]byte, len(pub))
+    for i, b := range pub {
+        le[len(pub)-1-i] = b
+    }
+    le[0] &= 0x7f
+    y := new(big.Int).SetBytes(le)
+    if y.Cmp(curve25519P) >= 0 {
+        return nil, errors.New("invalid ed25519 public key")
+    }
+    one := big.NewInt(1)
+    den := new(big.Int).Sub(one, y)
+    den.Mod(den, curve25519P)
+    if den.Sign() == 0 {
+        return nil, errors.New("invalid ed25519 public key")
+    }
+    u := new(big.Int).Add(one, y)
+    u.Mul(u, den.ModInverse(den, curve25519P))
+    u.Mod(u, curve25519P)
+
+    out := make([]byte, 32)
+    u.FillBytes(out)
+    for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+        out[i], out[j] = out[j], out[i]
+    }
+    return ecdh.X25519().NewPublicKey(out)
+}
+
+// Ed25519PrivateToX25519 把 Ed25519 私钥转换为对应的 X25519 私钥（种子 SHA-512 的前 32 字节，计算时由 X25519 钳位）
+func Ed25519PrivateToX25519(priv ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+    if len(priv) != ed25519.PrivateKeySize {
+        return nil, errors.New("invalid ed25519 private key")
+    }
+    h := sha512.Sum512(priv.Seed())
+    return ecdh.X25519().NewPrivateKey(h[:32])
+}
@@ -0,0 +1,102 @@
+// Package escrow 实现 Shamir 秘密分享，用于把 master key 等秘密拆分给多人保管
+// 任意 k 份即可恢复秘密，少于 k 份得不到任何信息
+package escrow
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// gfMul GF(2^8) 乘法（AES 多项式 x^8+x^4+x^3+x+1），不使用查表以避免时序侧信道
+func gfMul(a, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		p ^= -(b & 1) & a
+		hi := a >> 7
+		a = a<<1 ^ (0x1b & -hi)
+		b >>= 1
+	}
+	return p
+}
+
+// gfInv GF(2^8) 乘法逆元，a^254 = a^-1
+func gfInv(a byte) byte {
+	r := byte(1)
+	for i := 0; i < 7; i++ {
+		a = gfMul(a, a)
+		r = gfMul(r, a)
+	}
+	return r
+}
+
+// split 把 secret 拆分为 n 份，x 坐标为 1..n，任意 k 份可恢复
+func split(secret []byte, n, k int) ([][]byte, error) {
+	if k < 2 || k > n || n > 255 {
+		return nil, fmt.Errorf("invalid threshold: need 2 <= k <= n <= 255 (n=%d, k=%d)", n, k)
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("secret is empty")
+	}
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret))
+	}
+	coeffs := make([]byte, k)
+	for pos, s := range secret {
+		// 常数项为秘密字节，其余系数随机
+		coeffs[0] = s
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			x := byte(i + 1)
+			// Horner 法求值
+			var y byte
+			for j := k - 1; j >= 0; j-- {
+				y = gfMul(y, x) ^ coeffs[j]
+			}
+			shares[i][pos] = y
+		}
+	}
+	for i := range coeffs {
+		coeffs[i] = 0
+	}
+	return shares, nil
+}
+
+// combine 用拉格朗日插值求多项式在 0 处的值
+func combine(xs []byte, ys [][]byte) ([]byte, error) {
+	if len(xs) == 0 || len(xs) != len(ys) {
+		return nil, errors.New("no shares")
+	}
+	size := len(ys[0])
+	for i := range xs {
+		if xs[i] == 0 {
+			return nil, errors.New("invalid share index 0")
+		}
+		if len(ys[i]) != size {
+			return nil, errors.New("shares have different lengths")
+		}
+		for j := 0; j < i; j++ {
+			if xs[i] == xs[j] {
+				return nil, fmt.Errorf("duplicate share #%d", xs[i])
+			}
+		}
+	}
+	secret := make([]byte, size)
+	for i := range xs {
+		// basis = Π x_j / (x_j - x_i)，GF(2^8) 中减法即异或
+		basis := byte(1)
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			basis = gfMul(basis, gfMul(xs[j], gfInv(xs[j]^xs[i])))
+		}
+		for pos := 0; pos < size; pos++ {
+			secret[pos] ^= gfMul(ys[i][pos], basis)
+		}
+	}
+	return secret, nil
+}
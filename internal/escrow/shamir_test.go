@@ -0,0 +1,125 @@
+package escrow
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+)
+
+func TestGFInverse(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if got := gfMul(byte(a), gfInv(byte(a))); got != 1 {
+			t.Fatalf("a * inv(a) = %#x for a = %#x", got, a)
+		}
+	}
+}
+
+// 多项式 f(x) = 0x42 + x：f(1) = 0x43，f(2) = 0x40（GF(2^8) 中加法为异或）
+func TestCombineKnownAnswer(t *testing.T) {
+	got, err := combine([]byte{1, 2}, [][]byte{{0x43}, {0x40}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte{0x42}) {
+		t.Fatalf("combine() = %x, want 42", got)
+	}
+}
+
+func TestSplitCombineEverySubset(t *testing.T) {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	const n, k = 5, 3
+	shares, err := Split(KindMasterKey, secret, n, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for mask := 0; mask < 1<<n; mask++ {
+		var subset []*Share
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 {
+				subset = append(subset, shares[i])
+			}
+		}
+		got, err := Combine(subset)
+		if len(subset) < k {
+			if err == nil {
+				t.Fatalf("combined %d shares below threshold %d", len(subset), k)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("mask %05b: %v", mask, err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Fatalf("mask %05b: recovered a different secret", mask)
+		}
+	}
+}
+
+func TestSplitRejectsBadThreshold(t *testing.T) {
+	for _, tc := range []struct{ n, k int }{{3, 1}, {2, 3}, {256, 2}} {
+		if _, err := Split(KindMasterKey, []byte("secret"), tc.n, tc.k); err == nil {
+			t.Fatalf("Split(n=%d, k=%d) succeeded", tc.n, tc.k)
+		}
+	}
+	if _, err := Split(KindMasterKey, nil, 3, 2); err == nil {
+		t.Fatal("Split of an empty secret succeeded")
+	}
+}
+
+func TestShareWordsRoundTrip(t *testing.T) {
+	shares, err := Split(KindBackupPassphrase, []byte("correct horse battery staple"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed []*Share
+	for _, s := range shares {
+		// 打印的词列表可以换行、改大小写或用空格分隔
+		text := strings.ToUpper(strings.Join(s.Words(), " \n"))
+		p, err := ParseShare(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Kind != s.Kind || p.Threshold != s.Threshold || p.Index != s.Index || p.SetID != s.SetID || !bytes.Equal(p.Data, s.Data) {
+			t.Fatalf("ParseShare(%q) = %+v, want %+v", text, p, s)
+		}
+		if q, err := ParseShare(s.String()); err != nil || !bytes.Equal(q.Data, s.Data) {
+			t.Fatalf("ParseShare(String()) = %v, %v", q, err)
+		}
+		parsed = append(parsed, p)
+	}
+	got, err := Combine(parsed[1:])
+	if err != nil || string(got) != "correct horse battery staple" {
+		t.Fatalf("Combine(parsed) = %q, %v", got, err)
+	}
+}
+
+func TestParseShareDetectsMistakes(t *testing.T) {
+	s := &Share{Kind: KindMasterKey, Threshold: 2, Index: 1, SetID: 0x1234, Data: []byte{1, 2, 3, 4}}
+	words := s.Words()
+
+	swapped := append([]string(nil), words...)
+	swapped[6] = wordList[wordIndex[swapped[6]]+1]
+	if _, err := ParseShare(strings.Join(swapped, " ")); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("mistyped word: got %v, want a checksum error", err)
+	}
+	if _, err := ParseShare(strings.Join(words[:len(words)-1], " ")); err == nil {
+		t.Fatal("missing word accepted")
+	}
+	if _, err := ParseShare(strings.Join(append(words[:1:1], "notaword"), " ")); err == nil {
+		t.Fatal("unknown word accepted")
+	}
+}
+
+func TestCombineRejectsMixedSets(t *testing.T) {
+	a, _ := Split(KindMasterKey, []byte("secret"), 3, 2)
+	b, _ := Split(KindMasterKey, []byte("secret"), 3, 2)
+	b[1].SetID = a[0].SetID + 1
+	if _, err := Combine([]*Share{a[0], b[1]}); err == nil {
+		t.Fatal("combined shares from different splits")
+	}
+	if _, err := Combine([]*Share{a[0], a[0]}); err == nil {
+		t.Fatal("combined a duplicated share")
+	}
+}
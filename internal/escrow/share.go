@@ -0,0 +1,145 @@
+package escrow
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind 分享的秘密类型
+type Kind byte
+
+const (
+	KindMasterKey        Kind = 1 // 当前 profile 的 master key
+	KindBackupPassphrase Kind = 2 // fssh backup 使用的备份口令
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindMasterKey:
+		return "master-key"
+	case KindBackupPassphrase:
+		return "backup-passphrase"
+	}
+	return fmt.Sprintf("kind-%d", byte(k))
+}
+
+const shareVersion = 1
+
+// 分享编码：版本(1) 类型(1) 门限(1) 序号(1) 分组ID(2) 数据(n) 校验(2)
+const (
+	shareHeaderLen   = 6
+	shareChecksumLen = 2
+)
+
+// Share 一份秘密分享
+type Share struct {
+	Kind      Kind
+	Threshold int
+	Index     byte   // x 坐标，1..n
+	SetID     uint16 // 同一次拆分的所有分享相同，防止混用不同批次
+	Data      []byte
+}
+
+// Split 把秘密拆分为 n 份，任意 k 份可恢复
+func Split(kind Kind, secret []byte, n, k int) ([]*Share, error) {
+	ys, err := split(secret, n, k)
+	if err != nil {
+		return nil, err
+	}
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	setID := binary.BigEndian.Uint16(id[:])
+	shares := make([]*Share, n)
+	for i, y := range ys {
+		shares[i] = &Share{Kind: kind, Threshold: k, Index: byte(i + 1), SetID: setID, Data: y}
+	}
+	return shares, nil
+}
+
+// Combine 从至少门限数量的分享恢复秘密
+func Combine(shares []*Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares")
+	}
+	first := shares[0]
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("need %d shares, got %d", first.Threshold, len(shares))
+	}
+	xs := make([]byte, 0, len(shares))
+	ys := make([][]byte, 0, len(shares))
+	for _, s := range shares {
+		if s.Kind != first.Kind || s.SetID != first.SetID || s.Threshold != first.Threshold {
+			return nil, fmt.Errorf("share #%d belongs to a different split", s.Index)
+		}
+		xs = append(xs, s.Index)
+		ys = append(ys, s.Data)
+	}
+	return combine(xs, ys)
+}
+
+func (s *Share) bytes() []byte {
+	b := make([]byte, 0, shareHeaderLen+len(s.Data)+shareChecksumLen)
+	b = append(b, shareVersion, byte(s.Kind), byte(s.Threshold), s.Index)
+	b = binary.BigEndian.AppendUint16(b, s.SetID)
+	b = append(b, s.Data...)
+	sum := sha256.Sum256(b)
+	return append(b, sum[:shareChecksumLen]...)
+}
+
+// Words 把分享编码为词列表，每个词一个字节，末尾两个词为校验
+func (s *Share) Words() []string {
+	b := s.bytes()
+	words := make([]string, len(b))
+	for i, c := range b {
+		words[i] = wordList[c]
+	}
+	return words
+}
+
+// String 以连字符连接的词列表，用于二维码
+func (s *Share) String() string {
+	return strings.Join(s.Words(), "-")
+}
+
+// ParseShare 解析词列表（空白或连字符分隔，不区分大小写）
+func ParseShare(text string) (*Share, error) {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return r == '-' || r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	b := make([]byte, 0, len(fields))
+	for i, w := range fields {
+		c, ok := wordIndex[w]
+		if !ok {
+			return nil, fmt.Errorf("unknown word #%d: %q", i+1, w)
+		}
+		b = append(b, c)
+	}
+	if len(b) <= shareHeaderLen+shareChecksumLen {
+		return nil, errors.New("share is too short")
+	}
+	body, check := b[:len(b)-shareChecksumLen], b[len(b)-shareChecksumLen:]
+	sum := sha256.Sum256(body)
+	if string(sum[:shareChecksumLen]) != string(check) {
+		return nil, errors.New("share checksum mismatch (mistyped or missing word)")
+	}
+	if body[0] != shareVersion {
+		return nil, fmt.Errorf("unsupported share version %d", body[0])
+	}
+	s := &Share{
+		Kind:      Kind(body[1]),
+		Threshold: int(body[2]),
+		Index:     body[3],
+		SetID:     binary.BigEndian.Uint16(body[4:6]),
+		Data:      append([]byte(nil), body[shareHeaderLen:]...),
+	}
+	if s.Threshold < 2 || s.Index == 0 {
+		return nil, errors.New("invalid share header")
+	}
+	return s, nil
+}
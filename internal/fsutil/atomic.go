@@ -0,0 +1,100 @@
+// Package fsutil 提供文件写入相关的辅助函数
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic 原子地写入文件
+// 先写入同目录下的临时文件并 fsync，再 rename 覆盖目标文件，
+// 任何时刻目标文件要么是旧内容，要么是完整的新内容
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpPath, err := writeTemp(path, data, perm)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath) // rename 成功后为空操作
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// WriteFileExclusive 与 WriteFileAtomic 相同，但目标文件已存在时返回 os.ErrExist 而不是覆盖
+// 用 link 代替 rename，检查和创建是同一个原子操作；
+// 不支持硬链接的文件系统（如 U 盘上的 FAT）退回到 O_EXCL 创建并 fsync，返回前数据同样已落盘
+func WriteFileExclusive(path string, data []byte, perm os.FileMode) error {
+	tmpPath, err := writeTemp(path, data, perm)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	if err := os.Link(tmpPath, path); err == nil {
+		return syncDir(filepath.Dir(path))
+	} else if errors.Is(err, os.ErrExist) {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// writeTemp 把数据写入目标文件同目录下的临时文件并 fsync，返回临时文件路径
+func writeTemp(path string, data []byte, perm os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	fail := func(err error) (string, error) {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return fail(err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return tmpPath, nil
+}
+
+// syncDir 同步目录项，确保 rename 在崩溃后仍然可见
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// 部分文件系统不支持对目录 fsync，忽略该错误
+	_ = d.Sync()
+	return nil
+}
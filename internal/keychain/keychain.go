@@ -7,14 +7,20 @@ import (
 
     kc "github.com/keybase/go-keychain"
     "fssh/internal/macos"
+    "fssh/internal/profile"
 )
 
 const (
     serviceNew = "fssh"
     serviceOld = "fingerpass"
-    account    = "master_key_v1"
 )
 
+// account 返回当前 profile 的 Keychain 账户名
+// default profile 为 master_key_v1，与旧版本兼容
+func account() string {
+    return profile.KeychainAccount()
+}
+
 func MasterKeyExists() (bool, error) {
     exists, err := masterKeyExistsForService(serviceNew)
     if err != nil {
@@ -30,7 +36,7 @@ func masterKeyExistsForService(svc string) (bool, error) {
     q := kc.NewItem()
     q.SetSecClass(kc.SecClassGenericPassword)
     q.SetService(svc)
-    q.SetAccount(account)
+    q.SetAccount(account())
     q.SetMatchLimit(kc.MatchLimitOne)
     q.SetReturnData(true)
     res, err := kc.QueryItem(q)
@@ -56,15 +62,55 @@ func StoreMasterKey(key []byte, overwrite bool) error {
             return err
         }
     }
+    return addMasterKey(key)
+}
+
+// ReplaceMasterKey 原地更新 Keychain 中的 master key
+// 与 StoreMasterKey(key, true) 不同，不会先删除旧条目：更新失败（包括用户取消授权）时旧 key 仍然可用
+func ReplaceMasterKey(key []byte) error {
+    exists, err := masterKeyExistsForService(serviceNew)
+    if err != nil {
+        return err
+    }
+    if !exists {
+        // 只有旧服务名下的条目：先写入新条目，成功后再删除旧条目
+        if err := addMasterKey(key); err != nil {
+            return err
+        }
+        old := kc.NewItem()
+        old.SetSecClass(kc.SecClassGenericPassword)
+        old.SetService(serviceOld)
+        old.SetAccount(account())
+        _ = kc.DeleteItem(old) // 可能不存在
+        return nil
+    }
+    q := kc.NewItem()
+    q.SetSecClass(kc.SecClassGenericPassword)
+    q.SetService(serviceNew)
+    q.SetAccount(account())
+    upd := kc.NewItem()
+    upd.SetData(key)
+    if err := kc.UpdateItem(q, upd); err != nil {
+        if errors.Is(err, kc.ErrorUserCanceled) {
+            return fmt.Errorf("用户取消了 Keychain 授权")
+        }
+        return fmt.Errorf("更新 master key 失败: %w", err)
+    }
+    return nil
+}
+
+// addMasterKey 添加 master key 条目，调用方保证条目不存在
+func addMasterKey(key []byte) error {
     it := kc.NewItem()
     it.SetSecClass(kc.SecClassGenericPassword)
     it.SetService(serviceNew)
-    it.SetAccount(account)
+    it.SetAccount(account())
     it.SetAccessible(kc.AccessibleWhenUnlocked)
     it.SetData(key)
 
     // Retry logic for Keychain authorization
     // First authorization may take time or return temporary error
+    var err error
     maxRetries := 3
     for attempt := 1; attempt <= maxRetries; attempt++ {
         err = kc.AddItem(it)
@@ -120,22 +166,31 @@ func queryMasterKey(svc string) ([]kc.QueryResult, error) {
     q := kc.NewItem()
     q.SetSecClass(kc.SecClassGenericPassword)
     q.SetService(svc)
-    q.SetAccount(account)
+    q.SetAccount(account())
     q.SetMatchLimit(kc.MatchLimitOne)
     q.SetReturnData(true)
     return kc.QueryItem(q)
 }
 
 func DeleteMasterKey() error {
+    return deleteMasterKeyForAccount(account())
+}
+
+// DeleteProfileMasterKey 删除指定 profile 的 master key
+func DeleteProfileMasterKey(name string) error {
+    return deleteMasterKeyForAccount(profile.KeychainAccountFor(name))
+}
+
+func deleteMasterKeyForAccount(acct string) error {
     it := kc.NewItem()
     it.SetSecClass(kc.SecClassGenericPassword)
     it.SetService(serviceNew)
-    it.SetAccount(account)
+    it.SetAccount(acct)
     _ = kc.DeleteItem(it)  // Ignore errors, may not exist
     it2 := kc.NewItem()
     it2.SetSecClass(kc.SecClassGenericPassword)
     it2.SetService(serviceOld)
-    it2.SetAccount(account)
+    it2.SetAccount(acct)
     _ = kc.DeleteItem(it2)  // Ignore errors, may not exist
     return nil
 }
\ No newline at end of file
@@ -0,0 +1,130 @@
+// Package keypolicy 定义密钥强度策略（允许的类型、RSA 和 ECDSA 的最小位数）
+// 策略保存在 config.json 的 key_policy 中，导入和生成密钥时检查，fssh key audit 检查已有密钥
+package keypolicy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 密钥类型
+const (
+	TypeEd25519 = "ed25519"
+	TypeECDSA   = "ecdsa"
+	TypeRSA     = "rsa"
+)
+
+// ErrViolation 密钥不满足策略
+var ErrViolation = errors.New("key does not satisfy key_policy")
+
+// Policy 密钥强度策略，零值表示不限制
+type Policy struct {
+	AllowedTypes []string `json:"allowed_types,omitempty"`  // ed25519、ecdsa、rsa，为空表示全部允许
+	MinRSABits   int      `json:"min_rsa_bits,omitempty"`   // 例如 3072
+	MinECDSABits int      `json:"min_ecdsa_bits,omitempty"` // 例如 256 拒绝 P-224
+}
+
+// IsZero 是否没有配置任何限制
+func (p Policy) IsZero() bool {
+	return len(p.AllowedTypes) == 0 && p.MinRSABits == 0 && p.MinECDSABits == 0
+}
+
+// Validate 检查策略本身是否有效
+func (p Policy) Validate() error {
+	for _, t := range p.AllowedTypes {
+		switch t {
+		case TypeEd25519, TypeECDSA, TypeRSA:
+		default:
+			return fmt.Errorf("key_policy: unknown key type %q (supported: ed25519, ecdsa, rsa)", t)
+		}
+	}
+	if p.MinRSABits < 0 || p.MinECDSABits < 0 {
+		return errors.New("key_policy: minimum bits must not be negative")
+	}
+	return nil
+}
+
+// String 策略的简短描述
+func (p Policy) String() string {
+	if p.IsZero() {
+		return "none"
+	}
+	var parts []string
+	if len(p.AllowedTypes) > 0 {
+		parts = append(parts, "types="+strings.Join(p.AllowedTypes, ","))
+	}
+	if p.MinRSABits > 0 {
+		parts = append(parts, fmt.Sprintf("rsa>=%d", p.MinRSABits))
+	}
+	if p.MinECDSABits > 0 {
+		parts = append(parts, fmt.Sprintf("ecdsa>=%d", p.MinECDSABits))
+	}
+	return strings.Join(parts, " ")
+}
+
+// KeyInfo 密钥的类型和强度
+type KeyInfo struct {
+	Type  string
+	Bits  int
+	Curve string // 仅 ECDSA
+}
+
+func (k KeyInfo) String() string {
+	switch k.Type {
+	case TypeRSA:
+		return fmt.Sprintf("rsa-%d", k.Bits)
+	case TypeECDSA:
+		return "ecdsa-" + k.Curve
+	}
+	return k.Type
+}
+
+// Inspect 返回私钥、公钥或 SSH 公钥的类型和位数
+func Inspect(key interface{}) (KeyInfo, error) {
+	if pk, ok := key.(ssh.PublicKey); ok {
+		cpk, ok := pk.(ssh.CryptoPublicKey)
+		if !ok {
+			return KeyInfo{}, fmt.Errorf("unsupported key type %s", pk.Type())
+		}
+		key = cpk.CryptoPublicKey()
+	}
+	if signer, ok := key.(crypto.Signer); ok {
+		key = signer.Public()
+	}
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return KeyInfo{Type: TypeEd25519, Bits: 256}, nil
+	case *rsa.PublicKey:
+		return KeyInfo{Type: TypeRSA, Bits: k.N.BitLen()}, nil
+	case *ecdsa.PublicKey:
+		return KeyInfo{Type: TypeECDSA, Bits: k.Curve.Params().BitSize, Curve: k.Curve.Params().Name}, nil
+	}
+	return KeyInfo{}, fmt.Errorf("unsupported key type %T", key)
+}
+
+// Check 检查密钥是否满足策略，不满足时返回包装 ErrViolation 的错误
+func (p Policy) Check(info KeyInfo) error {
+	if len(p.AllowedTypes) > 0 {
+		allowed := false
+		for _, t := range p.AllowedTypes {
+			allowed = allowed || t == info.Type
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s keys are not allowed (allowed: %s)", ErrViolation, info.Type, strings.Join(p.AllowedTypes, ", "))
+		}
+	}
+	switch {
+	case info.Type == TypeRSA && info.Bits < p.MinRSABits:
+		return fmt.Errorf("%w: RSA key has %d bits, minimum is %d", ErrViolation, info.Bits, p.MinRSABits)
+	case info.Type == TypeECDSA && info.Bits < p.MinECDSABits:
+		return fmt.Errorf("%w: ECDSA curve %s has %d bits, minimum is %d", ErrViolation, info.Curve, info.Bits, p.MinECDSABits)
+	}
+	return nil
+}
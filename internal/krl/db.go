@@ -0,0 +1,244 @@
+package krl
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"fssh/internal/fsutil"
+	"fssh/internal/profile"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const dbVersion = "fssh-krl/v1"
+
+// 吊销类型
+const (
+	KindSerial      = "serial"      // 某个 CA 签发的证书序列号区间
+	KindKeyID       = "id"          // 证书 key ID，CA 为空时对所有 CA 生效
+	KindKey         = "key"         // 公钥（同时吊销该公钥的所有证书）
+	KindFingerprint = "fingerprint" // 只知道 SHA256 指纹的公钥
+)
+
+// Revocation 吊销数据库中的一条记录
+type Revocation struct {
+	Kind        string `json:"kind"`
+	CA          string `json:"ca,omitempty"` // CA 公钥，authorized_keys 格式
+	SerialMin   uint64 `json:"serial_min,omitempty"`
+	SerialMax   uint64 `json:"serial_max,omitempty"`
+	KeyID       string `json:"key_id,omitempty"`
+	Key         string `json:"key,omitempty"`         // 公钥，authorized_keys 格式
+	Fingerprint string `json:"fingerprint,omitempty"` // SHA256:...
+	Label       string `json:"label,omitempty"`       // 别名、证书 key ID 等便于识别的说明
+	Reason      string `json:"reason,omitempty"`
+	Added       string `json:"added"`
+}
+
+// String 记录的简短描述
+func (r *Revocation) String() string {
+	switch r.Kind {
+	case KindSerial:
+		if r.SerialMin == r.SerialMax {
+			return fmt.Sprintf("serial %d", r.SerialMin)
+		}
+		return fmt.Sprintf("serial %d-%d", r.SerialMin, r.SerialMax)
+	case KindKeyID:
+		return fmt.Sprintf("key ID %q", r.KeyID)
+	case KindKey:
+		if pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(r.Key)); err == nil {
+			return "key " + ssh.FingerprintSHA256(pk)
+		}
+		return "key"
+	case KindFingerprint:
+		return "key " + r.Fingerprint
+	}
+	return r.Kind
+}
+
+// same 两条记录是否吊销同一对象（忽略说明和时间）
+func (r *Revocation) same(o *Revocation) bool {
+	return r.Kind == o.Kind && r.CA == o.CA && r.SerialMin == o.SerialMin && r.SerialMax == o.SerialMax &&
+		r.KeyID == o.KeyID && r.Key == o.Key && r.Fingerprint == o.Fingerprint
+}
+
+// Database 吊销数据库，保存在 profile 目录下的 krl/revocations.json
+type Database struct {
+	Version     string        `json:"version"`
+	KRLVersion  uint64        `json:"krl_version"` // 最近一次生成的 KRL 版本号
+	Revocations []*Revocation `json:"revocations"`
+}
+
+// Path 返回当前 profile 的吊销数据库路径
+func Path() string {
+	return filepath.Join(profile.Dir(), "krl", "revocations.json")
+}
+
+// Load 读取吊销数据库，不存在时返回空数据库
+func Load() (*Database, error) {
+	db := &Database{Version: dbVersion}
+	data, err := os.ReadFile(Path())
+	if errors.Is(err, os.ErrNotExist) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", Path(), err)
+	}
+	if db.Version != dbVersion {
+		return nil, fmt.Errorf("unsupported revocation database version: %s", db.Version)
+	}
+	return db, nil
+}
+
+// Update 在文件锁内读取、修改并原子写回吊销数据库
+func Update(fn func(db *Database) error) error {
+	path := Path()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	db, err := Load()
+	if err != nil {
+		return err
+	}
+	if err := fn(db); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data, 0600)
+}
+
+// Add 添加吊销记录，已有相同记录时返回 false
+func (db *Database) Add(r *Revocation) (bool, error) {
+	if err := r.validate(); err != nil {
+		return false, err
+	}
+	for _, o := range db.Revocations {
+		if o.same(r) {
+			return false, nil
+		}
+	}
+	if r.Added == "" {
+		r.Added = time.Now().Format(time.RFC3339)
+	}
+	db.Revocations = append(db.Revocations, r)
+	return true, nil
+}
+
+func (r *Revocation) validate() error {
+	switch r.Kind {
+	case KindSerial:
+		if r.CA == "" {
+			return errors.New("serial revocations require a CA key")
+		}
+		if r.SerialMin == 0 || r.SerialMin > r.SerialMax {
+			return fmt.Errorf("invalid serial range %d-%d (serial 0 cannot be revoked)", r.SerialMin, r.SerialMax)
+		}
+	case KindKeyID:
+		if r.KeyID == "" {
+			return errors.New("key ID must not be empty")
+		}
+	case KindKey:
+		if r.Key == "" {
+			return errors.New("public key must not be empty")
+		}
+	case KindFingerprint:
+		if _, err := fingerprintHash(r.Fingerprint); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown revocation kind %q", r.Kind)
+	}
+	return nil
+}
+
+// FormatKey 把公钥格式化为不带注释的 authorized_keys 行，同一公钥总是得到相同的字符串
+func FormatKey(pk ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pk)))
+}
+
+// fingerprintHash 把 SHA256:xxx 指纹解码为 32 字节摘要
+func fingerprintHash(fp string) ([]byte, error) {
+	b64, ok := strings.CutPrefix(fp, "SHA256:")
+	if !ok {
+		return nil, fmt.Errorf("invalid fingerprint %q (expected SHA256:...)", fp)
+	}
+	h, err := base64.RawStdEncoding.DecodeString(b64)
+	if err != nil || len(h) != sha256.Size {
+		return nil, fmt.Errorf("invalid fingerprint %q", fp)
+	}
+	return h, nil
+}
+
+// KRL 由数据库生成 KRL，同一 CA 的记录合并到一个证书部分
+func (db *Database) KRL(version uint64, comment string) (*KRL, error) {
+	k := &KRL{Version: version, GeneratedDate: time.Now(), Comment: comment}
+	sections := map[string]*CertSection{}
+	section := func(ca string) (*CertSection, error) {
+		if cs, ok := sections[ca]; ok {
+			return cs, nil
+		}
+		cs := &CertSection{}
+		if ca != "" {
+			pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(ca))
+			if err != nil {
+				return nil, fmt.Errorf("invalid CA key: %w", err)
+			}
+			cs.CA = pk
+		}
+		sections[ca] = cs
+		k.Certs = append(k.Certs, cs)
+		return cs, nil
+	}
+	for _, r := range db.Revocations {
+		switch r.Kind {
+		case KindSerial:
+			cs, err := section(r.CA)
+			if err != nil {
+				return nil, err
+			}
+			cs.Serials = append(cs.Serials, SerialRange{r.SerialMin, r.SerialMax})
+		case KindKeyID:
+			cs, err := section(r.CA)
+			if err != nil {
+				return nil, err
+			}
+			cs.KeyIDs = append(cs.KeyIDs, r.KeyID)
+		case KindKey:
+			pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(r.Key))
+			if err != nil {
+				return nil, fmt.Errorf("invalid revoked key: %w", err)
+			}
+			k.Keys = append(k.Keys, pk.Marshal())
+		case KindFingerprint:
+			h, err := fingerprintHash(r.Fingerprint)
+			if err != nil {
+				return nil, err
+			}
+			k.SHA256 = append(k.SHA256, h)
+		}
+	}
+	return k, nil
+}
@@ -0,0 +1,364 @@
+// Package krl 读写 OpenSSH 二进制密钥吊销列表（KRL，见 OpenSSH PROTOCOL.krl），
+// 并在 profile 目录下维护吊销数据库，由 fssh krl build 生成供 sshd RevokedKeys 使用的文件
+package krl
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	krlMagic         = 0x5353484b524c0a00 // "SSHKRL\n\0"
+	krlFormatVersion = 1
+)
+
+// KRL 各部分的类型
+const (
+	sectionCertificates      = 1
+	sectionExplicitKey       = 2
+	sectionFingerprintSHA1   = 3
+	sectionSignature         = 4
+	sectionFingerprintSHA256 = 5
+
+	certSectionSerialList   = 0x20
+	certSectionSerialRange  = 0x21
+	certSectionSerialBitmap = 0x22
+	certSectionKeyID        = 0x23
+)
+
+// SerialRange 证书序列号区间（闭区间），序列号 0 不能吊销
+type SerialRange struct {
+	Min, Max uint64
+}
+
+// CertSection 一个 CA 签发的被吊销证书
+type CertSection struct {
+	CA      ssh.PublicKey // nil 表示任意 CA，只能按 key ID 吊销
+	Serials []SerialRange
+	KeyIDs  []string
+}
+
+// KRL 密钥吊销列表
+type KRL struct {
+	Version       uint64 // 每次生成递增，便于确认服务器上的版本
+	GeneratedDate time.Time
+	Comment       string
+	Certs         []*CertSection
+	Keys          [][]byte // 完整公钥编码
+	SHA1          [][]byte // 公钥编码的 SHA-1
+	SHA256        [][]byte // 公钥编码的 SHA-256
+}
+
+// Marshal 按 OpenSSH 格式编码，不带签名
+func (k *KRL) Marshal() ([]byte, error) {
+	var b bytes.Buffer
+	putU64(&b, krlMagic)
+	putU32(&b, krlFormatVersion)
+	putU64(&b, k.Version)
+	putU64(&b, uint64(k.GeneratedDate.Unix()))
+	putU64(&b, 0) // flags
+	putString(&b, nil)
+	putString(&b, []byte(k.Comment))
+
+	for _, cs := range k.Certs {
+		var sect bytes.Buffer
+		if cs.CA != nil {
+			putString(&sect, cs.CA.Marshal())
+		} else {
+			if len(cs.Serials) > 0 {
+				return nil, errors.New("krl: serial revocations require a CA key")
+			}
+			putString(&sect, nil)
+		}
+		putString(&sect, nil) // reserved
+
+		var list, ranges bytes.Buffer
+		for _, r := range cs.Serials {
+			if r.Min == 0 || r.Min > r.Max {
+				return nil, fmt.Errorf("krl: invalid serial range %d-%d", r.Min, r.Max)
+			}
+			if r.Min == r.Max {
+				putU64(&list, r.Min)
+			} else {
+				putU64(&ranges, r.Min)
+				putU64(&ranges, r.Max)
+			}
+		}
+		if list.Len() > 0 {
+			sect.WriteByte(certSectionSerialList)
+			putString(&sect, list.Bytes())
+		}
+		// 每个区间一个子段
+		for rb := ranges.Bytes(); len(rb) > 0; rb = rb[16:] {
+			sect.WriteByte(certSectionSerialRange)
+			putString(&sect, rb[:16])
+		}
+		if len(cs.KeyIDs) > 0 {
+			var ids bytes.Buffer
+			for _, id := range cs.KeyIDs {
+				putString(&ids, []byte(id))
+			}
+			sect.WriteByte(certSectionKeyID)
+			putString(&sect, ids.Bytes())
+		}
+		b.WriteByte(sectionCertificates)
+		putString(&b, sect.Bytes())
+	}
+	for _, s := range []struct {
+		typ   byte
+		blobs [][]byte
+	}{{sectionExplicitKey, k.Keys}, {sectionFingerprintSHA1, k.SHA1}, {sectionFingerprintSHA256, k.SHA256}} {
+		if len(s.blobs) == 0 {
+			continue
+		}
+		var sect bytes.Buffer
+		for _, blob := range s.blobs {
+			putString(&sect, blob)
+		}
+		b.WriteByte(s.typ)
+		putString(&b, sect.Bytes())
+	}
+	return b.Bytes(), nil
+}
+
+// Parse 解析 OpenSSH KRL（包括 ssh-keygen -k 生成的文件），签名部分被忽略
+func Parse(data []byte) (*KRL, error) {
+	r := &reader{buf: data}
+	if r.u64() != krlMagic {
+		return nil, errors.New("krl: not an OpenSSH KRL")
+	}
+	if v := r.u32(); v != krlFormatVersion {
+		return nil, fmt.Errorf("krl: unsupported format version %d", v)
+	}
+	k := &KRL{Version: r.u64()}
+	k.GeneratedDate = time.Unix(int64(r.u64()), 0)
+	r.u64() // flags
+	r.str() // reserved
+	k.Comment = string(r.str())
+	if r.err != nil {
+		return nil, r.err
+	}
+	for len(r.buf) > 0 && r.err == nil {
+		typ := r.u8()
+		sect := &reader{buf: r.str()}
+		if r.err != nil {
+			break
+		}
+		switch typ {
+		case sectionCertificates:
+			cs, err := parseCertSection(sect)
+			if err != nil {
+				return nil, err
+			}
+			k.Certs = append(k.Certs, cs)
+		case sectionExplicitKey, sectionFingerprintSHA1, sectionFingerprintSHA256:
+			for len(sect.buf) > 0 && sect.err == nil {
+				blob := sect.str()
+				switch typ {
+				case sectionExplicitKey:
+					k.Keys = append(k.Keys, blob)
+				case sectionFingerprintSHA1:
+					k.SHA1 = append(k.SHA1, blob)
+				case sectionFingerprintSHA256:
+					k.SHA256 = append(k.SHA256, blob)
+				}
+			}
+			if sect.err != nil {
+				return nil, sect.err
+			}
+		case sectionSignature:
+			// 签名位于末尾，fssh 不生成也不校验
+			return k, nil
+		default:
+			return nil, fmt.Errorf("krl: unsupported section type %d", typ)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return k, nil
+}
+
+func parseCertSection(r *reader) (*CertSection, error) {
+	cs := &CertSection{}
+	if caBlob := r.str(); len(caBlob) > 0 {
+		ca, err := ssh.ParsePublicKey(caBlob)
+		if err != nil {
+			return nil, fmt.Errorf("krl: invalid CA key: %w", err)
+		}
+		cs.CA = ca
+	}
+	r.str() // reserved
+	for len(r.buf) > 0 && r.err == nil {
+		typ := r.u8()
+		sub := &reader{buf: r.str()}
+		switch typ {
+		case certSectionSerialList:
+			for len(sub.buf) > 0 && sub.err == nil {
+				s := sub.u64()
+				cs.Serials = append(cs.Serials, SerialRange{s, s})
+			}
+		case certSectionSerialRange:
+			cs.Serials = append(cs.Serials, SerialRange{sub.u64(), sub.u64()})
+		case certSectionSerialBitmap:
+			offset := sub.u64()
+			bitmap := new(big.Int).SetBytes(sub.str())
+			for i := 0; i < bitmap.BitLen(); i++ {
+				if bitmap.Bit(i) == 1 {
+					s := offset + uint64(i)
+					cs.Serials = append(cs.Serials, SerialRange{s, s})
+				}
+			}
+		case certSectionKeyID:
+			for len(sub.buf) > 0 && sub.err == nil {
+				cs.KeyIDs = append(cs.KeyIDs, string(sub.str()))
+			}
+		default:
+			return nil, fmt.Errorf("krl: unsupported certificate section type 0x%x", typ)
+		}
+		if sub.err != nil {
+			return nil, sub.err
+		}
+	}
+	return cs, r.err
+}
+
+// Check 检查公钥或证书是否被吊销，返回命中的原因；未吊销时返回空字符串
+// 与 sshd 相同：证书的底层公钥被吊销时证书也视为吊销，证书的 CA 公钥被吊销时同样视为吊销
+func (k *KRL) Check(pub ssh.PublicKey) string {
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		if reason := k.checkCert(cert); reason != "" {
+			return reason
+		}
+		if reason := k.checkKey(cert.Key); reason != "" {
+			return reason
+		}
+		if reason := k.checkKey(cert.SignatureKey); reason != "" {
+			return "CA key " + reason
+		}
+		return ""
+	}
+	return k.checkKey(pub)
+}
+
+func (k *KRL) checkKey(pub ssh.PublicKey) string {
+	blob := pub.Marshal()
+	s1 := sha1.Sum(blob)
+	s256 := sha256.Sum256(blob)
+	for _, c := range []struct {
+		list   [][]byte
+		target []byte
+		reason string
+	}{
+		{k.Keys, blob, "revoked key"},
+		{k.SHA1, s1[:], "revoked SHA1 fingerprint"},
+		{k.SHA256, s256[:], "revoked SHA256 fingerprint"},
+	} {
+		for _, v := range c.list {
+			if bytes.Equal(v, c.target) {
+				return c.reason
+			}
+		}
+	}
+	return ""
+}
+
+func (k *KRL) checkCert(cert *ssh.Certificate) string {
+	caBlob := cert.SignatureKey.Marshal()
+	for _, cs := range k.Certs {
+		if cs.CA != nil && !bytes.Equal(cs.CA.Marshal(), caBlob) {
+			continue
+		}
+		for _, id := range cs.KeyIDs {
+			if id == cert.KeyId {
+				return fmt.Sprintf("revoked key ID %q", id)
+			}
+		}
+		if cs.CA == nil {
+			continue
+		}
+		for _, r := range cs.Serials {
+			if cert.Serial >= r.Min && cert.Serial <= r.Max {
+				return fmt.Sprintf("revoked serial %d", cert.Serial)
+			}
+		}
+	}
+	return ""
+}
+
+func putU32(b *bytes.Buffer, v uint32) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], v)
+	b.Write(n[:])
+}
+
+func putU64(b *bytes.Buffer, v uint64) {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], v)
+	b.Write(n[:])
+}
+
+func putString(b *bytes.Buffer, s []byte) {
+	putU32(b, uint32(len(s)))
+	b.Write(s)
+}
+
+// reader 顺序读取 SSH 编码，出错后后续读取返回零值，由调用方检查 err
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf) < n {
+		r.err = errors.New("krl: truncated data")
+		return nil
+	}
+	v := r.buf[:n]
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *reader) u8() byte {
+	if v := r.take(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (r *reader) u32() uint32 {
+	if v := r.take(4); v != nil {
+		return binary.BigEndian.Uint32(v)
+	}
+	return 0
+}
+
+func (r *reader) u64() uint64 {
+	if v := r.take(8); v != nil {
+		return binary.BigEndian.Uint64(v)
+	}
+	return 0
+}
+
+func (r *reader) str() []byte {
+	n := r.u32()
+	if r.err != nil {
+		return nil
+	}
+	if uint64(n) > uint64(len(r.buf)) {
+		r.err = errors.New("krl: truncated data")
+		return nil
+	}
+	return r.take(int(n))
+}
@@ -0,0 +1,7 @@
+serial: 1
+serial: 5-7
+serial: 10
+serial: 12
+serial: 100-200
+id: bob@example.com
+ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIPQDx78k97pRtxF4Z2lncHmm+9zhZp3cORGqgMHtduTT user
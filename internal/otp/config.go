@@ -5,6 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"syscall"
+
+	"fssh/internal/crypt"
+	"fssh/internal/fsutil"
+	"fssh/internal/profile"
 )
 
 // Config OTP 配置结构
@@ -13,33 +18,62 @@ type Config struct {
 	// 版本标识
 	Version string `json:"version"`
 
-	// TOTP 参数
-	Algorithm string `json:"algorithm"` // SHA1, SHA256, SHA512
-	Digits    int    `json:"digits"`    // 6 或 8
-	Period    int    `json:"period"`    // 时间窗口（秒），通常是 30
+	// OTP 类型: totp 或 hotp，为空表示 totp
+	Type string `json:"type,omitempty"`
+
+	// TOTP/HOTP 参数
+	Algorithm string `json:"algorithm"`  // SHA1, SHA256, SHA512
+	Digits    int    `json:"digits"`     // 6 或 8
+	Period    int    `json:"period"`     // 时间窗口（秒），通常是 30
+	SkewSteps int    `json:"skew_steps"` // 允许的时钟偏移窗口数（±N 个周期）
+
+	// 重放保护：最后一次通过验证的计数器
+	LastUsedCounter int64 `json:"last_used_counter"`
+
+	// HOTP：下一个期望的计数器、前向窗口和待确认的重新同步计数器
+	HOTPCounter       int64 `json:"hotp_counter,omitempty"`
+	HOTPLookAhead     int   `json:"hotp_look_ahead,omitempty"`
+	HOTPResyncCounter int64 `json:"hotp_resync_counter,omitempty"`
+
+	// 时钟偏移检测：连续落在验证窗口外的验证码偏移（周期数）及次数
+	NearMissSteps int `json:"near_miss_steps,omitempty"`
+	NearMissCount int `json:"near_miss_count,omitempty"`
 
 	// 加密的 OTP seed
 	EncryptedSeed string `json:"encrypted_seed"` // Base64 编码的密文
-	SeedSalt      string `json:"seed_salt"`      // Base64 编码的 PBKDF2 salt (32 bytes)
+	SeedSalt      string `json:"seed_salt"`      // Base64 编码的 KDF salt (32 bytes)
 	SeedNonce     string `json:"seed_nonce"`     // Base64 编码的 AES-GCM nonce (12 bytes)
 
+	// 从密码派生 seed 加密密钥的算法和参数，为空表示旧版 PBKDF2-SHA256 100k
+	KDF *crypt.KDFParams `json:"kdf,omitempty"`
+
 	// Master Key 派生参数
 	MasterKeySalt string `json:"master_key_salt"` // Base64 编码的 HKDF salt (32 bytes)
 
 	// 缓存配置
 	SeedUnlockTTLSeconds int `json:"seed_unlock_ttl_seconds"` // OTP seed 缓存时间（秒）
 
-	// 恢复码（SHA-256 哈希）
+	// 恢复码（SHA-256 哈希），已使用的恢复码标记为 "used:<哈希>"
 	RecoveryCodesHash []string `json:"recovery_codes_hash"`
 
+	// 使用恢复码加密的 OTP seed 副本，与 RecoveryCodesHash 一一对应
+	RecoverySeeds []RecoverySeed `json:"recovery_seeds,omitempty"`
+
 	// 创建时间
 	CreatedAt string `json:"created_at"`
 }
 
+// OTPType 返回 OTP 类型，旧配置默认为 TOTP
+func (c *Config) OTPType() string {
+	if c.Type == "" {
+		return TypeTOTP
+	}
+	return c.Type
+}
+
 // ConfigPath 返回 OTP 配置文件路径
 func ConfigPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".fssh", "otp", "config.enc")
+	return filepath.Join(profile.Dir(), "otp", "config.enc")
 }
 
 // LoadConfig 加载 OTP 配置
@@ -64,6 +98,11 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("配置文件损坏：缺少必要字段")
 	}
 
+	// 未设置 skew_steps（包括旧版本配置）时使用默认值
+	if cfg.SkewSteps <= 0 {
+		cfg.SkewSteps = DefaultSkewSteps
+	}
+
 	return &cfg, nil
 }
 
@@ -83,8 +122,8 @@ func SaveConfig(cfg *Config) error {
 		return fmt.Errorf("序列化配置失败: %w", err)
 	}
 
-	// 写入文件，权限 0600（仅当前用户可读写）
-	if err := os.WriteFile(path, data, 0600); err != nil {
+	// 原子写入文件，权限 0600（仅当前用户可读写）
+	if err := fsutil.WriteFileAtomic(path, data, 0600); err != nil {
 		return fmt.Errorf("写入配置文件失败: %w", err)
 	}
 
@@ -97,9 +136,37 @@ func ConfigExists() bool {
 	return err == nil
 }
 
+// lockConfig 获取配置文件的独占锁，返回释放函数
+// 防止多个进程（agent、命令行）同时读-改-写配置
+func lockConfig() (func(), error) {
+	path := ConfigPath() + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("创建配置目录失败: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开配置锁失败: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("获取配置锁失败: %w", err)
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
 // UpdateConfig 更新配置字段
-// 使用回调函数修改配置，然后保存
+// 在配置锁内读取最新配置、调用回调函数修改，然后原子保存
+// 回调返回错误时不保存
 func UpdateConfig(updateFn func(*Config) error) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	cfg, err := LoadConfig(ConfigPath())
 	if err != nil {
 		return err
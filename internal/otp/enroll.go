@@ -0,0 +1,89 @@
+package otp
+
+import (
+	"encoding/base32"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"fssh/internal/qrcode"
+)
+
+// issuer otpauth URI 中的发行者名称
+const issuer = "fssh"
+
+// EncodeSecret 按 RFC 4648 base32（无填充）编码 OTP seed
+// 认证器应用手动输入密钥时使用此格式
+func EncodeSecret(seed []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(seed)
+}
+
+// DefaultAccount 返回 user@hostname 形式的账户名
+func DefaultAccount() string {
+	hostname, _ := os.Hostname()
+	user := os.Getenv("USER")
+	return fmt.Sprintf("%s@%s", user, hostname)
+}
+
+// EnrollmentURI 生成 otpauth URI（Key URI Format）
+// TOTP: otpauth://totp/fssh:user@host?secret=...&issuer=fssh&algorithm=...&digits=...&period=...
+// HOTP: otpauth://hotp/fssh:user@host?secret=...&issuer=fssh&algorithm=...&digits=...&counter=...
+func EnrollmentURI(seed []byte, account string, cfg *Config) string {
+	q := url.Values{}
+	q.Set("secret", EncodeSecret(seed))
+	q.Set("issuer", issuer)
+	q.Set("algorithm", cfg.Algorithm)
+	q.Set("digits", strconv.Itoa(cfg.Digits))
+	if cfg.OTPType() == TypeHOTP {
+		q.Set("counter", strconv.FormatInt(cfg.HOTPCounter, 10))
+	} else {
+		q.Set("period", strconv.Itoa(cfg.Period))
+	}
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     cfg.OTPType(),
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// DisplayEnrollment 显示认证器注册信息：base32 密钥、otpauth URI 和终端二维码
+func DisplayEnrollment(seed []byte, cfg *Config) error {
+	account := DefaultAccount()
+	uri := EnrollmentURI(seed, account, cfg)
+
+	if cfg.OTPType() == TypeHOTP {
+		fmt.Println("HOTP 配置:")
+	} else {
+		fmt.Println("TOTP 配置:")
+	}
+	fmt.Printf("  发行者: %s\n", issuer)
+	fmt.Printf("  账户: %s\n", account)
+	fmt.Printf("  密钥: %s\n", EncodeSecret(seed))
+	fmt.Printf("  算法: %s\n", cfg.Algorithm)
+	fmt.Printf("  位数: %d\n", cfg.Digits)
+	if cfg.OTPType() == TypeHOTP {
+		fmt.Printf("  计数器: %d\n", cfg.HOTPCounter)
+	} else {
+		fmt.Printf("  间隔: %d秒\n", cfg.Period)
+	}
+	fmt.Println()
+	fmt.Printf("  URI: %s\n", uri)
+	fmt.Println()
+
+	code, err := qrcode.Encode([]byte(uri), qrcode.LevelM)
+	if err != nil {
+		return fmt.Errorf("生成二维码失败: %w", err)
+	}
+	fmt.Println("使用认证器应用扫描二维码，或手动输入上面的密钥:")
+	fmt.Println()
+	if err := code.WriteTerminal(os.Stdout); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	return nil
+}
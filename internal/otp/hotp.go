@@ -0,0 +1,67 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"errors"
+)
+
+// OTP 类型
+const (
+	TypeTOTP = "totp" // 基于时间（RFC 6238）
+	TypeHOTP = "hotp" // 基于计数器（RFC 4226）
+)
+
+// DefaultHOTPLookAhead 默认的 HOTP 前向窗口（允许认证器多按几次而未使用）
+const DefaultHOTPLookAhead = 10
+
+// hotpResyncWindow 超出前向窗口后用于重新同步的搜索范围
+const hotpResyncWindow = 100
+
+// ErrHOTPResync 验证码超出前向窗口，需要再输入下一个验证码完成重新同步
+var ErrHOTPResync = errors.New("验证码超出同步窗口，请再生成并输入下一个验证码以重新同步")
+
+// matchHOTP 在 [from, from+window] 范围内查找与验证码匹配的计数器
+func matchHOTP(seed []byte, code string, algorithm string, digits int, from int64, window int) (int64, bool) {
+	for c := from; c <= from+int64(window); c++ {
+		if hmac.Equal([]byte(code), []byte(Generate(seed, c, algorithm, digits))) {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// CheckHOTP 验证 HOTP 验证码并推进计数器
+// 只修改 cfg，调用方负责在配置锁内保存（见 UpdateConfig）
+//
+// 1. 在 [counter, counter+lookAhead] 内匹配：计数器前进到匹配值 + 1
+// 2. 上次超窗的验证码之后紧跟下一个验证码：完成重新同步
+// 3. 在更大范围内匹配：记录待同步计数器并返回 ErrHOTPResync（此时需要保存 cfg）
+func CheckHOTP(cfg *Config, seed []byte, code string) error {
+	lookAhead := cfg.HOTPLookAhead
+	if lookAhead <= 0 {
+		lookAhead = DefaultHOTPLookAhead
+	}
+
+	if c, ok := matchHOTP(seed, code, cfg.Algorithm, cfg.Digits, cfg.HOTPCounter, lookAhead); ok {
+		cfg.HOTPCounter = c + 1
+		cfg.HOTPResyncCounter = 0
+		return nil
+	}
+
+	if cfg.HOTPResyncCounter > 0 {
+		next := cfg.HOTPResyncCounter + 1
+		if hmac.Equal([]byte(code), []byte(Generate(seed, next, cfg.Algorithm, cfg.Digits))) {
+			cfg.HOTPCounter = next + 1
+			cfg.HOTPResyncCounter = 0
+			return nil
+		}
+	}
+
+	from := cfg.HOTPCounter + int64(lookAhead) + 1
+	if c, ok := matchHOTP(seed, code, cfg.Algorithm, cfg.Digits, from, hotpResyncWindow); ok {
+		cfg.HOTPResyncCounter = c
+		return ErrHOTPResync
+	}
+
+	return ErrInvalidCode
+}
@@ -2,24 +2,23 @@ package otp
 
 import (
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
-	"os"
 	"time"
 
 	"fssh/internal/crypt"
-
-	"golang.org/x/crypto/pbkdf2"
 )
 
 // InitOptions OTP 初始化选项
 type InitOptions struct {
 	Password         string // OTP 密码
 	SeedUnlockTTL    int    // OTP seed 缓存时间（秒）
-	Algorithm        string // TOTP 算法: SHA1, SHA256, SHA512
+	Type             string // OTP 类型: totp 或 hotp
+	Algorithm        string // TOTP/HOTP 算法: SHA1, SHA256, SHA512
 	Digits           int    // 验证码位数: 6 或 8
 	Period           int    // TOTP 时间窗口（秒）
+	SkewSteps        int    // 允许的时钟偏移窗口数（±N 个周期）
+	LookAhead        int    // HOTP 前向窗口（计数器数）
 	GenerateRecovery bool   // 是否生成恢复码
 }
 
@@ -27,17 +26,26 @@ type InitOptions struct {
 func DefaultInitOptions() *InitOptions {
 	return &InitOptions{
 		SeedUnlockTTL:    3600, // 1小时
+		Type:             TypeTOTP,
 		Algorithm:        "SHA1",
 		Digits:           6,
 		Period:           30,
+		SkewSteps:        DefaultSkewSteps,
+		LookAhead:        DefaultHOTPLookAhead,
 		GenerateRecovery: true,
 	}
 }
 
+// MaxSkewSteps 允许配置的最大时钟偏移窗口数
+const MaxSkewSteps = 5
+
+// MaxHOTPLookAhead 允许配置的最大 HOTP 前向窗口
+const MaxHOTPLookAhead = 50
+
 // Initialize 初始化 OTP 配置
 // 1. 生成随机 OTP seed
 // 2. 使用密码加密 seed
-// 3. 生成恢复码
+// 3. 生成恢复码（每个恢复码包裹一份 seed 副本）
 // 4. 保存配置
 func Initialize(opts *InitOptions) (seed []byte, recoveryCodes []string, err error) {
 	// 验证密码强度
@@ -45,63 +53,69 @@ func Initialize(opts *InitOptions) (seed []byte, recoveryCodes []string, err err
 		return nil, nil, fmt.Errorf("密码强度不足: %w", err)
 	}
 
-	// 1. 生成随机 OTP seed (20 字节，标准 TOTP seed 长度)
-	seed = make([]byte, 20)
-	if _, err := rand.Read(seed); err != nil {
-		return nil, nil, fmt.Errorf("生成 OTP seed 失败: %w", err)
+	switch opts.Type {
+	case "", TypeTOTP:
+	case TypeHOTP:
+		if opts.LookAhead == 0 {
+			opts.LookAhead = DefaultHOTPLookAhead
+		}
+		if opts.LookAhead < 1 || opts.LookAhead > MaxHOTPLookAhead {
+			return nil, nil, fmt.Errorf("HOTP 前向窗口必须在 1-%d 之间", MaxHOTPLookAhead)
+		}
+	default:
+		return nil, nil, fmt.Errorf("不支持的 OTP 类型: %s（支持 totp, hotp）", opts.Type)
 	}
 
-	// 2. 生成加密参数
-	seedSalt := make([]byte, 32)
-	if _, err := rand.Read(seedSalt); err != nil {
-		return nil, nil, fmt.Errorf("生成 seed salt 失败: %w", err)
+	if opts.SkewSteps < 1 || opts.SkewSteps > MaxSkewSteps {
+		return nil, nil, fmt.Errorf("时钟偏移窗口必须在 1-%d 之间", MaxSkewSteps)
 	}
 
-	seedNonce := make([]byte, 12) // AES-GCM nonce 12 字节
-	if _, err := rand.Read(seedNonce); err != nil {
-		return nil, nil, fmt.Errorf("生成 seed nonce 失败: %w", err)
+	// 1. 生成随机 OTP seed (20 字节，标准 TOTP seed 长度)
+	seed = make([]byte, 20)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, nil, fmt.Errorf("生成 OTP seed 失败: %w", err)
 	}
 
+	// 2. 生成 master key 派生参数
 	masterKeySalt := make([]byte, 32)
 	if _, err := rand.Read(masterKeySalt); err != nil {
 		return nil, nil, fmt.Errorf("生成 master key salt 失败: %w", err)
 	}
 
-	// 3. 派生加密密钥 (PBKDF2)
-	encKey := pbkdf2.Key([]byte(opts.Password), seedSalt, 100000, 32, sha256.New)
-
-	// 4. 加密 OTP seed
-	encryptedSeed, err := crypt.EncryptAEAD(encKey, seedNonce, seed, nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("加密 OTP seed 失败: %w", err)
-	}
-
-	// 5. 生成恢复码
-	var recoveryCodesHash []string
-	if opts.GenerateRecovery {
-		recoveryCodes, err = GenerateRecoveryCodes(10)
-		if err != nil {
-			return nil, nil, fmt.Errorf("生成恢复码失败: %w", err)
-		}
-		recoveryCodesHash = HashRecoveryCodes(recoveryCodes)
-	}
-
-	// 6. 创建配置
+	// 3. 创建配置
 	cfg := &Config{
 		Version:              "fssh-otp/v1",
+		Type:                 opts.Type,
 		Algorithm:            opts.Algorithm,
 		Digits:               opts.Digits,
 		Period:               opts.Period,
-		EncryptedSeed:        base64.StdEncoding.EncodeToString(encryptedSeed),
-		SeedSalt:             base64.StdEncoding.EncodeToString(seedSalt),
-		SeedNonce:            base64.StdEncoding.EncodeToString(seedNonce),
+		SkewSteps:            opts.SkewSteps,
 		MasterKeySalt:        base64.StdEncoding.EncodeToString(masterKeySalt),
 		SeedUnlockTTLSeconds: opts.SeedUnlockTTL,
-		RecoveryCodesHash:    recoveryCodesHash,
 		CreatedAt:            time.Now().Format(time.RFC3339),
 	}
 
-	// 7. 保存配置
+	if cfg.OTPType() == TypeHOTP {
+		cfg.HOTPLookAhead = opts.LookAhead
+	}
+
+	// 4. 使用密码加密 OTP seed (Argon2id + AES-GCM)
+	if err := SealSeedWithKDF(cfg, seed, opts.Password, crypt.DefaultKDFParams()); err != nil {
+		return nil, nil, err
+	}
+
+	// 5. 生成恢复码，每个恢复码各自加密一份 seed 副本
+	if opts.GenerateRecovery {
+		recoveryCodes, err = GenerateRecoveryCodes(10)
+		if err != nil {
+			return nil, nil, fmt.Errorf("生成恢复码失败: %w", err)
+		}
+		if err := SetRecoveryCodes(cfg, seed, recoveryCodes); err != nil {
+			return nil, nil, fmt.Errorf("生成恢复码失败: %w", err)
+		}
+	}
+
+	// 6. 保存配置
 	if err := SaveConfig(cfg); err != nil {
 		return nil, nil, fmt.Errorf("保存配置失败: %w", err)
 	}
@@ -110,31 +124,22 @@ func Initialize(opts *InitOptions) (seed []byte, recoveryCodes []string, err err
 }
 
 // DisplayInitResult 显示初始化结果
-func DisplayInitResult(seed []byte, recoveryCodes []string, algorithm string, digits int, period int) error {
-	// 获取账户信息
-	hostname, _ := os.Hostname()
-	user := os.Getenv("USER")
-	account := fmt.Sprintf("%s@%s", user, hostname)
-
-	// 显示 OTP 设置
-	secret := base64.StdEncoding.EncodeToString(seed)
-
+func DisplayInitResult(seed []byte, recoveryCodes []string, cfg *Config) error {
 	fmt.Println()
 	fmt.Println("OTP 认证已初始化")
 	fmt.Println("================")
 	fmt.Println()
 
-	// 显示配置信息
-	fmt.Println("TOTP 配置:")
-	fmt.Printf("  发行者: fssh\n")
-	fmt.Printf("  账户: %s\n", account)
-	fmt.Printf("  密钥: %s\n", secret)
-	fmt.Printf("  算法: %s\n", algorithm)
-	fmt.Printf("  位数: %d\n", digits)
-	fmt.Printf("  间隔: %d秒\n", period)
-	fmt.Println()
+	// 显示注册信息（密钥、URI、二维码）
+	if err := DisplayEnrollment(seed, cfg); err != nil {
+		return err
+	}
 
-	fmt.Println("请将以上信息添加到 TOTP 认证器应用（如 Google Authenticator, Authy）")
+	if cfg.OTPType() == TypeHOTP {
+		fmt.Println("请将以上信息添加到支持 HOTP 的认证器或硬件令牌")
+	} else {
+		fmt.Println("请将以上信息添加到 TOTP 认证器应用（如 Google Authenticator, Authy）")
+	}
 	fmt.Println()
 
 	// 显示恢复码
@@ -157,6 +162,7 @@ func DisplayInitResult(seed []byte, recoveryCodes []string, algorithm string, di
 	fmt.Println("下一步:")
 	fmt.Println("  1. 导入 SSH 私钥: fssh import -alias myserver -file ~/.ssh/id_rsa")
 	fmt.Println("  2. 启动 agent: fssh agent")
+	fmt.Println("  3. 重新显示注册信息: fssh otp show-enrollment")
 	fmt.Println()
 
 	return nil
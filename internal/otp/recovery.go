@@ -3,13 +3,34 @@ package otp
 import (
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
+
+	"fssh/internal/crypt"
 )
 
 const recoveryCodeLength = 16 // XXXX-XXXX-XXXX-XXXX
 
+// usedRecoveryPrefix 已使用恢复码在 RecoveryCodesHash 中的前缀
+const usedRecoveryPrefix = "used:"
+
+// recoveryKDF 恢复码包裹 seed 使用的 KDF
+// 恢复码本身约有 79 位熵，不依赖慢速 KDF 抵抗暴力破解，使用 PBKDF2 避免初始化时过慢
+var recoveryKDF = crypt.LegacyKDFParams()
+
+// RecoverySeed 使用单个恢复码加密的 OTP seed 副本
+type RecoverySeed struct {
+	Salt       string `json:"salt"`       // Base64 编码的 PBKDF2 salt (32 bytes)
+	Nonce      string `json:"nonce"`      // Base64 编码的 AES-GCM nonce (12 bytes)
+	Ciphertext string `json:"ciphertext"` // Base64 编码的密文
+}
+
+// ErrRecoveryNotSupported 恢复码由旧版本生成，没有包裹 seed 副本
+var ErrRecoveryNotSupported = errors.New("恢复码由旧版本生成，不包含 seed 副本，无法用于恢复；请运行 fssh otp recovery-codes --regenerate")
+
 // GenerateRecoveryCodes 生成恢复码
 func GenerateRecoveryCodes(count int) ([]string, error) {
 	codes := make([]string, count)
@@ -62,6 +83,84 @@ func HashRecoveryCodes(codes []string) []string {
 	return hashes
 }
 
+// NormalizeRecoveryCode 规范化用户输入的恢复码（忽略大小写、空格和分隔符）
+func NormalizeRecoveryCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	raw := b.String()
+	if len(raw) != recoveryCodeLength {
+		return code
+	}
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+}
+
+// SetRecoveryCodes 为每个恢复码加密一份 seed 副本，并替换配置中的恢复码
+func SetRecoveryCodes(cfg *Config, seed []byte, codes []string) error {
+	seeds := make([]RecoverySeed, len(codes))
+	for i, code := range codes {
+		salt, nonce, ct, err := sealWithSecret(recoveryKDF, code, seed)
+		if err != nil {
+			return err
+		}
+		seeds[i] = RecoverySeed{
+			Salt:       base64.StdEncoding.EncodeToString(salt),
+			Nonce:      base64.StdEncoding.EncodeToString(nonce),
+			Ciphertext: base64.StdEncoding.EncodeToString(ct),
+		}
+	}
+	cfg.RecoveryCodesHash = HashRecoveryCodes(codes)
+	cfg.RecoverySeeds = seeds
+	return nil
+}
+
+// RecoverSeed 使用恢复码解密 OTP seed，返回 seed 和恢复码索引
+// 不会标记恢复码为已使用，调用方确认恢复完成后调用 MarkRecoveryCodeUsed
+func RecoverSeed(cfg *Config, code string) ([]byte, int, error) {
+	code = NormalizeRecoveryCode(code)
+	ok, idx := VerifyRecoveryCode(code, cfg.RecoveryCodesHash)
+	if !ok {
+		return nil, -1, errors.New("恢复码无效或已使用")
+	}
+	if idx >= len(cfg.RecoverySeeds) || cfg.RecoverySeeds[idx].Ciphertext == "" {
+		return nil, -1, ErrRecoveryNotSupported
+	}
+
+	entry := cfg.RecoverySeeds[idx]
+	seed, err := openWithSecret(recoveryKDF, code, entry.Salt, entry.Nonce, entry.Ciphertext)
+	if err != nil {
+		return nil, -1, fmt.Errorf("解密 seed 副本失败: %w", err)
+	}
+	return seed, idx, nil
+}
+
+// MarkRecoveryCodeUsed 将恢复码标记为已使用，并删除对应的 seed 副本
+func MarkRecoveryCodeUsed(cfg *Config, index int) {
+	if index < 0 || index >= len(cfg.RecoveryCodesHash) {
+		return
+	}
+	if !strings.HasPrefix(cfg.RecoveryCodesHash[index], usedRecoveryPrefix) {
+		cfg.RecoveryCodesHash[index] = usedRecoveryPrefix + cfg.RecoveryCodesHash[index]
+	}
+	if index < len(cfg.RecoverySeeds) {
+		cfg.RecoverySeeds[index] = RecoverySeed{}
+	}
+}
+
+// RemainingRecoveryCodes 返回未使用的恢复码数量
+func RemainingRecoveryCodes(cfg *Config) int {
+	n := 0
+	for _, h := range cfg.RecoveryCodesHash {
+		if !strings.HasPrefix(h, usedRecoveryPrefix) {
+			n++
+		}
+	}
+	return n
+}
+
 // VerifyRecoveryCode 验证恢复码
 // 返回是否有效和在哈希列表中的索引
 func VerifyRecoveryCode(code string, hashes []string) (bool, int) {
@@ -0,0 +1,109 @@
+package otp
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"fssh/internal/crypt"
+)
+
+// ErrWrongPassword 密码错误或配置文件损坏，无法解密 OTP seed
+var ErrWrongPassword = errors.New("密码错误或配置文件损坏")
+
+// SeedKDF 返回加密 OTP seed 使用的 KDF 参数
+// 旧版本配置没有 kdf 字段，使用 PBKDF2-SHA256 100k 迭代
+func (c *Config) SeedKDF() crypt.KDFParams {
+	if c.KDF == nil {
+		return crypt.LegacyKDFParams()
+	}
+	return *c.KDF
+}
+
+// NeedsKDFUpgrade 配置是否仍在使用需要升级的旧 KDF 参数
+func NeedsKDFUpgrade(cfg *Config) bool {
+	return cfg.SeedKDF().IsLegacy()
+}
+
+// sealWithSecret 使用口令派生的密钥加密数据，返回 salt、nonce 和密文
+func sealWithSecret(kdf crypt.KDFParams, secret string, plaintext []byte) (salt, nonce, ciphertext []byte, err error) {
+	salt = make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, nil, fmt.Errorf("生成 salt 失败: %w", err)
+	}
+
+	nonce = make([]byte, 12) // AES-GCM nonce 12 字节
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, nil, fmt.Errorf("生成 nonce 失败: %w", err)
+	}
+
+	key, err := kdf.DeriveKey([]byte(secret), salt, 32)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ciphertext, err = crypt.EncryptAEAD(key, nonce, plaintext, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return salt, nonce, ciphertext, nil
+}
+
+// openWithSecret 解密 sealWithSecret 生成的 base64 编码数据
+func openWithSecret(kdf crypt.KDFParams, secret string, saltB64, nonceB64, ciphertextB64 string) ([]byte, error) {
+	salt, err := base64.StdEncoding.DecodeString(saltB64)
+	if err != nil {
+		return nil, fmt.Errorf("解码 salt 失败: %w", err)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(nonceB64)
+	if err != nil {
+		return nil, fmt.Errorf("解码 nonce 失败: %w", err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextB64)
+	if err != nil {
+		return nil, fmt.Errorf("解码密文失败: %w", err)
+	}
+
+	key, err := kdf.DeriveKey([]byte(secret), salt, 32)
+	if err != nil {
+		return nil, err
+	}
+	return crypt.DecryptAEAD(key, nonce, ciphertext, nil)
+}
+
+// DecryptSeed 使用密码解密配置中的 OTP seed
+func DecryptSeed(cfg *Config, password string) ([]byte, error) {
+	seed, err := openWithSecret(cfg.SeedKDF(), password, cfg.SeedSalt, cfg.SeedNonce, cfg.EncryptedSeed)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	return seed, nil
+}
+
+// SealSeed 使用新密码加密 OTP seed 并写入配置（每次生成新的 salt 和 nonce）
+// 沿用配置中的 KDF 参数；旧版参数会同时升级为默认参数
+func SealSeed(cfg *Config, seed []byte, password string) error {
+	kdf := cfg.SeedKDF()
+	if kdf.IsLegacy() {
+		kdf = crypt.DefaultKDFParams()
+	}
+	return SealSeedWithKDF(cfg, seed, password, kdf)
+}
+
+// SealSeedWithKDF 使用指定的 KDF 参数加密 OTP seed 并写入配置
+func SealSeedWithKDF(cfg *Config, seed []byte, password string, kdf crypt.KDFParams) error {
+	if err := kdf.Validate(); err != nil {
+		return err
+	}
+	salt, nonce, ct, err := sealWithSecret(kdf, password, seed)
+	if err != nil {
+		return fmt.Errorf("加密 OTP seed 失败: %w", err)
+	}
+	cfg.KDF = &kdf
+	cfg.EncryptedSeed = base64.StdEncoding.EncodeToString(ct)
+	cfg.SeedSalt = base64.StdEncoding.EncodeToString(salt)
+	cfg.SeedNonce = base64.StdEncoding.EncodeToString(nonce)
+	return nil
+}
@@ -6,26 +6,75 @@ import (
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"hash"
 	"math"
 	"time"
 )
 
+// DefaultSkewSteps 默认允许的时间窗口偏移数（±1 个周期）
+const DefaultSkewSteps = 1
+
+// driftSearchSteps 检测时钟偏移时向前/向后搜索的最大周期数
+const driftSearchSteps = 10
+
+var (
+	// ErrInvalidCode 验证码不匹配
+	ErrInvalidCode = errors.New("验证码错误或已过期")
+	// ErrCodeReused 验证码已被使用过（重放）
+	ErrCodeReused = errors.New("验证码已使用，请等待下一个验证码")
+)
+
 // Verify 验证 TOTP 验证码
 // 支持 ±1 个时间窗口容错（±30秒），防止时钟偏移
+// 不做重放检查，需要重放保护时使用 VerifyCounter
 func Verify(seed []byte, userCode string, algorithm string, digits int, period int) bool {
-	now := time.Now().Unix()
-	counter := now / int64(period)
+	_, err := VerifyCounter(seed, userCode, algorithm, digits, period, DefaultSkewSteps, -1)
+	return err == nil
+}
+
+// VerifyCounter 在 ±skew 个时间窗口内验证 TOTP 验证码
+// 返回匹配的计数器值；计数器不大于 lastCounter 的验证码视为重放并拒绝
+func VerifyCounter(seed []byte, userCode string, algorithm string, digits int, period int, skew int, lastCounter int64) (int64, error) {
+	if skew < 0 {
+		skew = 0
+	}
+	counter := time.Now().Unix() / int64(period)
+
+	reused := false
+	for offset := int64(-skew); offset <= int64(skew); offset++ {
+		c := counter + offset
+		expected := Generate(seed, c, algorithm, digits)
+		if !hmac.Equal([]byte(userCode), []byte(expected)) {
+			continue
+		}
+		if c <= lastCounter {
+			reused = true
+			continue
+		}
+		return c, nil
+	}
+	if reused {
+		return 0, ErrCodeReused
+	}
+	return 0, ErrInvalidCode
+}
+
+// DetectDrift 在验证窗口之外搜索验证码，估算本机与认证器之间的时钟偏移
+// 返回偏移的周期数（正数表示认证器时间超前本机），找不到时 ok 为 false
+func DetectDrift(seed []byte, userCode string, algorithm string, digits int, period int, skew int) (steps int, ok bool) {
+	counter := time.Now().Unix() / int64(period)
 
-	// 允许 ±1 个时间窗口（±30秒容错）
-	for offset := int64(-1); offset <= 1; offset++ {
-		expected := Generate(seed, counter+offset, algorithm, digits)
-		if userCode == expected {
-			return true
+	for d := skew + 1; d <= driftSearchSteps; d++ {
+		for _, s := range []int{d, -d} {
+			expected := Generate(seed, counter+int64(s), algorithm, digits)
+			if hmac.Equal([]byte(userCode), []byte(expected)) {
+				return s, true
+			}
 		}
 	}
-	return false
+	return 0, false
 }
 
 // Generate 生成 TOTP 验证码
@@ -0,0 +1,146 @@
+// Package profile 管理相互隔离的 fssh 配置
+// 每个 profile 拥有独立的密钥库、认证模式、OTP 配置、config.json、agent socket 和 Keychain 账户。
+// default profile 使用原有的 ~/.fssh 路径，其他 profile 位于 ~/.fssh/profiles/<name>
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// DefaultName 默认 profile 名称
+const DefaultName = "default"
+
+// EnvVar 选择 profile 的环境变量
+const EnvVar = "FSSH_PROFILE"
+
+// keychainAccount default profile 的 Keychain 账户名
+const keychainAccount = "master_key_v1"
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,31}$`)
+
+// selected 通过 --profile 选择的 profile，优先于环境变量
+var selected string
+
+// Validate 检查 profile 名称是否合法
+func Validate(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("无效的 profile 名称 %q（字母、数字、- 和 _，最长 32 位）", name)
+	}
+	return nil
+}
+
+// Select 选择当前进程使用的 profile
+func Select(name string) error {
+	if err := Validate(name); err != nil {
+		return err
+	}
+	selected = name
+	return nil
+}
+
+// Current 返回当前 profile：--profile > FSSH_PROFILE > default
+func Current() string {
+	if selected != "" {
+		return selected
+	}
+	if env := os.Getenv(EnvVar); env != "" {
+		return env
+	}
+	return DefaultName
+}
+
+// IsDefault 当前是否为 default profile
+func IsDefault() bool {
+	return Current() == DefaultName
+}
+
+// BaseDir 返回 fssh 根目录 ~/.fssh
+func BaseDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".fssh")
+}
+
+func profilesDir() string {
+	return filepath.Join(BaseDir(), "profiles")
+}
+
+// DirFor 返回指定 profile 的数据目录
+func DirFor(name string) string {
+	if name == DefaultName {
+		return BaseDir()
+	}
+	return filepath.Join(profilesDir(), name)
+}
+
+// Dir 返回当前 profile 的数据目录
+func Dir() string {
+	return DirFor(Current())
+}
+
+// KeychainAccountFor 返回指定 profile 在 Keychain 中的账户名
+func KeychainAccountFor(name string) string {
+	if name == DefaultName {
+		return keychainAccount
+	}
+	return keychainAccount + ":" + name
+}
+
+// KeychainAccount 返回当前 profile 在 Keychain 中的账户名
+func KeychainAccount() string {
+	return KeychainAccountFor(Current())
+}
+
+// Exists 检查 profile 是否存在，default 始终存在
+func Exists(name string) bool {
+	if name == DefaultName {
+		return true
+	}
+	info, err := os.Stat(DirFor(name))
+	return err == nil && info.IsDir()
+}
+
+// List 返回所有 profile 名称，default 排在最前
+func List() ([]string, error) {
+	entries, err := os.ReadDir(profilesDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && Validate(e.Name()) == nil && e.Name() != DefaultName {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultName}, names...), nil
+}
+
+// Create 创建 profile 目录
+func Create(name string) error {
+	if err := Validate(name); err != nil {
+		return err
+	}
+	if Exists(name) {
+		return fmt.Errorf("profile %s 已存在", name)
+	}
+	return os.MkdirAll(DirFor(name), 0700)
+}
+
+// Delete 删除 profile 目录及其中的所有数据
+func Delete(name string) error {
+	if name == DefaultName {
+		return errors.New("不能删除 default profile")
+	}
+	if err := Validate(name); err != nil {
+		return err
+	}
+	if !Exists(name) {
+		return fmt.Errorf("profile %s 不存在", name)
+	}
+	return os.RemoveAll(DirFor(name))
+}
@@ -0,0 +1,242 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// maskFuncs 标准中的 8 种掩码条件，独立于 applyMask 实现，用于解码
+var maskFuncs = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// readFormat 读取左上角的格式信息，与 32 个合法码字比较，返回纠错等级和掩码
+func readFormat(t *testing.T, c *Code) (Level, int) {
+	t.Helper()
+	read := func(copy2 bool) int {
+		bits := 0
+		set := func(i int, dark bool) {
+			if dark {
+				bits |= 1 << uint(i)
+			}
+		}
+		if !copy2 {
+			for i := 0; i <= 5; i++ {
+				set(i, c.Dark(8, i))
+			}
+			set(6, c.Dark(8, 7))
+			set(7, c.Dark(8, 8))
+			set(8, c.Dark(7, 8))
+			for i := 9; i < 15; i++ {
+				set(i, c.Dark(14-i, 8))
+			}
+			return bits
+		}
+		for i := 0; i < 8; i++ {
+			set(i, c.Dark(c.Size-1-i, 8))
+		}
+		for i := 8; i < 15; i++ {
+			set(i, c.Dark(8, c.Size-15+i))
+		}
+		return bits
+	}
+	first, second := read(false), read(true)
+	if first != second {
+		t.Fatalf("format copies differ: %015b vs %015b", first, second)
+	}
+	for level := LevelL; level <= LevelH; level++ {
+		for mask := 0; mask < 8; mask++ {
+			data := level.formatBits()<<3 | mask
+			rem := data
+			for i := 0; i < 10; i++ {
+				rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+			}
+			if (data<<10|rem)^0x5412 == first {
+				return level, mask
+			}
+		}
+	}
+	t.Fatalf("format bits %015b are not a valid codeword", first)
+	return 0, 0
+}
+
+// decode 从模块矩阵读回数据：去掩码、按之字形读取码字、解交织、校验纠错码、解析字节模式
+func decode(t *testing.T, c *Code) (Level, []byte) {
+	t.Helper()
+	if c.Size != c.Version*4+17 {
+		t.Fatalf("size %d does not match version %d", c.Size, c.Version)
+	}
+	// 三个定位图形
+	for _, corner := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				d := max(abs(dx-3), abs(dy-3))
+				if c.Dark(corner[0]+dx, corner[1]+dy) != (d != 2) {
+					t.Fatalf("finder pattern at %v is wrong", corner)
+				}
+			}
+		}
+	}
+	if !c.Dark(8, c.Size-8) {
+		t.Fatal("dark module is missing")
+	}
+
+	level, mask := readFormat(t, c)
+	fn := newCode(c.Version)
+	fn.drawFunctionPatterns(level)
+
+	var raw []byte
+	var cur byte
+	n := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if fn.isFunction[y][x] {
+					continue
+				}
+				bit := c.Dark(x, y) != maskFuncs[mask](x, y)
+				cur <<= 1
+				if bit {
+					cur |= 1
+				}
+				if n++; n%8 == 0 {
+					raw = append(raw, cur)
+					cur = 0
+				}
+			}
+		}
+	}
+	rawCodewords := numRawDataModules(c.Version) / 8
+	if len(raw) != rawCodewords {
+		t.Fatalf("read %d codewords, want %d", len(raw), rawCodewords)
+	}
+
+	// 解交织并逐块校验 Reed-Solomon 余数
+	numBlocks := numErrorCorrectionBlocks[level][c.Version]
+	eccLen := eccCodewordsPerBlock[level][c.Version]
+	numShort := numBlocks - rawCodewords%numBlocks
+	shortData := rawCodewords/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i < shortData+1; i++ {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	divisor := rsDivisor(eccLen)
+	var data []byte
+	for j := range blocks {
+		ecc := make([]byte, eccLen)
+		for i := range ecc {
+			ecc[i] = raw[k+i*numBlocks+j]
+		}
+		if !bytes.Equal(rsRemainder(blocks[j], divisor), ecc) {
+			t.Fatalf("block %d: error correction codewords do not match", j)
+		}
+		data = append(data, blocks[j]...)
+	}
+
+	// 字节模式：4 位模式指示符 + 字符计数 + 数据
+	bit := func(i int) int { return int(data[i>>3]>>(7-uint(i&7))) & 1 }
+	readBits := func(pos, n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | bit(pos+i)
+		}
+		return v
+	}
+	if m := readBits(0, 4); m != 0x4 {
+		t.Fatalf("mode indicator %#x, want byte mode", m)
+	}
+	count := readBits(4, charCountBits(c.Version))
+	pos := 4 + charCountBits(c.Version)
+	out := make([]byte, count)
+	for i := range out {
+		out[i] = byte(readBits(pos+i*8, 8))
+	}
+	return level, out
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	uri := "otpauth://totp/fssh:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=fssh&algorithm=SHA1&digits=6&period=30"
+	for _, tc := range []struct {
+		data    string
+		level   Level
+		version int
+	}{
+		{"", LevelL, 1},
+		{"HELLO", LevelM, 1},
+		// 版本 1-L 最多 17 字节
+		{strings.Repeat("a", 17), LevelL, 1},
+		{strings.Repeat("a", 18), LevelL, 2},
+		// 版本 6-M 最多 106 字节
+		{uri, LevelM, 7},
+		// 版本 7 及以上包含版本信息，多个纠错块且长短块混合
+		{strings.Repeat("x", 200), LevelQ, 12},
+		{strings.Repeat("0123456789", 40), LevelH, 21},
+	} {
+		c, err := Encode([]byte(tc.data), tc.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Version != tc.version {
+			t.Errorf("len=%d level=%d: version %d, want %d", len(tc.data), tc.level, c.Version, tc.version)
+		}
+		level, got := decode(t, c)
+		if level != tc.level {
+			t.Errorf("len=%d: format level %d, want %d", len(tc.data), level, tc.level)
+		}
+		if string(got) != tc.data {
+			t.Errorf("len=%d level=%d: decoded %q", len(tc.data), tc.level, got)
+		}
+	}
+}
+
+func TestEncodeVersionInfo(t *testing.T) {
+	c, err := Encode(bytes.Repeat([]byte("x"), 200), LevelQ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 版本 12 的版本信息码字为 0x0C762（ISO/IEC 18004 表 D.1）
+	if c.Version != 12 {
+		t.Fatalf("version %d, want 12", c.Version)
+	}
+	want := 0x0C762
+	for i := 0; i < 18; i++ {
+		dark := want>>uint(i)&1 != 0
+		a, b := c.Size-11+i%3, i/3
+		if c.Dark(a, b) != dark || c.Dark(b, a) != dark {
+			t.Fatalf("version info bit %d is wrong", i)
+		}
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	// 版本 40-L 字节模式最多 2953 字节
+	if _, err := Encode(make([]byte, 2953), LevelL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Encode(make([]byte, 2954), LevelL); !errors.Is(err, ErrTooLong) {
+		t.Fatalf("got %v, want ErrTooLong", err)
+	}
+}
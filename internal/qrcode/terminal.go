@@ -0,0 +1,36 @@
+package qrcode
+
+import (
+	"bufio"
+	"io"
+)
+
+// quietZone 四周留白的模块数
+const quietZone = 4
+
+const (
+	fgBlack = "30"
+	fgWhite = "97"
+	bgBlack = "40"
+	bgWhite = "107"
+)
+
+// WriteTerminal 使用 Unicode 半块字符和 ANSI 颜色把 QR 码输出到终端
+// 每个字符表示上下两个模块；深浅两色都显式着色，不受终端背景色影响
+func (c *Code) WriteTerminal(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for y := -quietZone; y < c.Size+quietZone; y += 2 {
+		for x := -quietZone; x < c.Size+quietZone; x++ {
+			fg, bg := fgWhite, bgWhite
+			if c.Dark(x, y) {
+				fg = fgBlack
+			}
+			if c.Dark(x, y+1) {
+				bg = bgBlack
+			}
+			bw.WriteString("\x1b[" + fg + ";" + bg + "m▀")
+		}
+		bw.WriteString("\x1b[0m\n")
+	}
+	return bw.Flush()
+}
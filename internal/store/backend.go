@@ -0,0 +1,170 @@
+package store
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "fssh/internal/config"
+    "fssh/internal/fsutil"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("key not found")
+
+// ErrExists 记录已存在
+var ErrExists = errors.New("key already exists")
+
+// Backend 加密记录的存储后端
+// 后端只保存和读取 EncryptedFile，不接触明文私钥；加解密由 SaveRecord/LoadRecord 完成
+type Backend interface {
+    // List 返回所有记录的元数据（按别名排序，不解密）
+    List() ([]EncryptedFile, error)
+    // Get 读取单条记录，不存在时返回 ErrNotFound
+    Get(alias string) (*EncryptedFile, error)
+    // Put 原子地写入记录，已存在时覆盖
+    Put(ef *EncryptedFile) error
+    // Delete 删除记录，不存在时返回 ErrNotFound
+    Delete(alias string) error
+    // Rename 用 ef（ef.Alias 为新别名）替换 oldAlias 记录
+    // 别名参与密钥派生，ef 需要用新别名重新加密，见 RenameRecord
+    Rename(oldAlias string, ef *EncryptedFile) error
+}
+
+// Relocatable 以文件系统路径为根的后端
+// rekey 和切换认证模式把记录写入同类型的暂存后端，再用 rename 整体替换
+type Relocatable interface {
+    Backend
+    // Root 返回后端的根路径（目录或文件）
+    Root() string
+    // At 返回以 root 为根的同类型后端
+    At(root string) Backend
+}
+
+// Default 返回当前配置的存储后端
+// config.json 中设置了 vault 时使用单文件保险库，否则使用 ~/.fssh/keys 目录
+func Default() Backend {
+    if cfg, err := config.Load(); err == nil && cfg.Vault != "" {
+        return NewVaultBackend(cfg.Vault)
+    }
+    return NewDirBackend(KeysDir())
+}
+
+// validateAlias 拒绝空别名和包含路径分隔符的别名
+func validateAlias(alias string) error {
+    if alias == "" || alias == "." || alias == ".." || strings.ContainsAny(alias, `/\`) {
+        return fmt.Errorf("invalid alias: %q", alias)
+    }
+    return nil
+}
+
+// DirBackend 每条记录保存为目录下的 <alias>.enc 文件
+type DirBackend struct {
+    Dir string
+}
+
+// NewDirBackend 创建目录后端
+func NewDirBackend(dir string) *DirBackend {
+    return &DirBackend{Dir: dir}
+}
+
+func (b *DirBackend) path(alias string) string {
+    return filepath.Join(b.Dir, alias+".enc")
+}
+
+func (b *DirBackend) List() ([]EncryptedFile, error) {
+    entries, err := os.ReadDir(b.Dir)
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+    var out []EncryptedFile
+    for _, e := range entries {
+        if e.IsDir() || !strings.HasSuffix(e.Name(), ".enc") {
+            continue
+        }
+        ef, err := b.Get(strings.TrimSuffix(e.Name(), ".enc"))
+        if err != nil {
+            // 跳过损坏的文件，避免一条记录影响其余记录
+            continue
+        }
+        out = append(out, *ef)
+    }
+    // 文件名排序时 a-b.enc 在 a.enc 之前，需要按别名重新排序
+    sortFiles(out)
+    return out, nil
+}
+
+func (b *DirBackend) Get(alias string) (*EncryptedFile, error) {
+    if err := validateAlias(alias); err != nil {
+        return nil, err
+    }
+    data, err := os.ReadFile(b.path(alias))
+    if os.IsNotExist(err) {
+        return nil, fmt.Errorf("%w: %s", ErrNotFound, alias)
+    }
+    if err != nil {
+        return nil, err
+    }
+    var ef EncryptedFile
+    if err := json.Unmarshal(data, &ef); err != nil {
+        return nil, err
+    }
+    return &ef, nil
+}
+
+func (b *DirBackend) Put(ef *EncryptedFile) error {
+    if err := validateAlias(ef.Alias); err != nil {
+        return err
+    }
+    if err := os.MkdirAll(b.Dir, 0700); err != nil {
+        return err
+    }
+    data, err := json.MarshalIndent(ef, "", "  ")
+    if err != nil {
+        return err
+    }
+    return fsutil.WriteFileAtomic(b.path(ef.Alias), data, 0600)
+}
+
+func (b *DirBackend) Delete(alias string) error {
+    if err := validateAlias(alias); err != nil {
+        return err
+    }
+    err := os.Remove(b.path(alias))
+    if os.IsNotExist(err) {
+        return fmt.Errorf("%w: %s", ErrNotFound, alias)
+    }
+    return err
+}
+
+// Rename 先写入新记录再删除旧记录，中断时最多留下两条都可用的记录
+func (b *DirBackend) Rename(oldAlias string, ef *EncryptedFile) error {
+    if _, err := b.Get(oldAlias); err != nil {
+        return err
+    }
+    if ef.Alias != oldAlias {
+        if _, err := os.Stat(b.path(ef.Alias)); err == nil {
+            return fmt.Errorf("%w: %s", ErrExists, ef.Alias)
+        }
+    }
+    if err := b.Put(ef); err != nil {
+        return err
+    }
+    if ef.Alias == oldAlias {
+        return nil
+    }
+    return os.Remove(b.path(oldAlias))
+}
+
+func (b *DirBackend) Root() string { return b.Dir }
+
+func (b *DirBackend) At(root string) Backend { return NewDirBackend(root) }
+
+// sortFiles 按别名排序
+func sortFiles(files []EncryptedFile) {
+    sort.Slice(files, func(i, j int) bool { return files[i].Alias < files[j].Alias })
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+    "crypto/ed25519"
+    "crypto/rand"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// testBackends 返回需要满足 Backend 约定的各个实现
+func testBackends(t *testing.T) map[string]Backend {
+    dir := t.TempDir()
+    return map[string]Backend{
+        "dir":    NewDirBackend(filepath.Join(dir, "keys")),
+        "vault":  NewVaultBackend(filepath.Join(dir, "vault.json")),
+        "memory": NewMemoryBackend(),
+    }
+}
+
+func testRecord(t *testing.T, alias string) *Record {
+    t.Helper()
+    _, priv, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    rec, err := newRecordFromKey(alias, priv, "test")
+    if err != nil {
+        t.Fatal(err)
+    }
+    return rec
+}
+
+func testMasterKey() []byte {
+    return []byte("0123456789abcdef0123456789abcdef")
+}
+
+func aliases(files []EncryptedFile) string {
+    var out []string
+    for _, ef := range files {
+        out = append(out, ef.Alias)
+    }
+    return strings.Join(out, ",")
+}
+
+func TestBackendContract(t *testing.T) {
+    mk := testMasterKey()
+    for name, b := range testBackends(t) {
+        t.Run(name, func(t *testing.T) {
+            files, err := b.List()
+            if err != nil || len(files) != 0 {
+                t.Fatalf("empty backend: List() = %v, %v", aliases(files), err)
+            }
+            if _, err := b.Get("a"); !errors.Is(err, ErrNotFound) {
+                t.Fatalf("Get missing: got %v, want ErrNotFound", err)
+            }
+            if err := b.Delete("a"); !errors.Is(err, ErrNotFound) {
+                t.Fatalf("Delete missing: got %v, want ErrNotFound", err)
+            }
+
+            // 按别名排序：a 在 a-b 之前，尽管文件名 a-b.enc 排在 a.enc 之前
+            recs := map[string]*Record{}
+            for _, alias := range []string{"b", "a-b", "a"} {
+                recs[alias] = testRecord(t, alias)
+                if err := SaveRecord(b, recs[alias], mk); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            files, err = b.List()
+            if err != nil {
+                t.Fatal(err)
+            }
+            if got := aliases(files); got != "a,a-b,b" {
+                t.Fatalf("List() = %s, want a,a-b,b", got)
+            }
+
+            got, err := LoadRecord(b, "a-b", mk)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if got.Fingerprint != recs["a-b"].Fingerprint || string(got.PKCS8DER) != string(recs["a-b"].PKCS8DER) {
+                t.Fatal("LoadRecord returned a different key")
+            }
+            if _, err := LoadRecord(b, "a-b", []byte("another master key, 32 bytes...")); err == nil {
+                t.Fatal("LoadRecord with the wrong master key succeeded")
+            }
+
+            // Put 覆盖同名记录
+            replacement := testRecord(t, "b")
+            if err := SaveRecord(b, replacement, mk); err != nil {
+                t.Fatal(err)
+            }
+            if got, err := LoadRecord(b, "b", mk); err != nil || got.Fingerprint != replacement.Fingerprint {
+                t.Fatalf("overwrite: got %v, %v", got, err)
+            }
+
+            // Rename 不能覆盖已有别名，改名后旧别名不存在，记录用新别名可以解密
+            if err := RenameRecord(b, "a", "b", mk); !errors.Is(err, ErrExists) {
+                t.Fatalf("rename onto existing alias: got %v, want ErrExists", err)
+            }
+            if err := RenameRecord(b, "missing", "c", mk); !errors.Is(err, ErrNotFound) {
+                t.Fatalf("rename missing: got %v, want ErrNotFound", err)
+            }
+            if err := RenameRecord(b, "a", "c", mk); err != nil {
+                t.Fatal(err)
+            }
+            if _, err := b.Get("a"); !errors.Is(err, ErrNotFound) {
+                t.Fatalf("old alias after rename: got %v, want ErrNotFound", err)
+            }
+            if got, err := LoadRecord(b, "c", mk); err != nil || got.Fingerprint != recs["a"].Fingerprint {
+                t.Fatalf("renamed record: got %v, %v", got, err)
+            }
+
+            if err := b.Delete("a-b"); err != nil {
+                t.Fatal(err)
+            }
+            files, err = b.List()
+            if err != nil {
+                t.Fatal(err)
+            }
+            if got := aliases(files); got != "b,c" {
+                t.Fatalf("List() after delete = %s, want b,c", got)
+            }
+
+            for _, alias := range []string{"", ".", "..", "x/y", `x\y`} {
+                rec := testRecord(t, "ok")
+                rec.Alias = alias
+                if err := SaveRecord(b, rec, mk); err == nil {
+                    t.Fatalf("SaveRecord accepted invalid alias %q", alias)
+                }
+            }
+        })
+    }
+}
+
+// 记录文件被改名或复制到另一个别名下时，别名参与认证，解密必须失败
+func TestRecordBoundToAlias(t *testing.T) {
+    mk := testMasterKey()
+    ef, err := sealRecord(testRecord(t, "a"), mk)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := decryptRecord(ef, "a", mk); err != nil {
+        t.Fatal(err)
+    }
+    moved := *ef
+    moved.Alias = "b"
+    if _, err := decryptRecord(&moved, "b", mk); err == nil {
+        t.Fatal("record decrypted under a different alias")
+    }
+}
+
+func TestListAllRejectsUnreadableFiles(t *testing.T) {
+    mk := testMasterKey()
+    b := NewDirBackend(t.TempDir())
+    if err := SaveRecord(b, testRecord(t, "good"), mk); err != nil {
+        t.Fatal(err)
+    }
+    files, err := listAll(b)
+    if err != nil || aliases(files) != "good" {
+        t.Fatalf("listAll() = %s, %v", aliases(files), err)
+    }
+
+    if err := os.WriteFile(filepath.Join(b.Dir, "broken.enc"), []byte("{"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    // List 跳过损坏的文件，listAll 必须报错
+    if files, err := b.List(); err != nil || aliases(files) != "good" {
+        t.Fatalf("List() = %s, %v", aliases(files), err)
+    }
+    if _, err := listAll(b); err == nil || !strings.Contains(err.Error(), "broken.enc") {
+        t.Fatalf("listAll() with a broken file: got %v", err)
+    }
+    if _, _, err := CreateBackup(b, mk, "backup passphrase"); err == nil {
+        t.Fatal("CreateBackup succeeded with an unreadable record file")
+    }
+}
+
+func TestMissingRecordFiles(t *testing.T) {
+    oldRoot, newRoot := t.TempDir(), t.TempDir()
+    for _, name := range []string{"a.enc", "b.enc", "notes.txt"} {
+        os.WriteFile(filepath.Join(oldRoot, name), nil, 0600)
+    }
+    os.WriteFile(filepath.Join(newRoot, "a.enc"), nil, 0600)
+    if got := strings.Join(missingRecordFiles(oldRoot, newRoot), ","); got != "b.enc" {
+        t.Fatalf("missingRecordFiles() = %q, want b.enc", got)
+    }
+    // 单文件保险库的备份不是目录
+    if got := missingRecordFiles(filepath.Join(oldRoot, "a.enc"), newRoot); len(got) != 0 {
+        t.Fatalf("missingRecordFiles(file) = %v", got)
+    }
+}
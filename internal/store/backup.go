@@ -0,0 +1,263 @@
+package store
+
+import (
+    "crypto/rand"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "fssh/internal/crypt"
+)
+
+const backupVersion = "fssh-backup/v1"
+
+// BackupKDFParams 备份口令使用的 KDF 参数：Argon2id t=4 m=256MiB p=4
+// 备份文件可能长期离线保存，参数比日常解锁使用的默认值更强
+func BackupKDFParams() crypt.KDFParams {
+    return crypt.KDFParams{Algorithm: crypt.KDFArgon2id, Time: 4, MemoryKiB: 256 * 1024, Threads: 4}
+}
+
+// backupHeader 备份文件的明文头部，整体作为 AEAD 附加数据
+type backupHeader struct {
+    Version   string          `json:"version"`
+    CreatedAt string          `json:"created_at"`
+    KDF       crypt.KDFParams `json:"kdf"`
+    Salt      string          `json:"salt"`
+    Nonce     string          `json:"nonce"`
+}
+
+// backupFile 备份文件结构
+// 所有记录（包括别名和公钥等元数据）序列化后一起加密，备份文件不泄露任何密钥信息
+type backupFile struct {
+    backupHeader
+    Ciphertext string `json:"ciphertext"`
+}
+
+// backupRecord 备份中的一条明文记录
+type backupRecord struct {
+    Alias       string   `json:"alias"`
+    Fingerprint string   `json:"fingerprint"`
+    Comment     string   `json:"comment"`
+    CreatedAt   string   `json:"created_at"`
+    Meta        *KeyMeta `json:"meta,omitempty"`
+    PKCS8       string   `json:"pkcs8"` // Base64 编码的 PKCS#8 DER
+}
+
+// Backup 解密后的备份内容
+type Backup struct {
+    CreatedAt string
+    Records   []*Record
+}
+
+// CreateBackup 用 master key 解密后端中的所有记录，再用备份口令整体加密
+// 备份与 master key 和认证模式无关，可在另一台机器上用 RestoreBackup 导入
+// 有无法解析的记录文件时返回错误，不生成缺少记录的备份
+func CreateBackup(b Backend, masterKey []byte, passphrase string) ([]byte, int, error) {
+    files, err := listAll(b)
+    if err != nil {
+        return nil, 0, err
+    }
+    records := make([]backupRecord, 0, len(files))
+    for i := range files {
+        ef := &files[i]
+        rec, err := decryptRecord(ef, ef.Alias, masterKey)
+        if err != nil {
+            return nil, 0, fmt.Errorf("decrypt %s: %w", ef.Alias, err)
+        }
+        records = append(records, backupRecord{
+            Alias:       rec.Alias,
+            Fingerprint: rec.Fingerprint,
+            Comment:     rec.Comment,
+            CreatedAt:   rec.CreatedAt,
+            Meta:        rec.Meta,
+            PKCS8:       base64.StdEncoding.EncodeToString(rec.PKCS8DER),
+        })
+    }
+    plaintext, err := json.Marshal(records)
+    if err != nil {
+        return nil, 0, err
+    }
+
+    salt, err := crypt.RandBytes(rand.Reader, 32)
+    if err != nil {
+        return nil, 0, err
+    }
+    nonce, err := crypt.RandBytes(rand.Reader, 12)
+    if err != nil {
+        return nil, 0, err
+    }
+    hdr := backupHeader{
+        Version:   backupVersion,
+        CreatedAt: time.Now().Format(time.RFC3339),
+        KDF:       BackupKDFParams(),
+        Salt:      base64.StdEncoding.EncodeToString(salt),
+        Nonce:     base64.StdEncoding.EncodeToString(nonce),
+    }
+    ad, err := json.Marshal(hdr)
+    if err != nil {
+        return nil, 0, err
+    }
+    key, err := hdr.KDF.DeriveKey([]byte(passphrase), salt, 32)
+    if err != nil {
+        return nil, 0, err
+    }
+    ct, err := crypt.EncryptAEAD(key, nonce, plaintext, ad)
+    if err != nil {
+        return nil, 0, err
+    }
+    data, err := json.MarshalIndent(backupFile{backupHeader: hdr, Ciphertext: base64.StdEncoding.EncodeToString(ct)}, "", "  ")
+    if err != nil {
+        return nil, 0, err
+    }
+    return data, len(records), nil
+}
+
+// OpenBackup 用备份口令解密备份文件，并校验每条记录的指纹
+func OpenBackup(data []byte, passphrase string) (*Backup, error) {
+    var bf backupFile
+    if err := json.Unmarshal(data, &bf); err != nil {
+        return nil, fmt.Errorf("解析备份文件失败: %w", err)
+    }
+    if bf.Version != backupVersion {
+        return nil, fmt.Errorf("不支持的备份版本: %s", bf.Version)
+    }
+    salt, err := base64.StdEncoding.DecodeString(bf.Salt)
+    if err != nil {
+        return nil, err
+    }
+    nonce, err := base64.StdEncoding.DecodeString(bf.Nonce)
+    if err != nil {
+        return nil, err
+    }
+    ct, err := base64.StdEncoding.DecodeString(bf.Ciphertext)
+    if err != nil {
+        return nil, err
+    }
+    ad, err := json.Marshal(bf.backupHeader)
+    if err != nil {
+        return nil, err
+    }
+    key, err := bf.KDF.DeriveKey([]byte(passphrase), salt, 32)
+    if err != nil {
+        return nil, err
+    }
+    plaintext, err := crypt.DecryptAEAD(key, nonce, ct, ad)
+    if err != nil {
+        return nil, errors.New("备份口令错误或备份文件损坏")
+    }
+    var records []backupRecord
+    if err := json.Unmarshal(plaintext, &records); err != nil {
+        return nil, fmt.Errorf("解析备份内容失败: %w", err)
+    }
+
+    out := &Backup{CreatedAt: bf.CreatedAt}
+    for _, br := range records {
+        der, err := base64.StdEncoding.DecodeString(br.PKCS8)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", br.Alias, err)
+        }
+        if err := checkPublicKey(&EncryptedFile{Fingerprint: br.Fingerprint}, der); err != nil {
+            return nil, fmt.Errorf("%s: %w", br.Alias, err)
+        }
+        out.Records = append(out.Records, &Record{
+            Alias:       br.Alias,
+            Fingerprint: br.Fingerprint,
+            Comment:     br.Comment,
+            CreatedAt:   br.CreatedAt,
+            Meta:        br.Meta,
+            PKCS8DER:    der,
+        })
+    }
+    return out, nil
+}
+
+// 恢复时别名冲突（本地已有同名但不同密钥的记录）的处理方式
+const (
+    ConflictSkip      = "skip"      // 跳过备份中的记录
+    ConflictRename    = "rename"    // 以 <alias>-restored 等新别名导入
+    ConflictOverwrite = "overwrite" // 覆盖本地记录
+)
+
+// 单条记录的恢复结果
+const (
+    RestoreAdded     = "added"
+    RestoreRenamed   = "renamed"
+    RestoreReplaced  = "replaced"
+    RestoreSkipped   = "skipped"   // 别名冲突，按 skip 跳过
+    RestoreDuplicate = "duplicate" // 本地已有相同指纹的密钥
+)
+
+// RestoreResult 一条备份记录的恢复结果
+type RestoreResult struct {
+    Alias  string // 备份中的别名
+    Target string // 导入后的别名；duplicate 时为本地已有的别名
+    Action string
+}
+
+// RestoreBackup 把备份中的记录用本地 master key 加密保存
+// 本地已有相同指纹的密钥一律视为重复并跳过，避免同一密钥出现在两个别名下；
+// 别名冲突按 policy 处理。dryRun 为 true 时只计算结果不写入
+func RestoreBackup(b Backend, bk *Backup, masterKey []byte, policy string, dryRun bool) ([]RestoreResult, error) {
+    switch policy {
+    case ConflictSkip, ConflictRename, ConflictOverwrite:
+    default:
+        return nil, fmt.Errorf("unsupported conflict policy %q (supported: skip, rename, overwrite)", policy)
+    }
+    files, err := b.List()
+    if err != nil {
+        return nil, err
+    }
+    byAlias := make(map[string]string, len(files)) // alias -> fingerprint
+    byFP := make(map[string]string, len(files))    // fingerprint -> alias
+    for _, ef := range files {
+        byAlias[ef.Alias] = ef.Fingerprint
+        byFP[ef.Fingerprint] = ef.Alias
+    }
+
+    var results []RestoreResult
+    for _, rec := range bk.Records {
+        res := RestoreResult{Alias: rec.Alias, Target: rec.Alias, Action: RestoreAdded}
+        if alias, ok := byFP[rec.Fingerprint]; ok {
+            res.Target, res.Action = alias, RestoreDuplicate
+            results = append(results, res)
+            continue
+        }
+        if _, ok := byAlias[rec.Alias]; ok {
+            switch policy {
+            case ConflictSkip:
+                res.Action = RestoreSkipped
+                results = append(results, res)
+                continue
+            case ConflictRename:
+                res.Target, res.Action = freeAlias(byAlias, rec.Alias+"-restored"), RestoreRenamed
+            case ConflictOverwrite:
+                res.Action = RestoreReplaced
+                delete(byFP, byAlias[rec.Alias])
+            }
+        }
+        if !dryRun {
+            r := *rec
+            r.Alias = res.Target
+            if err := SaveRecord(b, &r, masterKey); err != nil {
+                return results, fmt.Errorf("save %s: %w", res.Target, err)
+            }
+        }
+        byAlias[res.Target] = rec.Fingerprint
+        byFP[rec.Fingerprint] = res.Target
+        results = append(results, res)
+    }
+    return results, nil
+}
+
+// freeAlias 返回 base、base-2、base-3 ... 中第一个未被占用的别名
+func freeAlias(taken map[string]string, base string) string {
+    alias := base
+    for i := 2; ; i++ {
+        if _, ok := taken[alias]; !ok {
+            return alias
+        }
+        alias = fmt.Sprintf("%s-%d", base, i)
+    }
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "golang.org/x/crypto/ssh"
+)
+
+// ScanEntry 目录中的一个记录文件
+// List 会跳过无法解析的文件，Scan 把它们连同错误一起返回，供诊断使用
+type ScanEntry struct {
+    Name   string // 文件名去掉 .enc 后的部分
+    Path   string
+    Record *EncryptedFile // 解析失败时为 nil
+    Err    error
+}
+
+// Scan 读取目录中的所有 .enc 文件，包括无法解析的文件
+func (b *DirBackend) Scan() ([]ScanEntry, error) {
+    entries, err := os.ReadDir(b.Dir)
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+    var out []ScanEntry
+    for _, e := range entries {
+        if e.IsDir() || !strings.HasSuffix(e.Name(), ".enc") {
+            continue
+        }
+        se := ScanEntry{Name: strings.TrimSuffix(e.Name(), ".enc"), Path: filepath.Join(b.Dir, e.Name())}
+        data, err := os.ReadFile(se.Path)
+        if err != nil {
+            se.Err = err
+        } else {
+            var ef EncryptedFile
+            if err := json.Unmarshal(data, &ef); err != nil {
+                se.Err = err
+            } else {
+                se.Record = &ef
+            }
+        }
+        out = append(out, se)
+    }
+    return out, nil
+}
+
+// CheckRecord 不解密地检查记录结构：版本、密钥类型、编码字段以及公钥与指纹是否一致
+// 返回发现的全部问题；v1 记录本身不算问题，由调用方提示迁移
+func CheckRecord(ef *EncryptedFile) []error {
+    var problems []error
+    if ef.Version != VersionV1 && ef.Version != VersionV2 {
+        problems = append(problems, fmt.Errorf("unsupported record version: %q", ef.Version))
+    }
+    if validateAlias(ef.Alias) != nil {
+        problems = append(problems, fmt.Errorf("invalid alias: %q", ef.Alias))
+    }
+    if ef.KeyType != "PKCS8" {
+        problems = append(problems, fmt.Errorf("unsupported key type: %q", ef.KeyType))
+    }
+    for _, f := range []struct{ name, value string }{
+        {"hkdf_salt", ef.HKDFSalt}, {"nonce", ef.Nonce}, {"ciphertext", ef.Ciphertext},
+    } {
+        if b, err := base64.StdEncoding.DecodeString(f.value); err != nil || len(b) == 0 {
+            problems = append(problems, fmt.Errorf("%s is missing or not valid base64", f.name))
+        }
+    }
+    if ef.PubKey == "" {
+        problems = append(problems, errors.New("public key is missing; the agent cannot offer this key"))
+    } else if raw, err := base64.StdEncoding.DecodeString(ef.PubKey); err != nil {
+        problems = append(problems, fmt.Errorf("public key is not valid base64: %w", err))
+    } else if pub, err := ssh.ParsePublicKey(raw); err != nil {
+        problems = append(problems, fmt.Errorf("public key cannot be parsed: %w", err))
+    } else if ssh.FingerprintSHA256(pub) != ef.Fingerprint {
+        problems = append(problems, errors.New("fingerprint does not match public key"))
+    }
+    return problems
+}
+
+// VerifyRecord 解密记录并确认私钥与记录中的指纹和公钥一致
+func VerifyRecord(ef *EncryptedFile, masterKey []byte) error {
+    _, err := decryptRecord(ef, ef.Alias, masterKey)
+    return err
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+    "crypto/ecdsa"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "strings"
+
+    "golang.org/x/crypto/ssh"
+)
+
+// 私钥导出格式
+const (
+    ExportOpenSSH = "openssh" // OpenSSH 私钥格式，加密使用 bcrypt-pbkdf + aes256-ctr
+    ExportPKCS8   = "pkcs8"   // PKCS#8 PEM（不支持加密）
+    ExportPEM     = "pem"     // 传统 PEM（RSA 为 PKCS#1，ECDSA 为 SEC1），仅 RSA/ECDSA，不支持加密
+)
+
+// 公钥输出格式
+const (
+    PubAuthorizedKeys    = "authorized_keys"
+    PubPEM               = "pem"
+    PubFingerprintMD5    = "fingerprint-md5"
+    PubFingerprintSHA256 = "fingerprint-sha256"
+)
+
+// ExportPrivateKey 按指定格式导出私钥
+func ExportPrivateKey(rec *Record, format string, passphrase string) ([]byte, error) {
+    switch format {
+    case ExportOpenSSH:
+        return ExportOpenSSHPEM(rec, passphrase)
+    case ExportPKCS8:
+        return ExportPKCS8PEM(rec, passphrase)
+    case ExportPEM:
+        return ExportTraditionalPEM(rec, passphrase)
+    default:
+        return nil, fmt.Errorf("unsupported export format %q (supported: openssh, pkcs8, pem)", format)
+    }
+}
+
+// ExportOpenSSHPEM 导出 OpenSSH 格式私钥，所有密钥类型都可被 ssh 直接读取
+func ExportOpenSSHPEM(rec *Record, passphrase string) ([]byte, error) {
+    pk, err := x509.ParsePKCS8PrivateKey(rec.PKCS8DER)
+    if err != nil {
+        return nil, err
+    }
+    var block *pem.Block
+    if passphrase != "" {
+        block, err = ssh.MarshalPrivateKeyWithPassphrase(pk, rec.Comment, []byte(passphrase))
+    } else {
+        block, err = ssh.MarshalPrivateKey(pk, rec.Comment)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return pem.EncodeToMemory(block), nil
+}
+
+// ExportPKCS8PEM 导出未加密的 PKCS#8 PEM
+// 旧版 PEM 加密（x509.EncryptPEMBlock）不安全且 OpenSSH 不支持 Ed25519，需要加密时请使用 OpenSSH 格式
+func ExportPKCS8PEM(rec *Record, passphrase string) ([]byte, error) {
+    if passphrase != "" {
+        return nil, errors.New("pkcs8 export does not support a passphrase; use --format openssh")
+    }
+    return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rec.PKCS8DER}), nil
+}
+
+// ExportTraditionalPEM 导出未加密的传统 PEM（RSA PRIVATE KEY / EC PRIVATE KEY），供只接受旧格式的工具使用
+// 传统 PEM 加密（x509.EncryptPEMBlock）不安全，需要加密时请使用 OpenSSH 格式
+func ExportTraditionalPEM(rec *Record, passphrase string) ([]byte, error) {
+    if passphrase != "" {
+        return nil, errors.New("pem export does not support a passphrase; use --format openssh")
+    }
+    pk, err := x509.ParsePKCS8PrivateKey(rec.PKCS8DER)
+    if err != nil {
+        return nil, err
+    }
+    var block *pem.Block
+    switch k := pk.(type) {
+    case *rsa.PrivateKey:
+        block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+    case *ecdsa.PrivateKey:
+        der, err := x509.MarshalECPrivateKey(k)
+        if err != nil {
+            return nil, err
+        }
+        block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+    default:
+        return nil, errors.New("pem format only supports RSA and ECDSA keys; use --format openssh")
+    }
+    return pem.EncodeToMemory(block), nil
+}
+
+// PublicKey 从记录元数据解析公钥，不需要 master key
+// 注意：未解密时无法验证元数据是否被篡改，解密时会校验（见 checkPublicKey）
+func (ef *EncryptedFile) PublicKey() (ssh.PublicKey, error) {
+    if ef.PubKey == "" {
+        return nil, errors.New("record has no public key")
+    }
+    raw, err := base64.StdEncoding.DecodeString(ef.PubKey)
+    if err != nil {
+        return nil, err
+    }
+    return ssh.ParsePublicKey(raw)
+}
+
+// FormatPublicKey 按指定格式输出公钥
+func FormatPublicKey(pub ssh.PublicKey, format string, comment string) (string, error) {
+    switch format {
+    case PubAuthorizedKeys:
+        return AuthorizedKey(pub, comment), nil
+    case PubPEM:
+        cpk, ok := pub.(ssh.CryptoPublicKey)
+        if !ok {
+            return "", errors.New("public key type cannot be converted to PEM")
+        }
+        der, err := x509.MarshalPKIXPublicKey(cpk.CryptoPublicKey())
+        if err != nil {
+            return "", err
+        }
+        return strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))), nil
+    case PubFingerprintMD5:
+        return "MD5:" + ssh.FingerprintLegacyMD5(pub), nil
+    case PubFingerprintSHA256:
+        return ssh.FingerprintSHA256(pub), nil
+    default:
+        return "", fmt.Errorf("unsupported public key format %q (supported: authorized_keys, pem, fingerprint-md5, fingerprint-sha256)", format)
+    }
+}
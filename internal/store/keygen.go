@@ -0,0 +1,71 @@
+package store
+
+import (
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/x509"
+    "fmt"
+    "strings"
+
+    "golang.org/x/crypto/ssh"
+)
+
+// 支持生成的密钥类型
+const (
+    KeyTypeEd25519   = "ed25519"
+    KeyTypeECDSAP256 = "ecdsa-p256"
+    KeyTypeECDSAP384 = "ecdsa-p384"
+    KeyTypeRSA4096   = "rsa-4096"
+)
+
+// KeyTypes 返回支持生成的密钥类型
+func KeyTypes() []string {
+    return []string{KeyTypeEd25519, KeyTypeECDSAP256, KeyTypeECDSAP384, KeyTypeRSA4096}
+}
+
+// GenerateRecord 在内存中生成新私钥并创建记录，私钥明文不会写入磁盘
+func GenerateRecord(alias string, keyType string, comment string) (*Record, error) {
+    var k interface{}
+    var err error
+    switch keyType {
+    case KeyTypeEd25519:
+        _, k, err = ed25519.GenerateKey(rand.Reader)
+    case KeyTypeECDSAP256:
+        k, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    case KeyTypeECDSAP384, "p384":
+        k, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+    case KeyTypeRSA4096:
+        k, err = rsa.GenerateKey(rand.Reader, 4096)
+    default:
+        return nil, fmt.Errorf("unsupported key type %q (supported: %s)", keyType, strings.Join(KeyTypes(), ", "))
+    }
+    if err != nil {
+        return nil, err
+    }
+    return newRecordFromKey(alias, k, comment)
+}
+
+// PublicKey 返回记录的 SSH 公钥
+func (r *Record) PublicKey() (ssh.PublicKey, error) {
+    pk, err := x509.ParsePKCS8PrivateKey(r.PKCS8DER)
+    if err != nil {
+        return nil, err
+    }
+    signer, err := ssh.NewSignerFromKey(pk)
+    if err != nil {
+        return nil, err
+    }
+    return signer.PublicKey(), nil
+}
+
+// AuthorizedKey 返回 authorized_keys 格式的公钥行（含注释）
+func AuthorizedKey(pub ssh.PublicKey, comment string) string {
+    line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+    if comment != "" {
+        line += " " + comment
+    }
+    return line
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+    "fmt"
+    "sync"
+)
+
+// MemoryBackend 保存在内存中的后端，用于测试或临时操作
+type MemoryBackend struct {
+    mu      sync.Mutex
+    records map[string]EncryptedFile
+}
+
+// NewMemoryBackend 创建内存后端
+func NewMemoryBackend() *MemoryBackend {
+    return &MemoryBackend{records: make(map[string]EncryptedFile)}
+}
+
+func (b *MemoryBackend) List() ([]EncryptedFile, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    out := make([]EncryptedFile, 0, len(b.records))
+    for _, ef := range b.records {
+        out = append(out, ef)
+    }
+    sortFiles(out)
+    return out, nil
+}
+
+func (b *MemoryBackend) Get(alias string) (*EncryptedFile, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    ef, ok := b.records[alias]
+    if !ok {
+        return nil, fmt.Errorf("%w: %s", ErrNotFound, alias)
+    }
+    return &ef, nil
+}
+
+func (b *MemoryBackend) Put(ef *EncryptedFile) error {
+    if err := validateAlias(ef.Alias); err != nil {
+        return err
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.records[ef.Alias] = *ef
+    return nil
+}
+
+func (b *MemoryBackend) Delete(alias string) error {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if _, ok := b.records[alias]; !ok {
+        return fmt.Errorf("%w: %s", ErrNotFound, alias)
+    }
+    delete(b.records, alias)
+    return nil
+}
+
+func (b *MemoryBackend) Rename(oldAlias string, ef *EncryptedFile) error {
+    if err := validateAlias(ef.Alias); err != nil {
+        return err
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if _, ok := b.records[oldAlias]; !ok {
+        return fmt.Errorf("%w: %s", ErrNotFound, oldAlias)
+    }
+    if _, ok := b.records[ef.Alias]; ok && ef.Alias != oldAlias {
+        return fmt.Errorf("%w: %s", ErrExists, ef.Alias)
+    }
+    delete(b.records, oldAlias)
+    b.records[ef.Alias] = *ef
+    return nil
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "path"
+    "sort"
+    "strings"
+    "time"
+
+    "fssh/internal/crypt"
+)
+
+// ExpiryLayout 过期日期格式
+const ExpiryLayout = "2006-01-02"
+
+// KeyMeta 密钥的组织性元数据
+// 不参与 AEAD 认证（修改时无需解密私钥），由 master key 派生的 MAC 单独认证，见 VerifyMeta
+type KeyMeta struct {
+    Tags    []string `json:"tags,omitempty"`
+    Notes   string   `json:"notes,omitempty"`
+    Owner   string   `json:"owner,omitempty"`
+    Expires string   `json:"expires,omitempty"` // YYYY-MM-DD，当天零点（本地时间）起视为过期
+    Hosts   []string `json:"hosts,omitempty"`   // 预期使用的主机，支持 * 和 ? 通配
+}
+
+// IsZero 是否没有任何元数据
+func (m *KeyMeta) IsZero() bool {
+    return m == nil || (len(m.Tags) == 0 && m.Notes == "" && m.Owner == "" && m.Expires == "" && len(m.Hosts) == 0)
+}
+
+// HasTag 是否包含标签
+func (m *KeyMeta) HasTag(tag string) bool {
+    if m == nil {
+        return false
+    }
+    for _, t := range m.Tags {
+        if t == tag {
+            return true
+        }
+    }
+    return false
+}
+
+// AddTags 添加标签，去重并排序
+func (m *KeyMeta) AddTags(tags ...string) error {
+    for _, t := range tags {
+        if t == "" || strings.ContainsAny(t, ", \t") {
+            return fmt.Errorf("invalid tag: %q", t)
+        }
+        if !m.HasTag(t) {
+            m.Tags = append(m.Tags, t)
+        }
+    }
+    sort.Strings(m.Tags)
+    return nil
+}
+
+// RemoveTags 删除标签
+func (m *KeyMeta) RemoveTags(tags ...string) {
+    out := m.Tags[:0]
+    for _, t := range m.Tags {
+        keep := true
+        for _, r := range tags {
+            if t == r {
+                keep = false
+                break
+            }
+        }
+        if keep {
+            out = append(out, t)
+        }
+    }
+    m.Tags = out
+}
+
+// ExpiresAt 返回过期时间，未设置时 ok 为 false
+func (m *KeyMeta) ExpiresAt() (t time.Time, ok bool) {
+    if m == nil || m.Expires == "" {
+        return time.Time{}, false
+    }
+    t, err := time.ParseInLocation(ExpiryLayout, m.Expires, time.Local)
+    if err != nil {
+        return time.Time{}, false
+    }
+    return t, true
+}
+
+// Expired 在 now 时是否已过期
+func (m *KeyMeta) Expired(now time.Time) bool {
+    t, ok := m.ExpiresAt()
+    return ok && !now.Before(t)
+}
+
+// MatchesHost 密钥是否适用于主机；未设置 Hosts 的密钥适用于所有主机
+func (m *KeyMeta) MatchesHost(host string) bool {
+    if m == nil || len(m.Hosts) == 0 {
+        return true
+    }
+    host = strings.ToLower(host)
+    for _, pattern := range m.Hosts {
+        if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// ParseExpiry 校验过期日期，"" 或 "never" 表示清除
+func ParseExpiry(s string) (string, error) {
+    if s == "" || s == "never" {
+        return "", nil
+    }
+    if _, err := time.Parse(ExpiryLayout, s); err != nil {
+        return "", fmt.Errorf("invalid expiry %q (expected YYYY-MM-DD or never)", s)
+    }
+    return s, nil
+}
+
+// ErrMetaUnauthenticated 记录没有元数据 MAC（旧版本写入），元数据不可信
+var ErrMetaUnauthenticated = errors.New("key metadata is not authenticated; run fssh store migrate")
+
+// ErrMetaModified 元数据 MAC 不匹配，元数据在 fssh 之外被修改
+var ErrMetaModified = errors.New("key metadata was modified outside fssh")
+
+// metaMAC 计算元数据 MAC，密钥为 HKDF(master key, "fssh-meta-v1")
+// 同时认证别名和指纹，元数据不能搬到另一条记录上；清除元数据同样会改变 MAC
+func metaMAC(ef *EncryptedFile, masterKey []byte) (string, error) {
+    meta := ef.Meta
+    if meta.IsZero() {
+        meta = nil
+    }
+    data, err := json.Marshal(struct {
+        Alias       string   `json:"alias"`
+        Fingerprint string   `json:"fingerprint"`
+        Meta        *KeyMeta `json:"meta"`
+    }{ef.Alias, ef.Fingerprint, meta})
+    if err != nil {
+        return "", err
+    }
+    m := hmac.New(sha256.New, crypt.HKDF(masterKey, nil, []byte("fssh-meta-v1"), 32))
+    m.Write(data)
+    return base64.StdEncoding.EncodeToString(m.Sum(nil)), nil
+}
+
+// sealMeta 用 master key 为记录的元数据写入 MAC
+func sealMeta(ef *EncryptedFile, masterKey []byte) error {
+    mac, err := metaMAC(ef, masterKey)
+    if err != nil {
+        return err
+    }
+    ef.MetaMAC = mac
+    return nil
+}
+
+// VerifyMeta 校验记录元数据的 MAC
+// 过期时间、标签和预期主机用于决定 agent 是否提供密钥，使用前必须校验；没有 MAC 时返回 ErrMetaUnauthenticated
+func VerifyMeta(ef *EncryptedFile, masterKey []byte) error {
+    if ef.MetaMAC == "" {
+        return ErrMetaUnauthenticated
+    }
+    want, err := metaMAC(ef, masterKey)
+    if err != nil {
+        return err
+    }
+    if !hmac.Equal([]byte(want), []byte(ef.MetaMAC)) {
+        return ErrMetaModified
+    }
+    return nil
+}
+
+// UpdateMeta 修改记录的元数据，不解密私钥，用 master key 重新计算元数据 MAC
+// 已有 MAC 不匹配时拒绝修改，避免为被篡改的元数据补上 MAC
+func UpdateMeta(b Backend, alias string, masterKey []byte, fn func(m *KeyMeta) error) (*KeyMeta, error) {
+    ef, err := b.Get(alias)
+    if err != nil {
+        return nil, err
+    }
+    if err := VerifyMeta(ef, masterKey); err != nil && !errors.Is(err, ErrMetaUnauthenticated) {
+        return nil, fmt.Errorf("%s: %w", alias, err)
+    }
+    m := &KeyMeta{}
+    if ef.Meta != nil {
+        *m = *ef.Meta
+        m.Tags = append([]string(nil), ef.Meta.Tags...)
+        m.Hosts = append([]string(nil), ef.Meta.Hosts...)
+    }
+    if err := fn(m); err != nil {
+        return nil, err
+    }
+    if m.IsZero() {
+        ef.Meta = nil
+    } else {
+        ef.Meta = m
+    }
+    if err := sealMeta(ef, masterKey); err != nil {
+        return nil, err
+    }
+    if err := b.Put(ef); err != nil {
+        return nil, err
+    }
+    return ef.Meta, nil
+}
+
+// Filter 按元数据筛选记录
+type Filter struct {
+    Tags           []string // 必须包含全部标签
+    Owner          string
+    Host           string // 只保留适用于该主机的密钥
+    IncludeExpired bool
+}
+
+// Match 记录是否满足筛选条件
+func (f Filter) Match(ef *EncryptedFile, now time.Time) bool {
+    for _, t := range f.Tags {
+        if !ef.Meta.HasTag(t) {
+            return false
+        }
+    }
+    if f.Owner != "" && (ef.Meta == nil || ef.Meta.Owner != f.Owner) {
+        return false
+    }
+    if f.Host != "" && !ef.Meta.MatchesHost(f.Host) {
+        return false
+    }
+    if !f.IncludeExpired && ef.Meta.Expired(now) {
+        return false
+    }
+    return true
+}
+
+// Apply 返回满足筛选条件的记录
+func (f Filter) Apply(files []EncryptedFile, now time.Time) []EncryptedFile {
+    out := make([]EncryptedFile, 0, len(files))
+    for i := range files {
+        if f.Match(&files[i], now) {
+            out = append(out, files[i])
+        }
+    }
+    return out
+}
+
+// ParseTags 解析逗号分隔的标签列表
+func ParseTags(s string) []string {
+    var tags []string
+    for _, t := range strings.Split(s, ",") {
+        if t = strings.TrimSpace(t); t != "" {
+            tags = append(tags, t)
+        }
+    }
+    return tags
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestMetaMAC(t *testing.T) {
+    mk := testMasterKey()
+    b := NewMemoryBackend()
+    rec := testRecord(t, "a")
+    rec.Meta = &KeyMeta{Tags: []string{"prod"}, Expires: "2020-01-01", Hosts: []string{"*.prod"}}
+    if err := SaveRecord(b, rec, mk); err != nil {
+        t.Fatal(err)
+    }
+    ef, err := b.Get("a")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := VerifyMeta(ef, mk); err != nil {
+        t.Fatalf("fresh record: %v", err)
+    }
+    if err := VerifyMeta(ef, []byte("another master key, 32 bytes...")); !errors.Is(err, ErrMetaModified) {
+        t.Fatalf("wrong master key: got %v", err)
+    }
+
+    // 在 fssh 之外修改元数据：清除过期时间、改标签、放宽主机、整体删除、搬到别的别名
+    for name, edit := range map[string]func(ef *EncryptedFile){
+        "clear expiry": func(ef *EncryptedFile) { m := *ef.Meta; m.Expires = ""; ef.Meta = &m },
+        "retag":        func(ef *EncryptedFile) { m := *ef.Meta; m.Tags = []string{"dev"}; ef.Meta = &m },
+        "widen hosts":  func(ef *EncryptedFile) { m := *ef.Meta; m.Hosts = []string{"*"}; ef.Meta = &m },
+        "drop meta":    func(ef *EncryptedFile) { ef.Meta = nil },
+        "move":         func(ef *EncryptedFile) { ef.Alias = "b" },
+    } {
+        tampered := *ef
+        edit(&tampered)
+        if err := VerifyMeta(&tampered, mk); !errors.Is(err, ErrMetaModified) {
+            t.Errorf("%s: got %v, want ErrMetaModified", name, err)
+        }
+        if name == "move" {
+            continue
+        }
+        if err := b.Put(&tampered); err != nil {
+            t.Fatal(err)
+        }
+        if _, err := LoadRecord(b, "a", mk); !errors.Is(err, ErrMetaModified) {
+            t.Errorf("%s: LoadRecord got %v, want ErrMetaModified", name, err)
+        }
+        if _, err := UpdateMeta(b, "a", mk, func(m *KeyMeta) error { return nil }); !errors.Is(err, ErrMetaModified) {
+            t.Errorf("%s: UpdateMeta got %v, want ErrMetaModified", name, err)
+        }
+    }
+
+    // 删除 MAC：不可信，但仍能解密以便迁移
+    stripped := *ef
+    stripped.MetaMAC = ""
+    if err := VerifyMeta(&stripped, mk); !errors.Is(err, ErrMetaUnauthenticated) {
+        t.Fatalf("no MAC: got %v", err)
+    }
+    if err := b.Put(&stripped); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := LoadRecord(b, "a", mk); err != nil {
+        t.Fatalf("LoadRecord without MAC: %v", err)
+    }
+    if !NeedsMigration(&stripped) {
+        t.Fatal("record without MAC does not need migration")
+    }
+    if migrated, err := MigrateRecord(b, "a", mk); err != nil || !migrated {
+        t.Fatalf("MigrateRecord() = %v, %v", migrated, err)
+    }
+    ef, _ = b.Get("a")
+    if err := VerifyMeta(ef, mk); err != nil || ef.Meta.Expires != "2020-01-01" {
+        t.Fatalf("after migrate: %v, %+v", err, ef.Meta)
+    }
+    if migrated, err := MigrateRecord(b, "a", mk); err != nil || migrated {
+        t.Fatalf("second MigrateRecord() = %v, %v", migrated, err)
+    }
+
+    // UpdateMeta 重新计算 MAC，清空元数据后仍有 MAC
+    if _, err := UpdateMeta(b, "a", mk, func(m *KeyMeta) error { *m = KeyMeta{}; return nil }); err != nil {
+        t.Fatal(err)
+    }
+    ef, _ = b.Get("a")
+    if ef.Meta != nil || VerifyMeta(ef, mk) != nil {
+        t.Fatalf("after clearing: meta=%+v, verify=%v", ef.Meta, VerifyMeta(ef, mk))
+    }
+}
@@ -0,0 +1,387 @@
+package store
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/des"
+    "crypto/hmac"
+    "crypto/sha1"
+    "crypto/sha256"
+    "crypto/sha512"
+    "crypto/x509"
+    "encoding/asn1"
+    "errors"
+    "fmt"
+    "hash"
+    "unicode/utf16"
+
+    "golang.org/x/crypto/pbkdf2"
+    "golang.org/x/crypto/ssh"
+)
+
+// PKCS#12 相关的 OID
+var (
+    oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+    oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+    oidKeyBag                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+    oidPKCS8ShroudedKeyBag      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+    oidPBEWithSHAAnd3KeyDESCBC  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+    oidPBES2                    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+    oidPBKDF2                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+    oidHMACWithSHA1             = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+    oidHMACWithSHA256           = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+    oidHMACWithSHA384           = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+    oidHMACWithSHA512           = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+    oidAES128CBC                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+    oidAES192CBC                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+    oidAES256CBC                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+    oidDESEDE3CBC               = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+    oidSHA1                     = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+    oidSHA256                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+    oidSHA384                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+    oidSHA512                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+type pfxPDU struct {
+    Version  int
+    AuthSafe contentInfo
+    MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+    ContentType asn1.ObjectIdentifier
+    Content     asn1.RawValue `asn1:"optional"` // [0] EXPLICIT，Bytes 为内部元素的完整编码
+}
+
+type macData struct {
+    Mac        digestInfo
+    MacSalt    []byte
+    Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+    Algorithm algorithmIdentifier
+    Digest    []byte
+}
+
+type algorithmIdentifier struct {
+    Algorithm  asn1.ObjectIdentifier
+    Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type encryptedData struct {
+    Version              int
+    EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+    ContentType                asn1.ObjectIdentifier
+    ContentEncryptionAlgorithm algorithmIdentifier
+    EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+    ID         asn1.ObjectIdentifier
+    Value      asn1.RawValue // [0] EXPLICIT，Bytes 为内部元素的完整编码
+    Attributes asn1.RawValue `asn1:"optional"`
+}
+
+type encryptedPrivateKeyInfo struct {
+    Algorithm     algorithmIdentifier
+    EncryptedData []byte
+}
+
+type pbeParams struct {
+    Salt       []byte
+    Iterations int
+}
+
+type pbes2Params struct {
+    KeyDerivationFunc algorithmIdentifier
+    EncryptionScheme  algorithmIdentifier
+}
+
+type pbkdf2Params struct {
+    Salt       []byte
+    Iterations int
+    KeyLength  int                 `asn1:"optional"`
+    PRF        algorithmIdentifier `asn1:"optional"`
+}
+
+// isPKCS12 判断数据是否为 DER 编码的 PKCS#12（PFX v3）
+func isPKCS12(data []byte) bool {
+    var pfx pfxPDU
+    rest, err := asn1.Unmarshal(data, &pfx)
+    return err == nil && len(rest) == 0 && pfx.Version == 3 && pfx.AuthSafe.ContentType.Equal(oidDataContentType)
+}
+
+// parsePKCS12 从 PKCS#12 文件中取出唯一的私钥，忽略证书
+// 支持 PBES2（PBKDF2 + AES/3DES）和传统的 pbeWithSHAAnd3-KeyTripleDES-CBC，MAC 支持 SHA-1 和 SHA-2
+func parsePKCS12(data []byte, passphrase string) (interface{}, error) {
+    var pfx pfxPDU
+    if rest, err := asn1.Unmarshal(data, &pfx); err != nil || len(rest) != 0 {
+        return nil, errors.New("pkcs12: not a DER-encoded PKCS#12 file (re-export it with openssl pkcs12)")
+    }
+    if pfx.Version != 3 || !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+        return nil, errors.New("pkcs12: only password-integrity PFX v3 files are supported")
+    }
+    var authSafe []byte
+    if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+        return nil, fmt.Errorf("pkcs12: %w", err)
+    }
+    if pfx.MacData.Mac.Algorithm.Algorithm != nil {
+        if err := verifyPKCS12MAC(&pfx.MacData, authSafe, passphrase); err != nil {
+            if passphrase == "" {
+                return nil, &ssh.PassphraseMissingError{}
+            }
+            return nil, err
+        }
+    }
+
+    var contents []contentInfo
+    if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+        return nil, fmt.Errorf("pkcs12: %w", err)
+    }
+    var keys []interface{}
+    for _, ci := range contents {
+        var bags []byte
+        switch {
+        case ci.ContentType.Equal(oidDataContentType):
+            if _, err := asn1.Unmarshal(ci.Content.Bytes, &bags); err != nil {
+                return nil, fmt.Errorf("pkcs12: %w", err)
+            }
+        case ci.ContentType.Equal(oidEncryptedDataContentType):
+            var ed encryptedData
+            if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+                return nil, fmt.Errorf("pkcs12: %w", err)
+            }
+            plain, err := pkcs12Decrypt(ed.EncryptedContentInfo.ContentEncryptionAlgorithm, ed.EncryptedContentInfo.EncryptedContent, passphrase)
+            if errors.Is(err, errUnsupportedPBE) {
+                // 传统工具用 RC2-40 加密证书部分，私钥不在其中，跳过
+                continue
+            }
+            if err != nil {
+                return nil, err
+            }
+            bags = plain
+        default:
+            continue
+        }
+        var sb []safeBag
+        if _, err := asn1.Unmarshal(bags, &sb); err != nil {
+            return nil, fmt.Errorf("pkcs12: %w", err)
+        }
+        for _, bag := range sb {
+            var der []byte
+            switch {
+            case bag.ID.Equal(oidKeyBag):
+                der = bag.Value.Bytes
+            case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+                var epki encryptedPrivateKeyInfo
+                if _, err := asn1.Unmarshal(bag.Value.Bytes, &epki); err != nil {
+                    return nil, fmt.Errorf("pkcs12: %w", err)
+                }
+                plain, err := pkcs12Decrypt(epki.Algorithm, epki.EncryptedData, passphrase)
+                if err != nil {
+                    return nil, err
+                }
+                der = plain
+            default:
+                continue
+            }
+            k, err := x509.ParsePKCS8PrivateKey(der)
+            if err != nil {
+                return nil, fmt.Errorf("pkcs12: %w", err)
+            }
+            keys = append(keys, k)
+        }
+    }
+    switch len(keys) {
+    case 0:
+        return nil, errors.New("pkcs12: no private key found")
+    case 1:
+        return keys[0], nil
+    }
+    return nil, fmt.Errorf("pkcs12: file contains %d private keys, expected one", len(keys))
+}
+
+var errUnsupportedPBE = errors.New("pkcs12: unsupported encryption algorithm")
+
+// pkcs12Decrypt 按算法标识解密，返回去掉填充的明文
+func pkcs12Decrypt(alg algorithmIdentifier, ct []byte, passphrase string) ([]byte, error) {
+    var block cipher.Block
+    var iv []byte
+    switch {
+    case alg.Algorithm.Equal(oidPBEWithSHAAnd3KeyDESCBC):
+        var p pbeParams
+        if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &p); err != nil {
+            return nil, fmt.Errorf("pkcs12: %w", err)
+        }
+        pass := bmpString(passphrase)
+        key := pkcs12KDF(sha1.New, 64, pass, p.Salt, p.Iterations, 1, 24)
+        iv = pkcs12KDF(sha1.New, 64, pass, p.Salt, p.Iterations, 2, 8)
+        b, err := des.NewTripleDESCipher(key)
+        if err != nil {
+            return nil, err
+        }
+        block = b
+    case alg.Algorithm.Equal(oidPBES2):
+        var p pbes2Params
+        if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &p); err != nil {
+            return nil, fmt.Errorf("pkcs12: %w", err)
+        }
+        if !p.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+            return nil, errUnsupportedPBE
+        }
+        var kdf pbkdf2Params
+        if _, err := asn1.Unmarshal(p.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+            return nil, fmt.Errorf("pkcs12: %w", err)
+        }
+        prf := sha1.New
+        switch {
+        case kdf.PRF.Algorithm == nil || kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+        case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+            prf = sha256.New
+        case kdf.PRF.Algorithm.Equal(oidHMACWithSHA384):
+            prf = sha512.New384
+        case kdf.PRF.Algorithm.Equal(oidHMACWithSHA512):
+            prf = sha512.New
+        default:
+            return nil, errUnsupportedPBE
+        }
+        var keyLen int
+        newCipher := aes.NewCipher
+        switch enc := p.EncryptionScheme.Algorithm; {
+        case enc.Equal(oidAES128CBC):
+            keyLen = 16
+        case enc.Equal(oidAES192CBC):
+            keyLen = 24
+        case enc.Equal(oidAES256CBC):
+            keyLen = 32
+        case enc.Equal(oidDESEDE3CBC):
+            keyLen, newCipher = 24, des.NewTripleDESCipher
+        default:
+            return nil, errUnsupportedPBE
+        }
+        if _, err := asn1.Unmarshal(p.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+            return nil, fmt.Errorf("pkcs12: %w", err)
+        }
+        b, err := newCipher(pbkdf2.Key([]byte(passphrase), kdf.Salt, kdf.Iterations, keyLen, prf))
+        if err != nil {
+            return nil, err
+        }
+        block = b
+    default:
+        return nil, errUnsupportedPBE
+    }
+
+    bs := block.BlockSize()
+    if len(iv) != bs || len(ct) == 0 || len(ct)%bs != 0 {
+        return nil, errors.New("pkcs12: invalid ciphertext")
+    }
+    plain := make([]byte, len(ct))
+    cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, ct)
+    pad := int(plain[len(plain)-1])
+    if pad == 0 || pad > bs {
+        return nil, x509.IncorrectPasswordError
+    }
+    for _, b := range plain[len(plain)-pad:] {
+        if int(b) != pad {
+            return nil, x509.IncorrectPasswordError
+        }
+    }
+    return plain[:len(plain)-pad], nil
+}
+
+// verifyPKCS12MAC 校验完整性 MAC，口令错误时返回 x509.IncorrectPasswordError
+func verifyPKCS12MAC(md *macData, authSafe []byte, passphrase string) error {
+    var newHash func() hash.Hash
+    var v int
+    switch alg := md.Mac.Algorithm.Algorithm; {
+    case alg.Equal(oidSHA1):
+        newHash, v = sha1.New, 64
+    case alg.Equal(oidSHA256):
+        newHash, v = sha256.New, 64
+    case alg.Equal(oidSHA384):
+        newHash, v = sha512.New384, 128
+    case alg.Equal(oidSHA512):
+        newHash, v = sha512.New, 128
+    default:
+        return fmt.Errorf("pkcs12: unsupported MAC algorithm %v", alg)
+    }
+    // 空口令有两种编码习惯：两个零字节的 BMPString，或完全为空
+    candidates := [][]byte{bmpString(passphrase)}
+    if passphrase == "" {
+        candidates = append(candidates, nil)
+    }
+    for _, pass := range candidates {
+        key := pkcs12KDF(newHash, v, pass, md.MacSalt, md.Iterations, 3, newHash().Size())
+        m := hmac.New(newHash, key)
+        m.Write(authSafe)
+        if hmac.Equal(m.Sum(nil), md.Mac.Digest) {
+            return nil
+        }
+    }
+    return x509.IncorrectPasswordError
+}
+
+// bmpString 把口令编码为以两个零字节结尾的 UTF-16BE，PKCS#12 KDF 使用这种编码
+func bmpString(s string) []byte {
+    u := utf16.Encode([]rune(s))
+    out := make([]byte, 0, 2*len(u)+2)
+    for _, r := range u {
+        out = append(out, byte(r>>8), byte(r))
+    }
+    return append(out, 0, 0)
+}
+
+// pkcs12KDF RFC 7292 附录 B.2 的密钥派生函数
+// id 为 1（加密密钥）、2（IV）或 3（MAC 密钥），v 为哈希的分组长度
+func pkcs12KDF(newHash func() hash.Hash, v int, pass, salt []byte, iterations, id, size int) []byte {
+    fill := func(b []byte) []byte {
+        if len(b) == 0 {
+            return nil
+        }
+        out := make([]byte, v*((len(b)+v-1)/v))
+        for i := range out {
+            out[i] = b[i%len(b)]
+        }
+        return out
+    }
+    D := make([]byte, v)
+    for i := range D {
+        D[i] = byte(id)
+    }
+    I := append(fill(salt), fill(pass)...)
+
+    var out []byte
+    for len(out) < size {
+        h := newHash()
+        h.Write(D)
+        h.Write(I)
+        A := h.Sum(nil)
+        for r := 1; r < iterations; r++ {
+            h.Reset()
+            h.Write(A)
+            A = h.Sum(A[:0])
+        }
+        out = append(out, A...)
+        if len(out) >= size {
+            break
+        }
+        B := make([]byte, v)
+        for i := range B {
+            B[i] = A[i%len(A)]
+        }
+        // 每个 v 字节的分组 Ij = (Ij + B + 1) mod 2^(8v)
+        for j := 0; j < len(I); j += v {
+            carry := 1
+            for k := v - 1; k >= 0; k-- {
+                sum := int(I[j+k]) + int(B[k]) + carry
+                I[j+k] = byte(sum)
+                carry = sum >> 8
+            }
+        }
+    }
+    return out[:size]
+}
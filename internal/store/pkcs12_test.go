@@ -0,0 +1,69 @@
+package store
+
+import (
+    "crypto/ecdsa"
+    "crypto/x509"
+    "encoding/pem"
+    "errors"
+    "os"
+    "testing"
+
+    "golang.org/x/crypto/ssh"
+)
+
+// testdata 中的 .p12 文件由 OpenSSL 3.0 生成：
+//   openssl pkcs12 -export -inkey pkcs12-key.pem -in cert.pem -passout pass:test-passphrase -out pkcs12-aes.p12
+//   openssl pkcs12 -export -legacy ... -out pkcs12-legacy.p12
+// 前者为 PBES2/AES-256 + SHA-256 MAC，后者为 3DES 私钥、RC2-40 证书 + SHA-1 MAC
+const pkcs12TestPassphrase = "test-passphrase"
+
+func TestParsePKCS12(t *testing.T) {
+    keyPEM, err := os.ReadFile("testdata/pkcs12-key.pem")
+    if err != nil {
+        t.Fatal(err)
+    }
+    block, _ := pem.Decode(keyPEM)
+    want, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for _, name := range []string{"pkcs12-aes.p12", "pkcs12-legacy.p12"} {
+        t.Run(name, func(t *testing.T) {
+            data, err := os.ReadFile("testdata/" + name)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if DetectKeyFormat(data) != KeyFormatPKCS12 {
+                t.Fatalf("DetectKeyFormat() = %q, want %q", DetectKeyFormat(data), KeyFormatPKCS12)
+            }
+            k, err := ParsePrivateKeyBytes(data, pkcs12TestPassphrase)
+            if err != nil {
+                t.Fatal(err)
+            }
+            got, ok := k.(*ecdsa.PrivateKey)
+            if !ok || !got.Equal(want) {
+                t.Fatalf("got %T, want the key in pkcs12-key.pem", k)
+            }
+
+            var missing *ssh.PassphraseMissingError
+            if _, err := ParsePrivateKeyBytes(data, ""); !errors.As(err, &missing) {
+                t.Fatalf("without passphrase: got %v, want PassphraseMissingError", err)
+            }
+            if _, err := ParsePrivateKeyBytes(data, "wrong"); err == nil {
+                t.Fatal("wrong passphrase accepted")
+            }
+        })
+    }
+}
+
+func TestParsePKCS12Corrupt(t *testing.T) {
+    data, err := os.ReadFile("testdata/pkcs12-aes.p12")
+    if err != nil {
+        t.Fatal(err)
+    }
+    data[len(data)/2] ^= 0xff
+    if _, err := parsePKCS12(data, pkcs12TestPassphrase); err == nil {
+        t.Fatal("corrupted file accepted")
+    }
+}
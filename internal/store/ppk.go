@@ -0,0 +1,274 @@
+package store
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/hmac"
+    "crypto/rsa"
+    "crypto/sha1"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/binary"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash"
+    "math/big"
+    "strconv"
+    "strings"
+
+    "golang.org/x/crypto/argon2"
+    "golang.org/x/crypto/ssh"
+)
+
+// ppkFile PuTTY 私钥文件（PuTTY-User-Key-File-2/3）的字段
+type ppkFile struct {
+    Version    int
+    Algorithm  string
+    Encryption string
+    Comment    string
+    Public     []byte
+    Private    []byte
+    MAC        []byte
+    Headers    map[string]string // Key-Derivation、Argon2-* 等其余字段
+}
+
+// parsePPKFile 解析 PPK 文本结构，不解密
+func parsePPKFile(data []byte) (*ppkFile, error) {
+    f := &ppkFile{Headers: map[string]string{}}
+    sc := bufio.NewScanner(bytes.NewReader(data))
+    readLines := func(n string) ([]byte, error) {
+        count, err := strconv.Atoi(n)
+        if err != nil || count < 0 {
+            return nil, fmt.Errorf("ppk: invalid line count %q", n)
+        }
+        var b strings.Builder
+        for i := 0; i < count; i++ {
+            if !sc.Scan() {
+                return nil, errors.New("ppk: unexpected end of file")
+            }
+            b.WriteString(strings.TrimSpace(sc.Text()))
+        }
+        return base64.StdEncoding.DecodeString(b.String())
+    }
+    first := true
+    for sc.Scan() {
+        line := strings.TrimRight(sc.Text(), "\r")
+        if line == "" {
+            continue
+        }
+        key, value, ok := strings.Cut(line, ": ")
+        if !ok {
+            return nil, fmt.Errorf("ppk: malformed line %q", line)
+        }
+        var err error
+        switch {
+        case first:
+            first = false
+            switch key {
+            case "PuTTY-User-Key-File-2":
+                f.Version = 2
+            case "PuTTY-User-Key-File-3":
+                f.Version = 3
+            default:
+                return nil, fmt.Errorf("ppk: unsupported file version %q (convert it with puttygen)", key)
+            }
+            f.Algorithm = value
+        case key == "Encryption":
+            f.Encryption = value
+        case key == "Comment":
+            f.Comment = value
+        case key == "Public-Lines":
+            f.Public, err = readLines(value)
+        case key == "Private-Lines":
+            f.Private, err = readLines(value)
+        case key == "Private-MAC":
+            f.MAC, err = hex.DecodeString(value)
+        default:
+            f.Headers[key] = value
+        }
+        if err != nil {
+            return nil, fmt.Errorf("ppk: %s: %w", key, err)
+        }
+    }
+    if err := sc.Err(); err != nil {
+        return nil, err
+    }
+    if f.Version == 0 || f.Public == nil || f.Private == nil || f.MAC == nil {
+        return nil, errors.New("ppk: missing required fields")
+    }
+    return f, nil
+}
+
+// ppkMaxArgon2MemoryKiB PPK v3 接受的 Argon2 内存参数上限（1 GiB），puttygen 默认只用 8 MiB
+const ppkMaxArgon2MemoryKiB = 1 << 20
+
+// ppkKeys 派生 PPK 的解密密钥、IV 和 MAC 密钥
+// v2：SHA-1 派生 AES 密钥，IV 为零，MAC 密钥为 SHA-1("putty-private-key-file-mac-key" + 口令)
+// v3：Argon2 输出 80 字节，依次为 AES 密钥、IV 和 MAC 密钥；未加密时 MAC 密钥为空
+func (f *ppkFile) keys(passphrase string) (key, iv, macKey []byte, err error) {
+    encrypted := f.Encryption != "none"
+    if f.Version == 2 {
+        if encrypted {
+            h0 := sha1.Sum(append([]byte{0, 0, 0, 0}, passphrase...))
+            h1 := sha1.Sum(append([]byte{0, 0, 0, 1}, passphrase...))
+            key = append(h0[:], h1[:]...)[:32]
+            iv = make([]byte, aes.BlockSize)
+        } else {
+            passphrase = ""
+        }
+        mk := sha1.Sum([]byte("putty-private-key-file-mac-key" + passphrase))
+        return key, iv, mk[:], nil
+    }
+    if !encrypted {
+        return nil, nil, nil, nil
+    }
+    mem, err1 := strconv.ParseUint(f.Headers["Argon2-Memory"], 10, 32)
+    passes, err2 := strconv.ParseUint(f.Headers["Argon2-Passes"], 10, 32)
+    par, err3 := strconv.ParseUint(f.Headers["Argon2-Parallelism"], 10, 8)
+    salt, err4 := hex.DecodeString(f.Headers["Argon2-Salt"])
+    if err := errors.Join(err1, err2, err3, err4); err != nil {
+        return nil, nil, nil, fmt.Errorf("ppk: invalid Argon2 parameters: %w", err)
+    }
+    // 参数来自文件本身：为 0 时 argon2 会 panic，过大的内存参数会耗尽内存
+    if passes < 1 || par < 1 || mem > ppkMaxArgon2MemoryKiB {
+        return nil, nil, nil, fmt.Errorf("ppk: unsupported Argon2 parameters (passes=%d, parallelism=%d, memory=%d KiB)", passes, par, mem)
+    }
+    var out []byte
+    switch f.Headers["Key-Derivation"] {
+    case "Argon2id":
+        out = argon2.IDKey([]byte(passphrase), salt, uint32(passes), uint32(mem), uint8(par), 80)
+    case "Argon2i":
+        out = argon2.Key([]byte(passphrase), salt, uint32(passes), uint32(mem), uint8(par), 80)
+    default:
+        return nil, nil, nil, fmt.Errorf("ppk: unsupported key derivation %q (re-save it with puttygen using Argon2id)", f.Headers["Key-Derivation"])
+    }
+    return out[:32], out[32:48], out[48:80], nil
+}
+
+// parsePPK 解析 PuTTY PPK v2/v3 私钥，支持未加密和 aes256-cbc 加密
+func parsePPK(data []byte, passphrase string) (interface{}, error) {
+    f, err := parsePPKFile(data)
+    if err != nil {
+        return nil, err
+    }
+    pub, err := ssh.ParsePublicKey(f.Public)
+    if err != nil {
+        return nil, fmt.Errorf("ppk: %w", err)
+    }
+    if pub.Type() != f.Algorithm {
+        return nil, fmt.Errorf("ppk: public key type %s does not match header %s", pub.Type(), f.Algorithm)
+    }
+    switch f.Encryption {
+    case "none":
+    case "aes256-cbc":
+        if passphrase == "" {
+            return nil, &ssh.PassphraseMissingError{PublicKey: pub}
+        }
+    default:
+        return nil, fmt.Errorf("ppk: unsupported encryption %q", f.Encryption)
+    }
+
+    key, iv, macKey, err := f.keys(passphrase)
+    if err != nil {
+        return nil, err
+    }
+    priv := f.Private
+    if key != nil {
+        if len(priv)%aes.BlockSize != 0 {
+            return nil, errors.New("ppk: private blob is not a multiple of the block size")
+        }
+        block, err := aes.NewCipher(key)
+        if err != nil {
+            return nil, err
+        }
+        priv = make([]byte, len(f.Private))
+        cipher.NewCBCDecrypter(block, iv).CryptBlocks(priv, f.Private)
+    }
+
+    newHash := sha256.New
+    if f.Version == 2 {
+        newHash = sha1.New
+    }
+    if !hmac.Equal(ppkMAC(newHash, macKey, f, priv), f.MAC) {
+        if key != nil {
+            return nil, x509.IncorrectPasswordError
+        }
+        return nil, errors.New("ppk: MAC mismatch, the file is corrupt")
+    }
+    return ppkPrivateKey(pub, priv)
+}
+
+// ppkMAC 计算 PPK 的 MAC：算法、加密方式、注释、公钥和解密后的私钥，均以 SSH string 编码
+func ppkMAC(newHash func() hash.Hash, macKey []byte, f *ppkFile, priv []byte) []byte {
+    m := hmac.New(newHash, macKey)
+    for _, field := range [][]byte{[]byte(f.Algorithm), []byte(f.Encryption), []byte(f.Comment), f.Public, priv} {
+        var n [4]byte
+        binary.BigEndian.PutUint32(n[:], uint32(len(field)))
+        m.Write(n[:])
+        m.Write(field)
+    }
+    return m.Sum(nil)
+}
+
+// ppkPrivateKey 由公钥和 PPK 私钥部分组成私钥，并核对两者一致
+func ppkPrivateKey(pub ssh.PublicKey, priv []byte) (interface{}, error) {
+    cpk, ok := pub.(ssh.CryptoPublicKey)
+    if !ok {
+        return nil, fmt.Errorf("ppk: unsupported key type %s", pub.Type())
+    }
+    switch pk := cpk.CryptoPublicKey().(type) {
+    case *rsa.PublicKey:
+        var k struct {
+            D, P, Q, Iqmp *big.Int
+            Rest          []byte `ssh:"rest"`
+        }
+        if err := ssh.Unmarshal(priv, &k); err != nil {
+            return nil, fmt.Errorf("ppk: %w", err)
+        }
+        key := &rsa.PrivateKey{PublicKey: *pk, D: k.D, Primes: []*big.Int{k.P, k.Q}}
+        if err := key.Validate(); err != nil {
+            return nil, fmt.Errorf("ppk: %w", err)
+        }
+        key.Precompute()
+        return key, nil
+    case *ecdsa.PublicKey:
+        var k struct {
+            D    *big.Int
+            Rest []byte `ssh:"rest"`
+        }
+        if err := ssh.Unmarshal(priv, &k); err != nil {
+            return nil, fmt.Errorf("ppk: %w", err)
+        }
+        x, y := pk.Curve.ScalarBaseMult(k.D.Bytes())
+        if x.Cmp(pk.X) != 0 || y.Cmp(pk.Y) != 0 {
+            return nil, errors.New("ppk: private key does not match public key")
+        }
+        return &ecdsa.PrivateKey{PublicKey: *pk, D: k.D}, nil
+    case ed25519.PublicKey:
+        var k struct {
+            Seed []byte
+            Rest []byte `ssh:"rest"`
+        }
+        if err := ssh.Unmarshal(priv, &k); err != nil {
+            return nil, fmt.Errorf("ppk: %w", err)
+        }
+        // PuTTY 把私钥当作小端整数写入，末尾的零字节会被省略，需要补齐到 32 字节
+        if len(k.Seed) == 0 || len(k.Seed) > ed25519.SeedSize {
+            return nil, errors.New("ppk: invalid ed25519 private key")
+        }
+        seed := make([]byte, ed25519.SeedSize)
+        copy(seed, k.Seed)
+        key := ed25519.NewKeyFromSeed(seed)
+        if !bytes.Equal(key.Public().(ed25519.PublicKey), pk) {
+            return nil, errors.New("ppk: private key does not match public key")
+        }
+        return key, nil
+    }
+    return nil, fmt.Errorf("ppk: unsupported key type %s", pub.Type())
+}
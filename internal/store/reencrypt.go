@@ -0,0 +1,158 @@
+package store
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Reencryption 把整个密钥库用新 master key 重新加密到暂存后端
+// Commit 时整体替换当前后端，旧的保留为备份直到 Cleanup
+type Reencryption struct {
+    root        string
+    stagingRoot string
+    backupRoot  string
+    Count       int
+}
+
+// StageReencryption 用 oldKey 解密所有记录，再用 newKey 加密写入暂存后端
+// 任何一条记录失败（包括无法解析的记录文件）都会删除暂存并返回错误，当前密钥库保持不变
+func StageReencryption(oldKey, newKey []byte) (*Reencryption, error) {
+    b, err := relocatable()
+    if err != nil {
+        return nil, err
+    }
+    r := &Reencryption{root: b.Root(), stagingRoot: b.Root() + ".staging"}
+    if err := os.RemoveAll(r.stagingRoot); err != nil {
+        return nil, err
+    }
+    staging := b.At(r.stagingRoot)
+    files, err := listAll(b)
+    if err != nil {
+        return nil, err
+    }
+    for i := range files {
+        ef := &files[i]
+        rec, err := decryptRecord(ef, ef.Alias, oldKey)
+        if err != nil {
+            r.Abort()
+            return nil, fmt.Errorf("decrypt %s: %w", ef.Alias, err)
+        }
+        if err := SaveRecord(staging, rec, newKey); err != nil {
+            r.Abort()
+            return nil, fmt.Errorf("encrypt %s: %w", ef.Alias, err)
+        }
+        r.Count++
+    }
+    return r, nil
+}
+
+// Commit 用暂存替换当前密钥库，原密钥库改名为备份
+func (r *Reencryption) Commit() error {
+    r.backupRoot = fmt.Sprintf("%s.bak-%d", r.root, time.Now().Unix())
+    if exists(r.root) {
+        if err := os.Rename(r.root, r.backupRoot); err != nil {
+            return err
+        }
+    } else {
+        r.backupRoot = ""
+    }
+    if !exists(r.stagingRoot) {
+        // 没有任何记录，替换后密钥库为空
+        return nil
+    }
+    if err := os.Rename(r.stagingRoot, r.root); err != nil {
+        if r.backupRoot != "" {
+            _ = os.Rename(r.backupRoot, r.root)
+        }
+        return err
+    }
+    return nil
+}
+
+// Rollback 撤销已提交的替换，恢复原密钥库
+func (r *Reencryption) Rollback() error {
+    if exists(r.root) {
+        if err := os.Rename(r.root, r.stagingRoot); err != nil {
+            return err
+        }
+    }
+    if r.backupRoot != "" {
+        return os.Rename(r.backupRoot, r.root)
+    }
+    return nil
+}
+
+// Abort 丢弃尚未提交的暂存
+func (r *Reencryption) Abort() {
+    _ = os.RemoveAll(r.stagingRoot)
+}
+
+// Cleanup 提交成功后删除旧密钥库备份和残留的暂存
+// 备份中有新密钥库里没有的记录文件时保留备份并返回错误，备份是这些文件唯一的副本
+func (r *Reencryption) Cleanup() error {
+    _ = os.RemoveAll(r.stagingRoot)
+    if r.backupRoot == "" {
+        return nil
+    }
+    if missing := missingRecordFiles(r.backupRoot, r.root); len(missing) > 0 {
+        return fmt.Errorf("旧密钥库中的 %s 未被重新加密，已保留备份 %s", strings.Join(missing, ", "), r.backupRoot)
+    }
+    return os.RemoveAll(r.backupRoot)
+}
+
+// BackupDir 返回提交后旧密钥库的备份路径
+func (r *Reencryption) BackupDir() string {
+    return r.backupRoot
+}
+
+// listAll 返回后端的全部记录
+// List 会跳过无法解析的文件；整体替换密钥库前必须确认没有这样的文件，否则它们会随旧密钥库一起被删除
+func listAll(b Backend) ([]EncryptedFile, error) {
+    db, ok := b.(*DirBackend)
+    if !ok {
+        return b.List()
+    }
+    entries, err := db.Scan()
+    if err != nil {
+        return nil, err
+    }
+    var out []EncryptedFile
+    var bad []string
+    for _, e := range entries {
+        switch {
+        case e.Err != nil:
+            bad = append(bad, fmt.Sprintf("%s: %v", e.Path, e.Err))
+        case e.Record.Alias != e.Name:
+            bad = append(bad, fmt.Sprintf("%s: 记录的别名是 %q", e.Path, e.Record.Alias))
+        default:
+            out = append(out, *e.Record)
+        }
+    }
+    if len(bad) > 0 {
+        return nil, fmt.Errorf("以下记录文件无法读取，请先用 fssh doctor 检查并修复:\n  %s", strings.Join(bad, "\n  "))
+    }
+    sortFiles(out)
+    return out, nil
+}
+
+// missingRecordFiles 返回 oldRoot 目录中存在、newRoot 目录中不存在的 .enc 文件名
+// oldRoot 不是目录（单文件保险库）时返回空
+func missingRecordFiles(oldRoot, newRoot string) []string {
+    entries, err := os.ReadDir(oldRoot)
+    if err != nil {
+        return nil
+    }
+    var missing []string
+    for _, e := range entries {
+        if e.IsDir() || !strings.HasSuffix(e.Name(), ".enc") {
+            continue
+        }
+        if !exists(filepath.Join(newRoot, e.Name())) {
+            missing = append(missing, e.Name())
+        }
+    }
+    return missing
+}
@@ -0,0 +1,312 @@
+package store
+
+import (
+    "crypto/rand"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+
+    "fssh/internal/crypt"
+    "fssh/internal/fsutil"
+)
+
+// rekey 日志状态
+const (
+    RekeyStaging   = "staging"   // 正在把记录重新加密到暂存目录
+    RekeySwapped   = "swapped"   // 暂存目录已替换密钥目录，认证方式仍保存旧 master key
+    RekeyCommitted = "committed" // 认证方式已保存新 master key，只剩清理
+)
+
+const rekeyJournalVersion = "fssh-rekey/v1"
+
+// ErrRekeyInProgress 存在未完成的 rekey
+var ErrRekeyInProgress = errors.New("存在未完成的 rekey，请运行 fssh rekey --resume 或 fssh rekey --rollback")
+
+// ErrRekeyCommitted 认证方式已保存新 master key，rekey 只能继续完成
+var ErrRekeyCommitted = errors.New("认证方式已更新为新 master key，无法撤销，请运行 fssh rekey --resume 完成")
+
+// RekeyJournal rekey 进度日志
+// 新 master key 由旧 master key 加密保存在日志中，崩溃后可用旧 key 找回并继续
+type RekeyJournal struct {
+    Version    string            `json:"version"`
+    State      string            `json:"state"`
+    StartedAt  string            `json:"started_at"`
+    KeySalt    string            `json:"key_salt"`
+    KeyNonce   string            `json:"key_nonce"`
+    WrappedKey string            `json:"wrapped_key"`      // 由旧 master key 加密的新 master key
+    KeyCheck   string            `json:"key_check"`        // 新 master key 的校验值
+    Params     map[string]string `json:"params,omitempty"` // 认证模式相关的数据
+}
+
+// Rekey 一次可恢复的 rekey 过程
+// 暂存和备份与后端根路径相邻（<root>.rekey-staging、<root>.rekey-backup），日志为 <root>.rekey.json
+type Rekey struct {
+    Journal     *RekeyJournal
+    b           Relocatable
+    newKey      []byte
+    authUpdated bool
+}
+
+// relocatable 返回默认后端，要求支持整体替换
+func relocatable() (Relocatable, error) {
+    b, ok := Default().(Relocatable)
+    if !ok {
+        return nil, errors.New("当前存储后端不支持整体替换")
+    }
+    return b, nil
+}
+
+func rekeyJournalPath(b Relocatable) string {
+    return b.Root() + ".rekey.json"
+}
+
+func rekeyStagingRoot(b Relocatable) string {
+    return b.Root() + ".rekey-staging"
+}
+
+func rekeyBackupRoot(b Relocatable) string {
+    return b.Root() + ".rekey-backup"
+}
+
+// RekeyInProgress 检查是否存在未完成的 rekey
+func RekeyInProgress() bool {
+    b, err := relocatable()
+    if err != nil {
+        return false
+    }
+    return exists(rekeyJournalPath(b))
+}
+
+// rekeyKeyCheck 计算 master key 的校验值，用于判断认证方式是否已切换到新 key
+func rekeyKeyCheck(key []byte) string {
+    return base64.StdEncoding.EncodeToString(crypt.HKDF(key, nil, []byte("fssh-rekey-check-v1"), 16))
+}
+
+// StartRekey 写入 rekey 日志并创建暂存目录
+// 日志先于任何记录写入落盘，之后任何时刻崩溃都能用旧 master key 恢复
+func StartRekey(oldKey, newKey []byte, params map[string]string) (*Rekey, error) {
+    b, err := relocatable()
+    if err != nil {
+        return nil, err
+    }
+    if exists(rekeyJournalPath(b)) {
+        return nil, ErrRekeyInProgress
+    }
+    salt, err := crypt.RandBytes(rand.Reader, 32)
+    if err != nil {
+        return nil, err
+    }
+    nonce, err := crypt.RandBytes(rand.Reader, 12)
+    if err != nil {
+        return nil, err
+    }
+    wrapKey := crypt.HKDF(oldKey, salt, []byte("fssh-rekey-wrap-v1"), 32)
+    ct, err := crypt.EncryptAEAD(wrapKey, nonce, newKey, []byte(rekeyJournalVersion))
+    if err != nil {
+        return nil, err
+    }
+    j := &RekeyJournal{
+        Version:    rekeyJournalVersion,
+        State:      RekeyStaging,
+        StartedAt:  time.Now().Format(time.RFC3339),
+        KeySalt:    base64.StdEncoding.EncodeToString(salt),
+        KeyNonce:   base64.StdEncoding.EncodeToString(nonce),
+        WrappedKey: base64.StdEncoding.EncodeToString(ct),
+        KeyCheck:   rekeyKeyCheck(newKey),
+        Params:     params,
+    }
+    if err := os.RemoveAll(rekeyStagingRoot(b)); err != nil {
+        return nil, err
+    }
+    r := &Rekey{Journal: j, b: b, newKey: newKey}
+    if err := r.save(); err != nil {
+        return nil, err
+    }
+    return r, nil
+}
+
+// OpenRekey 读取未完成的 rekey 日志
+// currentKey 是认证方式当前保存的 master key：若它就是新 key，说明认证方式已更新；
+// 否则用它解开日志中的新 key
+func OpenRekey(currentKey []byte) (*Rekey, error) {
+    b, err := relocatable()
+    if err != nil {
+        return nil, err
+    }
+    data, err := os.ReadFile(rekeyJournalPath(b))
+    if err != nil {
+        return nil, err
+    }
+    var j RekeyJournal
+    if err := json.Unmarshal(data, &j); err != nil {
+        return nil, fmt.Errorf("解析 rekey 日志失败: %w", err)
+    }
+    if j.Version != rekeyJournalVersion {
+        return nil, fmt.Errorf("不支持的 rekey 日志版本: %s", j.Version)
+    }
+
+    if rekeyKeyCheck(currentKey) == j.KeyCheck {
+        return &Rekey{Journal: &j, b: b, newKey: currentKey, authUpdated: true}, nil
+    }
+
+    salt, err := base64.StdEncoding.DecodeString(j.KeySalt)
+    if err != nil {
+        return nil, err
+    }
+    nonce, err := base64.StdEncoding.DecodeString(j.KeyNonce)
+    if err != nil {
+        return nil, err
+    }
+    ct, err := base64.StdEncoding.DecodeString(j.WrappedKey)
+    if err != nil {
+        return nil, err
+    }
+    wrapKey := crypt.HKDF(currentKey, salt, []byte("fssh-rekey-wrap-v1"), 32)
+    newKey, err := crypt.DecryptAEAD(wrapKey, nonce, ct, []byte(rekeyJournalVersion))
+    if err != nil {
+        return nil, errors.New("当前 master key 无法解开 rekey 日志中的新 key")
+    }
+    return &Rekey{Journal: &j, b: b, newKey: newKey}, nil
+}
+
+// NewKey 返回新 master key
+func (r *Rekey) NewKey() []byte {
+    return r.newKey
+}
+
+// AuthUpdated 认证方式是否已保存新 master key
+func (r *Rekey) AuthUpdated() bool {
+    return r.authUpdated || r.Journal.State == RekeyCommitted
+}
+
+// Stage 用新 master key 重新加密记录到暂存后端
+// 已在暂存后端且能用新 key 解密的记录会被跳过，因此可以在中断后重复调用
+// 有无法解析的记录文件时返回错误，日志停留在暂存状态，修复后可用 --resume 继续
+func (r *Rekey) Stage(oldKey []byte) (int, error) {
+    if r.Journal.State != RekeyStaging || exists(rekeyBackupRoot(r.b)) {
+        return 0, nil
+    }
+    staging := r.b.At(rekeyStagingRoot(r.b))
+    files, err := listAll(r.b)
+    if err != nil {
+        return 0, err
+    }
+    wanted := make(map[string]bool, len(files))
+    for i := range files {
+        ef := &files[i]
+        wanted[ef.Alias] = true
+        if staged, err := staging.Get(ef.Alias); err == nil {
+            if _, err := decryptRecord(staged, ef.Alias, r.newKey); err == nil {
+                continue
+            }
+        }
+        rec, err := decryptRecord(ef, ef.Alias, oldKey)
+        if err != nil {
+            return 0, fmt.Errorf("decrypt %s: %w", ef.Alias, err)
+        }
+        if err := SaveRecord(staging, rec, r.newKey); err != nil {
+            return 0, fmt.Errorf("encrypt %s: %w", ef.Alias, err)
+        }
+    }
+    // 中断期间被删除的记录不应随暂存后端复活
+    staged, err := staging.List()
+    if err != nil {
+        return 0, err
+    }
+    for _, ef := range staged {
+        if !wanted[ef.Alias] {
+            _ = staging.Delete(ef.Alias)
+        }
+    }
+    return len(files), nil
+}
+
+// Swap 用暂存后端替换当前后端，旧的保留为备份
+// 每一步都可重复执行：中断后再次调用会从停下的位置继续
+func (r *Rekey) Swap() error {
+    root, staging, backup := r.b.Root(), rekeyStagingRoot(r.b), rekeyBackupRoot(r.b)
+    if exists(root) && exists(staging) && !exists(backup) {
+        if err := os.Rename(root, backup); err != nil {
+            return err
+        }
+    }
+    if exists(staging) && !exists(root) {
+        if err := os.Rename(staging, root); err != nil {
+            return err
+        }
+    }
+    r.Journal.State = RekeySwapped
+    return r.save()
+}
+
+// MarkCommitted 认证方式已保存新 master key 后调用
+func (r *Rekey) MarkCommitted() error {
+    r.Journal.State = RekeyCommitted
+    r.authUpdated = true
+    return r.save()
+}
+
+// Finish 删除备份、暂存和日志
+// 备份中有新密钥库里没有的记录文件时不删除备份，而是改名保留并返回保留的路径
+func (r *Rekey) Finish() (string, error) {
+    root, backup := r.b.Root(), rekeyBackupRoot(r.b)
+    kept := ""
+    if exists(backup) {
+        if len(missingRecordFiles(backup, root)) > 0 {
+            kept = fmt.Sprintf("%s.bak-%d", root, time.Now().Unix())
+            if err := os.Rename(backup, kept); err != nil {
+                return "", err
+            }
+        } else {
+            _ = os.RemoveAll(backup)
+        }
+    }
+    _ = os.RemoveAll(rekeyStagingRoot(r.b))
+    return kept, os.Remove(rekeyJournalPath(r.b))
+}
+
+// Rollback 撤销未完成的 rekey，恢复原记录
+// 认证方式已保存新 master key 后无法撤销，只能继续完成
+func (r *Rekey) Rollback() error {
+    if r.AuthUpdated() {
+        return ErrRekeyCommitted
+    }
+    root, staging, backup := r.b.Root(), rekeyStagingRoot(r.b), rekeyBackupRoot(r.b)
+    if exists(backup) {
+        if exists(root) {
+            _ = os.RemoveAll(staging)
+            if err := os.Rename(root, staging); err != nil {
+                return err
+            }
+        }
+        if err := os.Rename(backup, root); err != nil {
+            return err
+        }
+    }
+    if err := os.RemoveAll(staging); err != nil {
+        return err
+    }
+    return os.Remove(rekeyJournalPath(r.b))
+}
+
+func (r *Rekey) save() error {
+    data, err := json.MarshalIndent(r.Journal, "", "  ")
+    if err != nil {
+        return err
+    }
+    path := rekeyJournalPath(r.b)
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return err
+    }
+    return fsutil.WriteFileAtomic(path, data, 0600)
+}
+
+func exists(path string) bool {
+    _, err := os.Stat(path)
+    return err == nil
+}
+
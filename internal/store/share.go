@@ -0,0 +1,240 @@
+package store
+
+import (
+    "crypto/ecdh"
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "fssh/internal/crypt"
+    "golang.org/x/crypto/ssh"
+)
+
+const shareVersion = "fssh-share/v1"
+
+// 分享文件的接收方密钥类型
+const (
+    ShareRecipientEd25519 = "ed25519" // 转换为 X25519 后做 ECDH
+    ShareRecipientRSA     = "rsa"     // RSA-OAEP-SHA256 包装随机文件密钥
+)
+
+// shareMinRSABits 接收方 RSA 公钥的最小位数
+const shareMinRSABits = 2048
+
+// shareHeader 分享文件的明文头部，整体作为 AEAD 附加数据
+type shareHeader struct {
+    Version       string `json:"version"`
+    CreatedAt     string `json:"created_at"`
+    Recipient     string `json:"recipient"` // 接收方 SSH 公钥的 SHA256 指纹
+    RecipientType string `json:"recipient_type"`
+    Ephemeral     string `json:"ephemeral,omitempty"`   // ed25519：临时 X25519 公钥
+    WrappedKey    string `json:"wrapped_key,omitempty"` // rsa：RSA-OAEP 加密的文件密钥
+    Nonce         string `json:"nonce"`
+}
+
+// shareFile 分享文件结构
+// 别名、注释和私钥一起加密，只有接收方指纹是明文，用于在接收方的密钥库中找到解密用的密钥
+type shareFile struct {
+    shareHeader
+    Ciphertext string `json:"ciphertext"`
+}
+
+// SharedKey 已解析但尚未解密的分享文件
+type SharedKey struct {
+    CreatedAt     string
+    Recipient     string // 接收方公钥指纹
+    RecipientType string
+    file          shareFile
+}
+
+// shareKDF 由 X25519 共享秘密派生文件密钥，临时公钥和接收方公钥参与派生，绑定本次会话
+func shareKDF(shared, ephemeral, recipient []byte) []byte {
+    salt := append(append([]byte{}, ephemeral...), recipient...)
+    return crypt.HKDF(shared, salt, []byte(shareVersion), 32)
+}
+
+// CreateShare 把记录加密给接收方的 ed25519 或 RSA 公钥
+// 接收方用 OpenShare 和自己密钥库中对应的私钥解密，分享过程中私钥不以明文出现
+func CreateShare(rec *Record, to ssh.PublicKey) ([]byte, error) {
+    if cert, ok := to.(*ssh.Certificate); ok {
+        to = cert.Key
+    }
+    cpk, ok := to.(ssh.CryptoPublicKey)
+    if !ok {
+        return nil, fmt.Errorf("unsupported recipient key type %s", to.Type())
+    }
+    hdr := shareHeader{
+        Version:   shareVersion,
+        CreatedAt: time.Now().Format(time.RFC3339),
+        Recipient: ssh.FingerprintSHA256(to),
+    }
+    var key []byte
+    switch pk := cpk.CryptoPublicKey().(type) {
+    case ed25519.PublicKey:
+        recipient, err := crypt.Ed25519PublicToX25519(pk)
+        if err != nil {
+            return nil, err
+        }
+        eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+        if err != nil {
+            return nil, err
+        }
+        shared, err := eph.ECDH(recipient)
+        if err != nil {
+            return nil, err
+        }
+        key = shareKDF(shared, eph.PublicKey().Bytes(), recipient.Bytes())
+        hdr.RecipientType = ShareRecipientEd25519
+        hdr.Ephemeral = base64.StdEncoding.EncodeToString(eph.PublicKey().Bytes())
+    case *rsa.PublicKey:
+        if pk.N.BitLen() < shareMinRSABits {
+            return nil, fmt.Errorf("recipient RSA key has %d bits, at least %d are required", pk.N.BitLen(), shareMinRSABits)
+        }
+        var err error
+        if key, err = crypt.RandBytes(rand.Reader, 32); err != nil {
+            return nil, err
+        }
+        wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pk, key, []byte(shareVersion))
+        if err != nil {
+            return nil, err
+        }
+        hdr.RecipientType = ShareRecipientRSA
+        hdr.WrappedKey = base64.StdEncoding.EncodeToString(wrapped)
+    default:
+        return nil, fmt.Errorf("unsupported recipient key type %s (supported: ed25519, rsa)", to.Type())
+    }
+
+    nonce, err := crypt.RandBytes(rand.Reader, 12)
+    if err != nil {
+        return nil, err
+    }
+    hdr.Nonce = base64.StdEncoding.EncodeToString(nonce)
+    plaintext, err := json.Marshal(backupRecord{
+        Alias:       rec.Alias,
+        Fingerprint: rec.Fingerprint,
+        Comment:     rec.Comment,
+        CreatedAt:   rec.CreatedAt,
+        Meta:        rec.Meta,
+        PKCS8:       base64.StdEncoding.EncodeToString(rec.PKCS8DER),
+    })
+    if err != nil {
+        return nil, err
+    }
+    ad, err := json.Marshal(hdr)
+    if err != nil {
+        return nil, err
+    }
+    ct, err := crypt.EncryptAEAD(key, nonce, plaintext, ad)
+    if err != nil {
+        return nil, err
+    }
+    return json.MarshalIndent(shareFile{shareHeader: hdr, Ciphertext: base64.StdEncoding.EncodeToString(ct)}, "", "  ")
+}
+
+// ParseShare 解析分享文件头部，不解密
+func ParseShare(data []byte) (*SharedKey, error) {
+    var sf shareFile
+    if err := json.Unmarshal(data, &sf); err != nil {
+        return nil, fmt.Errorf("解析分享文件失败: %w", err)
+    }
+    if sf.Version != shareVersion {
+        return nil, fmt.Errorf("不支持的分享文件版本: %s", sf.Version)
+    }
+    switch sf.RecipientType {
+    case ShareRecipientEd25519, ShareRecipientRSA:
+    default:
+        return nil, fmt.Errorf("不支持的接收方密钥类型: %s", sf.RecipientType)
+    }
+    return &SharedKey{CreatedAt: sf.CreatedAt, Recipient: sf.Recipient, RecipientType: sf.RecipientType, file: sf}, nil
+}
+
+// Open 用接收方的记录解密分享文件，并校验其中私钥的指纹
+// 返回的记录保留分享方的别名、注释和元数据，CreatedAt 为空，保存时使用导入时间
+func (s *SharedKey) Open(identity *Record) (*Record, error) {
+    if identity.Fingerprint != s.Recipient {
+        return nil, fmt.Errorf("%s is not the recipient of this share (%s)", identity.Alias, s.Recipient)
+    }
+    priv, err := x509.ParsePKCS8PrivateKey(identity.PKCS8DER)
+    if err != nil {
+        return nil, err
+    }
+    var key []byte
+    switch s.RecipientType {
+    case ShareRecipientEd25519:
+        edPriv, ok := priv.(ed25519.PrivateKey)
+        if !ok {
+            return nil, fmt.Errorf("%s is not an ed25519 key", identity.Alias)
+        }
+        xPriv, err := crypt.Ed25519PrivateToX25519(edPriv)
+        if err != nil {
+            return nil, err
+        }
+        ephBytes, err := base64.StdEncoding.DecodeString(s.file.Ephemeral)
+        if err != nil {
+            return nil, err
+        }
+        eph, err := ecdh.X25519().NewPublicKey(ephBytes)
+        if err != nil {
+            return nil, err
+        }
+        shared, err := xPriv.ECDH(eph)
+        if err != nil {
+            return nil, err
+        }
+        key = shareKDF(shared, ephBytes, xPriv.PublicKey().Bytes())
+    case ShareRecipientRSA:
+        rsaPriv, ok := priv.(*rsa.PrivateKey)
+        if !ok {
+            return nil, fmt.Errorf("%s is not an RSA key", identity.Alias)
+        }
+        wrapped, err := base64.StdEncoding.DecodeString(s.file.WrappedKey)
+        if err != nil {
+            return nil, err
+        }
+        if key, err = rsa.DecryptOAEP(sha256.New(), nil, rsaPriv, wrapped, []byte(shareVersion)); err != nil {
+            return nil, errors.New("分享文件损坏或不是发给该密钥的")
+        }
+    }
+
+    nonce, err := base64.StdEncoding.DecodeString(s.file.Nonce)
+    if err != nil {
+        return nil, err
+    }
+    ct, err := base64.StdEncoding.DecodeString(s.file.Ciphertext)
+    if err != nil {
+        return nil, err
+    }
+    ad, err := json.Marshal(s.file.shareHeader)
+    if err != nil {
+        return nil, err
+    }
+    plaintext, err := crypt.DecryptAEAD(key, nonce, ct, ad)
+    if err != nil {
+        return nil, errors.New("分享文件损坏或不是发给该密钥的")
+    }
+    var br backupRecord
+    if err := json.Unmarshal(plaintext, &br); err != nil {
+        return nil, fmt.Errorf("解析分享内容失败: %w", err)
+    }
+    der, err := base64.StdEncoding.DecodeString(br.PKCS8)
+    if err != nil {
+        return nil, err
+    }
+    if err := checkPublicKey(&EncryptedFile{Fingerprint: br.Fingerprint}, der); err != nil {
+        return nil, err
+    }
+    return &Record{
+        Alias:       br.Alias,
+        Fingerprint: br.Fingerprint,
+        Comment:     br.Comment,
+        Meta:        br.Meta,
+        PKCS8DER:    der,
+    }, nil
+}
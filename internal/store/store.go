@@ -1,6 +1,7 @@
 package store
 
 import (
+    "bytes"
     "crypto/rand"
     "crypto/ecdsa"
     "crypto/ed25519"
@@ -8,16 +9,24 @@ import (
     "crypto/x509"
     "encoding/base64"
     "encoding/json"
-    "encoding/pem"
     "errors"
-    "os"
+    "fmt"
     "path/filepath"
     "time"
 
     "fssh/internal/crypt"
+    "fssh/internal/profile"
     "golang.org/x/crypto/ssh"
 )
 
+// 记录文件格式版本
+const (
+    // VersionV1 仅以指纹作为 AEAD 附加数据，元数据可被篡改而不被发现
+    VersionV1 = "fingerpass/v1"
+    // VersionV2 以全部元数据作为 AEAD 附加数据
+    VersionV2 = "fssh/v2"
+)
+
 type EncryptedFile struct {
     Version     string `json:"version"`
     Alias       string `json:"alias"`
@@ -29,37 +38,102 @@ type EncryptedFile struct {
     Ciphertext  string `json:"ciphertext"`
     CreatedAt   string `json:"created_at"`
     Comment     string `json:"comment"`
+    // Meta 不参与 AEAD 认证，修改无需解密私钥，由 MetaMAC 认证，见 KeyMeta
+    Meta    *KeyMeta `json:"meta,omitempty"`
+    MetaMAC string   `json:"meta_mac,omitempty"`
 }
 
 type Record struct {
     Alias       string
     Fingerprint string
     Comment     string
+    CreatedAt   string // 为空时保存记录使用当前时间
+    Meta        *KeyMeta
     PKCS8DER    []byte
 }
 
+// recordHeader v2 记录中受 AEAD 认证的元数据
+// 盐和 nonce 已参与密钥派生和解密，无需重复认证
+type recordHeader struct {
+    Version     string `json:"version"`
+    Alias       string `json:"alias"`
+    Fingerprint string `json:"fingerprint"`
+    PubKey      string `json:"pubkey"`
+    KeyType     string `json:"key_type"`
+    CreatedAt   string `json:"created_at"`
+    Comment     string `json:"comment"`
+}
+
+// associatedData 返回记录的 AEAD 附加数据
+// v1 只认证指纹；v2 认证全部元数据，修改别名、注释、公钥或创建时间都会导致解密失败
+func (ef *EncryptedFile) associatedData() ([]byte, error) {
+    switch ef.Version {
+    case VersionV1:
+        return []byte(ef.Fingerprint), nil
+    case VersionV2:
+        return json.Marshal(recordHeader{
+            Version:     ef.Version,
+            Alias:       ef.Alias,
+            Fingerprint: ef.Fingerprint,
+            PubKey:      ef.PubKey,
+            KeyType:     ef.KeyType,
+            CreatedAt:   ef.CreatedAt,
+            Comment:     ef.Comment,
+        })
+    default:
+        return nil, fmt.Errorf("unsupported record version: %s", ef.Version)
+    }
+}
+
 func KeysDir() string {
-    home, _ := os.UserHomeDir()
-    return filepath.Join(home, ".fssh", "keys")
+    return filepath.Join(profile.Dir(), "keys")
 }
 
-func ensureDirs() error {
-    dir := KeysDir()
-    return os.MkdirAll(dir, 0700)
+// 私钥文件格式
+const (
+    KeyFormatPEM    = "pem" // OpenSSH、PKCS#1、PKCS#8、SEC1 等 PEM 格式
+    KeyFormatPPK    = "ppk" // PuTTY PPK v2/v3
+    KeyFormatPKCS12 = "pkcs12"
+)
+
+// DetectKeyFormat 根据内容识别私钥文件格式，无法识别时按 PEM 处理
+func DetectKeyFormat(data []byte) string {
+    switch {
+    case bytes.HasPrefix(bytes.TrimLeft(data, "\xef\xbb\xbf \t\r\n"), []byte("PuTTY-User-Key-File-")):
+        return KeyFormatPPK
+    case len(data) > 0 && data[0] == 0x30 && isPKCS12(data):
+        return KeyFormatPKCS12
+    }
+    return KeyFormatPEM
 }
 
-func NewRecordFromPrivateKeyBytes(alias string, keyFileBytes []byte, passphrase string, comment string) (*Record, error) {
-    var k interface{}
-    var err error
+// ParsePrivateKeyBytes 识别格式并解析私钥文件
+// 加密的私钥未提供口令时返回 *ssh.PassphraseMissingError，口令错误时返回 x509.IncorrectPasswordError
+func ParsePrivateKeyBytes(keyFileBytes []byte, passphrase string) (interface{}, error) {
+    switch DetectKeyFormat(keyFileBytes) {
+    case KeyFormatPPK:
+        return parsePPK(bytes.TrimLeft(keyFileBytes, "\xef\xbb\xbf \t\r\n"), passphrase)
+    case KeyFormatPKCS12:
+        return parsePKCS12(keyFileBytes, passphrase)
+    }
     if passphrase != "" {
-        k, err = ssh.ParseRawPrivateKeyWithPassphrase(keyFileBytes, []byte(passphrase))
-    } else {
-        k, err = ssh.ParseRawPrivateKey(keyFileBytes)
+        return ssh.ParseRawPrivateKeyWithPassphrase(keyFileBytes, []byte(passphrase))
     }
+    return ssh.ParseRawPrivateKey(keyFileBytes)
+}
+
+func NewRecordFromPrivateKeyBytes(alias string, keyFileBytes []byte, passphrase string, comment string) (*Record, error) {
+    k, err := ParsePrivateKeyBytes(keyFileBytes, passphrase)
     if err != nil {
         return nil, err
     }
+    return newRecordFromKey(alias, k, comment)
+}
+
+// newRecordFromKey 把私钥转换为 PKCS#8 记录并计算指纹
+func newRecordFromKey(alias string, k interface{}, comment string) (*Record, error) {
     var der []byte
+    var err error
     switch kk := k.(type) {
     case ed25519.PrivateKey:
         der, err = x509.MarshalPKCS8PrivateKey(kk)
@@ -84,66 +158,94 @@ func NewRecordFromPrivateKeyBytes(alias string, keyFileBytes []byte, passphrase
     return &Record{Alias: alias, Fingerprint: fp, Comment: comment, PKCS8DER: der}, nil
 }
 
+// SaveEncryptedRecord 加密记录并保存到默认后端
 func SaveEncryptedRecord(rec *Record, masterKey []byte) error {
-    if err := ensureDirs(); err != nil {
+    return SaveRecord(Default(), rec, masterKey)
+}
+
+// SaveRecord 加密记录并保存到指定后端
+func SaveRecord(b Backend, rec *Record, masterKey []byte) error {
+    ef, err := sealRecord(rec, masterKey)
+    if err != nil {
         return err
     }
+    return b.Put(ef)
+}
+
+// sealRecord 用 master key 加密记录，生成 v2 格式的 EncryptedFile
+func sealRecord(rec *Record, masterKey []byte) (*EncryptedFile, error) {
     salt, err := crypt.RandBytes(rand.Reader, 32)
     if err != nil {
-        return err
+        return nil, err
     }
     nonce, err := crypt.RandBytes(rand.Reader, 12)
     if err != nil {
-        return err
-    }
-    fileKey := crypt.HKDF(masterKey, salt, []byte(rec.Alias), 32)
-    ct, err := crypt.EncryptAEAD(fileKey, nonce, rec.PKCS8DER, []byte(rec.Fingerprint))
-    if err != nil {
-        return err
+        return nil, err
     }
     // derive public key bytes
     pk, err := x509.ParsePKCS8PrivateKey(rec.PKCS8DER)
     if err != nil {
-        return err
+        return nil, err
     }
     signer, err := ssh.NewSignerFromKey(pk)
     if err != nil {
-        return err
+        return nil, err
     }
     pubRaw := signer.PublicKey().Marshal()
+    createdAt := rec.CreatedAt
+    if createdAt == "" {
+        createdAt = time.Now().Format(time.RFC3339)
+    }
     ef := EncryptedFile{
-        Version:     "fingerpass/v1",
+        Version:     VersionV2,
         Alias:       rec.Alias,
         Fingerprint: rec.Fingerprint,
         PubKey:      base64.StdEncoding.EncodeToString(pubRaw),
         KeyType:     "PKCS8",
         HKDFSalt:    base64.StdEncoding.EncodeToString(salt),
         Nonce:       base64.StdEncoding.EncodeToString(nonce),
-        Ciphertext:  base64.StdEncoding.EncodeToString(ct),
-        CreatedAt:   time.Now().Format(time.RFC3339),
+        CreatedAt:   createdAt,
         Comment:     rec.Comment,
+        Meta:        rec.Meta,
     }
-    b, err := json.MarshalIndent(ef, "", "  ")
+    ad, err := ef.associatedData()
     if err != nil {
-        return err
+        return nil, err
     }
-    path := filepath.Join(KeysDir(), rec.Alias+".enc")
-    return os.WriteFile(path, b, 0600)
+    fileKey := crypt.HKDF(masterKey, salt, []byte(rec.Alias), 32)
+    ct, err := crypt.EncryptAEAD(fileKey, nonce, rec.PKCS8DER, ad)
+    if err != nil {
+        return nil, err
+    }
+    ef.Ciphertext = base64.StdEncoding.EncodeToString(ct)
+    if err := sealMeta(&ef, masterKey); err != nil {
+        return nil, err
+    }
+    return &ef, nil
 }
 
+// LoadDecryptedRecord 从默认后端读取并解密记录，支持 v1 和 v2 格式
 func LoadDecryptedRecord(alias string, masterKey []byte) (*Record, error) {
-    path := filepath.Join(KeysDir(), alias+".enc")
-    b, err := os.ReadFile(path)
+    return LoadRecord(Default(), alias, masterKey)
+}
+
+// LoadRecord 从指定后端读取并解密记录
+func LoadRecord(b Backend, alias string, masterKey []byte) (*Record, error) {
+    ef, err := b.Get(alias)
     if err != nil {
         return nil, err
     }
-    var ef EncryptedFile
-    if err := json.Unmarshal(b, &ef); err != nil {
-        return nil, err
-    }
-    if ef.Version != "fingerpass/v1" || ef.Alias != alias || ef.KeyType != "PKCS8" {
+    return decryptRecord(ef, alias, masterKey)
+}
+
+func decryptRecord(ef *EncryptedFile, alias string, masterKey []byte) (*Record, error) {
+    if ef.Alias != alias || ef.KeyType != "PKCS8" {
         return nil, errors.New("invalid record metadata")
     }
+    ad, err := ef.associatedData()
+    if err != nil {
+        return nil, err
+    }
     salt, err := base64.StdEncoding.DecodeString(ef.HKDFSalt)
     if err != nil {
         return nil, err
@@ -157,21 +259,77 @@ func LoadDecryptedRecord(alias string, masterKey []byte) (*Record, error) {
         return nil, err
     }
     fileKey := crypt.HKDF(masterKey, salt, []byte(alias), 32)
-    der, err := crypt.DecryptAEAD(fileKey, nonce, ct, []byte(ef.Fingerprint))
+    der, err := crypt.DecryptAEAD(fileKey, nonce, ct, ad)
     if err != nil {
         return nil, err
     }
-    return &Record{Alias: ef.Alias, Fingerprint: ef.Fingerprint, Comment: ef.Comment, PKCS8DER: der}, nil
+    // v1 的公钥未被认证，解密后与私钥核对，防止 List 展示被替换的公钥
+    if err := checkPublicKey(ef, der); err != nil {
+        return nil, err
+    }
+    // 旧记录没有元数据 MAC，仍可解密以便迁移；有 MAC 时必须匹配
+    if err := VerifyMeta(ef, masterKey); err != nil && !errors.Is(err, ErrMetaUnauthenticated) {
+        return nil, err
+    }
+    return &Record{Alias: ef.Alias, Fingerprint: ef.Fingerprint, Comment: ef.Comment, CreatedAt: ef.CreatedAt, Meta: ef.Meta, PKCS8DER: der}, nil
 }
 
-func ExportPKCS8PEM(rec *Record, passphrase string) ([]byte, error) {
-    block := &pem.Block{Type: "PRIVATE KEY", Bytes: rec.PKCS8DER}
-    if passphrase != "" {
-        enc, err := x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte(passphrase), x509.PEMCipherAES256)
-        if err != nil {
-            return nil, err
-        }
-        return pem.EncodeToMemory(enc), nil
-    }
-    return pem.EncodeToMemory(block), nil
-}
\ No newline at end of file
+// checkPublicKey 校验记录中的公钥和指纹与解密出的私钥一致
+func checkPublicKey(ef *EncryptedFile, der []byte) error {
+    pk, err := x509.ParsePKCS8PrivateKey(der)
+    if err != nil {
+        return err
+    }
+    signer, err := ssh.NewSignerFromKey(pk)
+    if err != nil {
+        return err
+    }
+    pub := signer.PublicKey()
+    if ssh.FingerprintSHA256(pub) != ef.Fingerprint {
+        return errors.New("record fingerprint does not match private key")
+    }
+    if ef.PubKey != "" && ef.PubKey != base64.StdEncoding.EncodeToString(pub.Marshal()) {
+        return errors.New("record public key does not match private key")
+    }
+    return nil
+}
+
+// MigrateRecord 将 v1 记录升级为 v2，并为没有元数据 MAC 的记录补上 MAC，保留别名、注释和创建时间
+// 已是 v2 且有元数据 MAC 的记录不做修改，返回 false
+func MigrateRecord(b Backend, alias string, masterKey []byte) (bool, error) {
+    ef, err := b.Get(alias)
+    if err != nil {
+        return false, err
+    }
+    if !NeedsMigration(ef) {
+        return false, nil
+    }
+    rec, err := decryptRecord(ef, alias, masterKey)
+    if err != nil {
+        return false, err
+    }
+    if err := SaveRecord(b, rec, masterKey); err != nil {
+        return false, err
+    }
+    return true, nil
+}
+
+// NeedsMigration 记录是否需要 fssh store migrate 升级格式或补上元数据 MAC
+func NeedsMigration(ef *EncryptedFile) bool {
+    return ef.Version != VersionV2 || ef.MetaMAC == ""
+}
+
+// RenameRecord 修改记录别名
+// 别名参与文件密钥派生和附加数据认证，因此需要解密后以新别名重新加密
+func RenameRecord(b Backend, oldAlias, newAlias string, masterKey []byte) error {
+    rec, err := LoadRecord(b, oldAlias, masterKey)
+    if err != nil {
+        return err
+    }
+    rec.Alias = newAlias
+    ef, err := sealRecord(rec, masterKey)
+    if err != nil {
+        return err
+    }
+    return b.Rename(oldAlias, ef)
+}
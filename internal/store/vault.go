@@ -0,0 +1,159 @@
+package store
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "syscall"
+
+    "fssh/internal/fsutil"
+)
+
+const vaultVersion = "fssh-vault/v1"
+
+// vaultFile 单文件保险库格式
+// 每条记录与目录后端一样单独加密，保险库只是把它们放进一个文件，便于放在移动或同步存储上
+// 与目录后端相同，只有私钥是加密的：别名、指纹、公钥、注释和元数据以明文 JSON 保存
+type vaultFile struct {
+    Version string          `json:"version"`
+    Records []EncryptedFile `json:"records"`
+}
+
+// VaultBackend 所有记录保存在一个文件中
+// 写入时持有文件锁并整体原子替换，多个进程同时修改不会丢失记录
+type VaultBackend struct {
+    Path string
+}
+
+// NewVaultBackend 创建单文件保险库后端
+func NewVaultBackend(path string) *VaultBackend {
+    return &VaultBackend{Path: path}
+}
+
+func (b *VaultBackend) load() (*vaultFile, error) {
+    data, err := os.ReadFile(b.Path)
+    if errors.Is(err, os.ErrNotExist) {
+        return &vaultFile{Version: vaultVersion}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var v vaultFile
+    if err := json.Unmarshal(data, &v); err != nil {
+        return nil, fmt.Errorf("parse vault %s: %w", b.Path, err)
+    }
+    if v.Version != vaultVersion {
+        return nil, fmt.Errorf("unsupported vault version: %s", v.Version)
+    }
+    return &v, nil
+}
+
+// update 在文件锁内读取、修改并原子写回保险库
+func (b *VaultBackend) update(fn func(v *vaultFile) error) error {
+    if err := os.MkdirAll(filepath.Dir(b.Path), 0700); err != nil {
+        return err
+    }
+    lock, err := os.OpenFile(b.Path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+    if err != nil {
+        return err
+    }
+    defer lock.Close()
+    if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+        return err
+    }
+    defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+    v, err := b.load()
+    if err != nil {
+        return err
+    }
+    if err := fn(v); err != nil {
+        return err
+    }
+    sortFiles(v.Records)
+    data, err := json.MarshalIndent(v, "", "  ")
+    if err != nil {
+        return err
+    }
+    return fsutil.WriteFileAtomic(b.Path, data, 0600)
+}
+
+func (v *vaultFile) index(alias string) int {
+    for i := range v.Records {
+        if v.Records[i].Alias == alias {
+            return i
+        }
+    }
+    return -1
+}
+
+func (b *VaultBackend) List() ([]EncryptedFile, error) {
+    v, err := b.load()
+    if err != nil {
+        return nil, err
+    }
+    sortFiles(v.Records)
+    return v.Records, nil
+}
+
+func (b *VaultBackend) Get(alias string) (*EncryptedFile, error) {
+    v, err := b.load()
+    if err != nil {
+        return nil, err
+    }
+    i := v.index(alias)
+    if i < 0 {
+        return nil, fmt.Errorf("%w: %s", ErrNotFound, alias)
+    }
+    ef := v.Records[i]
+    return &ef, nil
+}
+
+func (b *VaultBackend) Put(ef *EncryptedFile) error {
+    if err := validateAlias(ef.Alias); err != nil {
+        return err
+    }
+    return b.update(func(v *vaultFile) error {
+        if i := v.index(ef.Alias); i >= 0 {
+            v.Records[i] = *ef
+        } else {
+            v.Records = append(v.Records, *ef)
+        }
+        return nil
+    })
+}
+
+func (b *VaultBackend) Delete(alias string) error {
+    return b.update(func(v *vaultFile) error {
+        i := v.index(alias)
+        if i < 0 {
+            return fmt.Errorf("%w: %s", ErrNotFound, alias)
+        }
+        v.Records = append(v.Records[:i], v.Records[i+1:]...)
+        return nil
+    })
+}
+
+// Rename 在一次原子写入中替换记录
+func (b *VaultBackend) Rename(oldAlias string, ef *EncryptedFile) error {
+    if err := validateAlias(ef.Alias); err != nil {
+        return err
+    }
+    return b.update(func(v *vaultFile) error {
+        i := v.index(oldAlias)
+        if i < 0 {
+            return fmt.Errorf("%w: %s", ErrNotFound, oldAlias)
+        }
+        if ef.Alias != oldAlias && v.index(ef.Alias) >= 0 {
+            return fmt.Errorf("%w: %s", ErrExists, ef.Alias)
+        }
+        v.Records[i] = *ef
+        return nil
+    })
+}
+
+func (b *VaultBackend) Root() string { return b.Path }
+
+func (b *VaultBackend) At(root string) Backend { return NewVaultBackend(root) }
@@ -0,0 +1,104 @@
+package usage
+
+import (
+	"sync"
+	"time"
+
+	"fssh/internal/log"
+)
+
+// DefaultFlushInterval agent 写入使用统计的间隔
+const DefaultFlushInterval = time.Minute
+
+// Recorder 在内存中累积使用统计，按固定间隔批量写入 usage.json
+// 每次写入都在文件锁内合并并原子替换，崩溃时最多丢失一个间隔内的统计，文件不会损坏
+type Recorder struct {
+	path    string
+	mu      sync.Mutex
+	pending map[string]*KeyUsage
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewRecorder 创建写入当前 profile 的 usage.json 的记录器，并启动后台写入
+func NewRecorder(interval time.Duration) *Recorder {
+	r := &Recorder{
+		path:    Path(),
+		pending: map[string]*KeyUsage{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go r.loop(interval)
+	return r
+}
+
+// Record 记录一次签名
+func (r *Recorder) Record(fingerprint, destination string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u := r.pending[fingerprint]
+	if u == nil {
+		u = &KeyUsage{}
+		r.pending[fingerprint] = u
+	}
+	u.Count++
+	u.LastUsed = time.Now().Format(time.RFC3339)
+	if destination != "" {
+		u.LastDestination = destination
+	}
+}
+
+// Flush 立即写入累积的统计；写入失败时保留增量，下次重试
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = map[string]*KeyUsage{}
+	r.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := update(r.path, func(f *File) {
+		for fp, d := range pending {
+			f.merge(fp, d)
+		}
+	})
+	if err != nil {
+		r.mu.Lock()
+		for fp, d := range pending {
+			if cur := r.pending[fp]; cur != nil {
+				d.Count += cur.Count
+				d.LastUsed = cur.LastUsed
+				if cur.LastDestination != "" {
+					d.LastDestination = cur.LastDestination
+				}
+			}
+			r.pending[fp] = d
+		}
+		r.mu.Unlock()
+	}
+	return err
+}
+
+// Close 停止后台写入并写入剩余统计
+func (r *Recorder) Close() error {
+	close(r.stop)
+	<-r.done
+	return r.Flush()
+}
+
+func (r *Recorder) loop(interval time.Duration) {
+	defer close(r.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := r.Flush(); err != nil {
+				log.Warn("写入使用统计失败", map[string]interface{}{"error": err.Error()})
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package usage 记录每个密钥的使用统计（最近使用时间、签名次数、最近的目标）
+// 统计保存在 profile 目录下的 usage.json，与加密记录分开，agent 批量写入
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"fssh/internal/fsutil"
+	"fssh/internal/profile"
+)
+
+const fileVersion = "fssh-usage/v1"
+
+// KeyUsage 单个密钥（按指纹）的使用统计
+type KeyUsage struct {
+	LastUsed        string `json:"last_used"` // RFC3339
+	Count           uint64 `json:"count"`
+	LastDestination string `json:"last_destination,omitempty"` // user@host，取决于客户端提供的信息
+}
+
+// LastUsedTime 解析最近使用时间，从未使用时返回零值
+func (u *KeyUsage) LastUsedTime() time.Time {
+	if u == nil {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339, u.LastUsed)
+	return t
+}
+
+// File usage.json 的内容
+type File struct {
+	Version string               `json:"version"`
+	Keys    map[string]*KeyUsage `json:"keys"` // 指纹 -> 统计
+}
+
+// Path 返回当前 profile 的 usage.json 路径
+func Path() string {
+	return filepath.Join(profile.Dir(), "usage.json")
+}
+
+// Load 读取使用统计，文件不存在时返回空统计
+func Load() (*File, error) {
+	return load(Path())
+}
+
+func load(path string) (*File, error) {
+	f := &File{Version: fileVersion, Keys: map[string]*KeyUsage{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if f.Version != fileVersion {
+		return nil, fmt.Errorf("unsupported usage file version: %s", f.Version)
+	}
+	if f.Keys == nil {
+		f.Keys = map[string]*KeyUsage{}
+	}
+	return f, nil
+}
+
+// Get 返回指纹对应的统计，从未使用时返回 nil
+func (f *File) Get(fingerprint string) *KeyUsage {
+	return f.Keys[fingerprint]
+}
+
+// merge 把增量合并进文件：次数相加，最近使用时间取较晚者
+func (f *File) merge(fingerprint string, d *KeyUsage) {
+	u := f.Keys[fingerprint]
+	if u == nil {
+		u = &KeyUsage{}
+		f.Keys[fingerprint] = u
+	}
+	u.Count += d.Count
+	if !d.LastUsedTime().Before(u.LastUsedTime()) {
+		u.LastUsed = d.LastUsed
+		if d.LastDestination != "" {
+			u.LastDestination = d.LastDestination
+		}
+	}
+}
+
+// update 在文件锁内读取、合并并原子写回，多个 agent 同时写入不会丢失计数
+func update(path string, fn func(f *File)) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	f, err := load(path)
+	if err != nil {
+		return err
+	}
+	fn(f)
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data, 0600)
+}